@@ -1,41 +1,34 @@
+// Command migrate is a placeholder: the backend has no schema or migrations
+// yet. It fails instead of pretending to migrate, and points to the lab04
+// migrate command, which applies the embedded lab04 migrations.
 package main
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
-
-	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
-	}
+const (
+	exitFailed = 1
+	exitUsage  = 2
+)
 
-	cfg := config.Load()
-	direction := os.Args[1]
+// lab04Migrate tells how to run the migrate command that works
+const lab04Migrate = "cd labs/lab04/backend && go run ./cmd/migrate -database \"$DATABASE_URL\" up"
 
-	switch direction {
-	case "up":
-		runMigrationsUp(cfg)
-	case "down":
-		runMigrationsDown(cfg)
-	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
-	}
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
-}
+// run executes the command line in args and returns the exit code
+func run(args []string, stderr io.Writer) int {
+	if len(args) != 1 || (args[0] != "up" && args[0] != "down") {
+		fmt.Fprintln(stderr, "Usage: go run cmd/migrate/main.go [up|down]")
+		return exitUsage
+	}
 
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+	fmt.Fprintf(stderr, "migrate: cannot run %s: the backend has no migrations yet\n", args[0])
+	fmt.Fprintf(stderr, "migrate: the lab04 schema is managed by labs/lab04/backend/cmd/migrate:\n  %s\n", lab04Migrate)
+	return exitFailed
 }
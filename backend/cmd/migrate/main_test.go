@@ -0,0 +1,22 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunNeverReportsSuccess(t *testing.T) {
+	for _, direction := range []string{"up", "down"} {
+		var stderr bytes.Buffer
+		if code := run([]string{direction}, &stderr); code != exitFailed {
+			t.Errorf("Expected exit code %d for %s, got %d", exitFailed, direction, code)
+		}
+		if !strings.Contains(stderr.String(), "labs/lab04/backend/cmd/migrate") {
+			t.Errorf("Expected a pointer to the lab04 migrate command, got %q", stderr.String())
+		}
+	}
+	if code := run([]string{"sideways"}, &bytes.Buffer{}); code != exitUsage {
+		t.Errorf("Expected exit code %d for a bad command, got %d", exitUsage, code)
+	}
+}
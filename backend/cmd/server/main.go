@@ -31,15 +31,8 @@ func main() {
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-
-	// API routes
-	api := router.Group("/api/v1")
-	{
-		api.GET("/ping", handlers.Ping)
-		// Add more routes as needed
-	}
+	// Routes, including the OpenAPI document at /openapi.json
+	handlers.Register(router)
 
 	// Create HTTP server
 	server := &http.Server{
@@ -2,7 +2,10 @@ module github.com/timur-harin/sum25-go-flutter-course/backend
 
 go 1.24.3
 
-require github.com/gin-gonic/gin v1.10.0
+require (
+	github.com/gin-gonic/gin v1.10.0
+	github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi v0.0.0
+)
 
 require (
 	github.com/bytedance/sonic v1.12.4 // indirect
@@ -36,3 +39,5 @@ require (
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi => ./pkg/openapi
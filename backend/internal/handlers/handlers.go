@@ -6,18 +6,30 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// HealthResponse is the body of GET /health
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Service string `json:"service"`
+	Version string `json:"version"`
+}
+
+// PingResponse is the body of GET /api/v1/ping
+type PingResponse struct {
+	Message string `json:"message"`
+}
+
 // HealthCheck returns server health status
 func HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "sum25-go-flutter-course-backend",
-		"version": "1.0.0",
+	c.JSON(http.StatusOK, HealthResponse{
+		Status:  "healthy",
+		Service: "sum25-go-flutter-course-backend",
+		Version: "1.0.0",
 	})
 }
 
 // Ping returns a simple pong response
 func Ping(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "pong",
+	c.JSON(http.StatusOK, PingResponse{
+		Message: "pong",
 	})
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+)
+
+// Register adds all routes to router. OpenAPI must describe every one of them.
+func Register(router *gin.Engine) {
+	// Health check endpoint
+	router.GET("/health", HealthCheck)
+
+	// API documentation
+	router.GET("/openapi.json", gin.WrapH(openapi.Handler(OpenAPI())))
+	router.GET("/docs", gin.WrapH(openapi.DocsHandler("/openapi.json")))
+
+	// API routes
+	api := router.Group("/api/v1")
+	{
+		api.GET("/ping", Ping)
+		// Add more routes as needed
+	}
+}
+
+// OpenAPI describes the routes added by Register
+func OpenAPI() *openapi.Document {
+	spec := openapi.New("sum25-go-flutter-course-backend", "1.0.0", "Course backend API")
+
+	spec.Add("GET", "/health", openapi.Operation{
+		Summary: "Report server health",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Server is healthy", spec.Schema(HealthResponse{})),
+		}),
+	})
+	spec.Add("GET", "/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("OpenAPI 3 document", &openapi.Schema{Type: "object"}),
+		}),
+	})
+	spec.Add("GET", "/docs", openapi.Operation{
+		Summary: "API documentation page",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("HTML page", "text/html", openapi.String()),
+		}),
+	})
+	spec.Add("GET", "/api/v1/ping", openapi.Operation{
+		Summary: "Check that the API responds",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Pong", spec.Schema(PingResponse{})),
+		}),
+	})
+
+	return spec.Document()
+}
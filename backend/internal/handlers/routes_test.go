@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	Register(router)
+
+	var served []string
+	for _, route := range router.Routes() {
+		served = append(served, route.Method+" "+openapi.NormalizePath(route.Path))
+	}
+	undocumented, unserved := openapi.Diff(served, OpenAPI().Routes())
+	for _, route := range undocumented {
+		t.Errorf("Route %s is missing from the OpenAPI document", route)
+	}
+	for _, route := range unserved {
+		t.Errorf("OpenAPI documents %s, which is not routed", route)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	Register(router)
+
+	for _, path := range []string{"/openapi.json", "/docs"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for %s, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi
+
+go 1.23.0
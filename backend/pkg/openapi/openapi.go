@@ -0,0 +1,216 @@
+// Package openapi builds OpenAPI 3 documents from route descriptions and
+// Go types, and serves them with a small documentation page.
+//
+// It is a module of its own, without dependencies, so the lab modules can
+// use it through a replace directive.
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to operations
+type PathItem map[string]*Operation
+
+// Operation describes one method on one path
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType gives the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Spec collects operations and the schemas they use
+type Spec struct {
+	doc   Document
+	types map[reflect.Type]string // Component name of each registered struct
+}
+
+// New returns an empty spec
+func New(title, version, description string) *Spec {
+	return &Spec{
+		doc: Document{
+			OpenAPI:    Version,
+			Info:       Info{Title: title, Version: version, Description: description},
+			Paths:      make(map[string]PathItem),
+			Components: Components{Schemas: make(map[string]*Schema)},
+		},
+		types: make(map[reflect.Type]string),
+	}
+}
+
+// Add documents method on path. Path parameters are written as {name}.
+// Operations without an ID get one derived from the method and path.
+func (s *Spec) Add(method, path string, op Operation) {
+	method = strings.ToLower(method)
+	if op.OperationID == "" {
+		op.OperationID = operationID(method, path)
+	}
+	if op.Responses == nil {
+		op.Responses = make(map[string]*Response)
+	}
+	item, ok := s.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		s.doc.Paths[path] = item
+	}
+	item[method] = &op
+}
+
+// AddBearerAuth declares a JWT bearer scheme that operations can require with Bearer
+func (s *Spec) AddBearerAuth(name string) {
+	if s.doc.Components.SecuritySchemes == nil {
+		s.doc.Components.SecuritySchemes = make(map[string]*SecurityScheme)
+	}
+	s.doc.Components.SecuritySchemes[name] = &SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+}
+
+// Bearer returns the security requirement for the named bearer scheme
+func Bearer(name string) []map[string][]string {
+	return []map[string][]string{{name: {}}}
+}
+
+// Document returns the document built so far
+func (s *Spec) Document() *Document {
+	return &s.doc
+}
+
+// Routes lists the documented operations as "METHOD /path", sorted
+func (d *Document) Routes() []string {
+	var routes []string
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '.' || r == '_' }) {
+		if strings.HasPrefix(part, "{") {
+			part = strings.Trim(part, "{}")
+			part = "By" + strings.ToUpper(part[:1]) + part[1:]
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// JSONBody is a required application/json request body
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+// JSON is a response with an application/json body; a nil schema means no body
+func JSON(description string, schema *Schema) *Response {
+	r := &Response{Description: description}
+	if schema != nil {
+		r.Content = map[string]MediaType{"application/json": {Schema: schema}}
+	}
+	return r
+}
+
+// Body is a response with a body of the given content type
+func Body(description, contentType string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{contentType: {Schema: schema}}}
+}
+
+// Responses builds a response map keyed by status code
+func Responses(byStatus map[int]*Response) map[string]*Response {
+	out := make(map[string]*Response, len(byStatus))
+	for status, r := range byStatus {
+		out[strconv.Itoa(status)] = r
+	}
+	return out
+}
+
+// PathParam is a required path parameter
+func PathParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: schema}
+}
+
+// QueryParam is an optional query parameter
+func QueryParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// HeaderParam is an optional request header
+func HeaderParam(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: schema}
+}
@@ -0,0 +1,129 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type author struct {
+	Name string `json:"name"`
+}
+
+type createRequest struct {
+	Username string    `json:"username" validate:"required,min=3,max=50"`
+	Tags     []string  `json:"tags" validate:"max=5"`
+	Format   string    `json:"format,omitempty" validate:"omitempty,oneof=plain markdown"`
+	Count    int       `json:"count" binding:"min=1"`
+	Author   *author   `json:"author"`
+	SentAt   time.Time `json:"sent_at"`
+	Secret   string    `json:"-"`
+	internal string
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	spec := New("Test", "1.0.0", "")
+	ref := spec.Schema(createRequest{})
+	if ref.Ref != "#/components/schemas/createRequest" {
+		t.Fatalf("Expected a reference to createRequest, got %+v", ref)
+	}
+	schema := spec.Document().Components.Schemas["createRequest"]
+	if schema == nil {
+		t.Fatal("Expected createRequest to be registered")
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"username"}) {
+		t.Errorf("Expected only username to be required, got %v", schema.Required)
+	}
+	username := schema.Properties["username"]
+	if username.Type != "string" || *username.MinLength != 3 || *username.MaxLength != 50 {
+		t.Errorf("Unexpected username schema: %+v", username)
+	}
+	if tags := schema.Properties["tags"]; tags.Type != "array" || *tags.MaxItems != 5 || tags.Items.Type != "string" {
+		t.Errorf("Unexpected tags schema: %+v", tags)
+	}
+	if format := schema.Properties["format"]; !reflect.DeepEqual(format.Enum, []interface{}{"plain", "markdown"}) {
+		t.Errorf("Expected format enum, got %v", format.Enum)
+	}
+	if count := schema.Properties["count"]; count.Minimum == nil || *count.Minimum != 1 {
+		t.Errorf("Expected binding min on count, got %+v", count)
+	}
+	if a := schema.Properties["author"]; a.Ref != "#/components/schemas/author" {
+		t.Errorf("Expected author reference, got %+v", a)
+	}
+	if sentAt := schema.Properties["sent_at"]; sentAt.Format != "date-time" {
+		t.Errorf("Expected date-time, got %+v", sentAt)
+	}
+	for _, name := range []string{"Secret", "-", "internal"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	cases := map[string]string{
+		"/api/messages/{id:[0-9]+}":    "/api/messages/{id}",
+		"/api/messages/{id}/revisions": "/api/messages/{id}/revisions",
+		"/api/v1/users/:id":            "/api/v1/users/{id}",
+		"/files/*path":                 "/files/{path}",
+		"/health":                      "/health",
+	}
+	for in, want := range cases {
+		if got := NormalizePath(in); got != want {
+			t.Errorf("NormalizePath(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestDiffAndRoutes(t *testing.T) {
+	spec := New("Test", "1.0.0", "")
+	spec.Add("GET", "/health", Operation{})
+	spec.Add("post", "/items/{id}", Operation{})
+	routes := spec.Document().Routes()
+	if !reflect.DeepEqual(routes, []string{"GET /health", "POST /items/{id}"}) {
+		t.Fatalf("Unexpected routes: %v", routes)
+	}
+	if op := spec.Document().Paths["/items/{id}"]["post"]; op.OperationID != "postItemsById" {
+		t.Errorf("Expected generated operation ID, got %q", op.OperationID)
+	}
+
+	undocumented, unserved := Diff([]string{"GET /health", "DELETE /items/{id}"}, routes)
+	if !reflect.DeepEqual(undocumented, []string{"DELETE /items/{id}"}) {
+		t.Errorf("Unexpected undocumented routes: %v", undocumented)
+	}
+	if !reflect.DeepEqual(unserved, []string{"POST /items/{id}"}) {
+		t.Errorf("Unexpected unserved routes: %v", unserved)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	spec := New("Test", "1.0.0", "")
+	spec.Add("GET", "/health", Operation{Responses: Responses(map[int]*Response{200: JSON("OK", String())})})
+
+	rec := httptest.NewRecorder()
+	Handler(spec.Document()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON, got %q", rec.Header().Get("Content-Type"))
+	}
+	var doc Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if doc.OpenAPI != Version || doc.Paths["/health"]["get"].Responses["200"] == nil {
+		t.Errorf("Unexpected document: %+v", doc)
+	}
+
+	rec = httptest.NewRecorder()
+	DocsHandler("/openapi.json").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `"/openapi.json"`) || strings.Contains(body, "{{SPEC_URL}}") {
+		t.Error("Expected the docs page to point at the spec")
+	}
+	if strings.Contains(body, "<script src=") || strings.Contains(body, "<link") {
+		t.Error("Expected the docs page to be self-contained")
+	}
+}
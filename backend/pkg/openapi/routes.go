@@ -0,0 +1,47 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// NormalizePath converts router path templates to OpenAPI form:
+// gorilla/mux "{id:[0-9]+}" and gin ":id" or "*path" all become "{id}" / "{path}".
+func NormalizePath(template string) string {
+	parts := strings.Split(template, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "{"):
+			name, _, _ := strings.Cut(strings.Trim(part, "{}"), ":")
+			parts[i] = "{" + name + "}"
+		case strings.HasPrefix(part, ":"), strings.HasPrefix(part, "*"):
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Diff compares the routes a router serves with the documented ones,
+// both as "METHOD /path". It returns routes missing from the document
+// and documented routes that are not served.
+func Diff(served, documented []string) (undocumented, unserved []string) {
+	inDoc := make(map[string]bool, len(documented))
+	for _, r := range documented {
+		inDoc[r] = true
+	}
+	inRouter := make(map[string]bool, len(served))
+	for _, r := range served {
+		inRouter[r] = true
+		if !inDoc[r] {
+			undocumented = append(undocumented, r)
+		}
+	}
+	for _, r := range documented {
+		if !inRouter[r] {
+			unserved = append(unserved, r)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unserved)
+	return undocumented, unserved
+}
@@ -0,0 +1,210 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// String returns a string schema
+func String() *Schema { return &Schema{Type: "string"} }
+
+// Integer returns an integer schema
+func Integer() *Schema { return &Schema{Type: "integer"} }
+
+// Binary returns the schema of a file or other raw body
+func Binary() *Schema { return &Schema{Type: "string", Format: "binary"} }
+
+// ArrayOf returns an array schema with the given items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Extend returns base with extra properties, e.g. to fill in the
+// payload of a generic response envelope
+func Extend(base *Schema, properties map[string]*Schema) *Schema {
+	return &Schema{AllOf: []*Schema{base, {Type: "object", Properties: properties}}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Schema returns the schema of v's type. Named structs are added to the
+// components and referenced, so each is described once.
+//
+// Field names come from `json` tags. Rules in `validate` tags, or gin's
+// `binding` tags, become constraints: required, min, max and oneof.
+func (s *Spec) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return s.schemaOf(reflect.TypeOf(v))
+}
+
+func (s *Spec) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(s.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.ref(t)
+	}
+	// Interfaces and anything else accept any value
+	return &Schema{}
+}
+
+// ref registers the named struct t as a component and returns a reference to it
+func (s *Spec) ref(t reflect.Type) *Schema {
+	name, ok := s.types[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.doc.Components.Schemas[name]; taken {
+			// Same name in another package
+			pkg := path.Base(t.PkgPath())
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		s.types[t] = name
+		// Reserve the name first so recursive types terminate
+		s.doc.Components.Schemas[name] = &Schema{}
+		*s.doc.Components.Schemas[name] = *s.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *Spec) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.addFields(schema, t)
+	return schema
+}
+
+func (s *Spec) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := s.schemaOf(field.Type)
+		rules := field.Tag.Get("validate")
+		if rules == "" {
+			rules = field.Tag.Get("binding")
+		}
+		if applyRules(prop, rules) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// applyRules turns validate rules into constraints on prop and
+// reports whether the field is required
+func applyRules(prop *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch key {
+		case "required":
+			required = true
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil || prop.Ref != "" {
+				continue
+			}
+			setBound(prop, key == "min", n)
+		case "oneof":
+			for _, option := range strings.Fields(arg) {
+				prop.Enum = append(prop.Enum, option)
+			}
+		}
+	}
+	return required
+}
+
+func setBound(prop *Schema, isMin bool, n float64) {
+	count := int(n)
+	switch prop.Type {
+	case "string":
+		if isMin {
+			prop.MinLength = &count
+		} else {
+			prop.MaxLength = &count
+		}
+	case "array":
+		if isMin {
+			prop.MinItems = &count
+		} else {
+			prop.MaxItems = &count
+		}
+	case "integer", "number":
+		if isMin {
+			prop.Minimum = &n
+		} else {
+			prop.Maximum = &n
+		}
+	}
+}
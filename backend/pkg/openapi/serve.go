@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// Handler serves doc as JSON, e.g. at /openapi.json
+func Handler(doc *Document) http.Handler {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "failed to encode OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// DocsHandler serves a self-contained documentation page for the
+// document at specURL. The page needs no resources from other sites.
+func DocsHandler(specURL string) http.Handler {
+	page := strings.Replace(docsPage, "{{SPEC_URL}}", html.EscapeString(specURL), 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	})
+}
@@ -0,0 +1,281 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AuditAction describes what happened to a user
+type AuditAction string
+
+const (
+	ActionCreate AuditAction = "create"
+	ActionUpdate AuditAction = "update"
+	ActionDelete AuditAction = "delete"
+)
+
+// SystemActor is recorded when the context carries no actor
+const SystemActor = "system"
+
+// FieldChange is the before and after value of one user field
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditRecord describes one change to one user
+type AuditRecord struct {
+	Action  AuditAction   `json:"action"`
+	UserID  string        `json:"user_id"`
+	Actor   string        `json:"actor"`
+	Time    time.Time     `json:"time"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Changed reports whether the record touches the given field
+func (r AuditRecord) Changed(field string) bool {
+	for _, c := range r.Changes {
+		if c.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditQuery filters audit records. Zero values match everything.
+// Since is inclusive, Until is exclusive.
+type AuditQuery struct {
+	UserID string
+	Field  string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Matches reports whether the record passes the filter
+func (q AuditQuery) Matches(r AuditRecord) bool {
+	if q.UserID != "" && r.UserID != q.UserID {
+		return false
+	}
+	if q.Field != "" && !r.Changed(q.Field) {
+		return false
+	}
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditSink stores audit records.
+// Write must store either all records or none of them.
+type AuditSink interface {
+	Write(records ...AuditRecord) error
+	Query(q AuditQuery) ([]AuditRecord, error)
+}
+
+// DefaultAuditCapacity is how many records a MemoryAuditSink keeps unless told otherwise
+const DefaultAuditCapacity = 10000
+
+// MemoryAuditSink keeps the latest audit records in memory. Once it holds
+// its capacity, each new record replaces the oldest one.
+type MemoryAuditSink struct {
+	records  []AuditRecord // Ring buffer, oldest record at start once full
+	start    int
+	capacity int
+	mutex    sync.RWMutex
+}
+
+// NewMemoryAuditSink creates an empty in-memory sink keeping at most
+// capacity records, or DefaultAuditCapacity if capacity is not positive
+func NewMemoryAuditSink(capacity int) *MemoryAuditSink {
+	if capacity <= 0 {
+		capacity = DefaultAuditCapacity
+	}
+	return &MemoryAuditSink{capacity: capacity}
+}
+
+// Write appends records, dropping the oldest ones beyond the capacity
+func (s *MemoryAuditSink) Write(records ...AuditRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, r := range records {
+		if len(s.records) < s.capacity {
+			s.records = append(s.records, r)
+			continue
+		}
+		s.records[s.start] = r
+		s.start = (s.start + 1) % s.capacity
+	}
+	return nil
+}
+
+// Query returns matching records ordered by time
+func (s *MemoryAuditSink) Query(q AuditQuery) ([]AuditRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var out []AuditRecord
+	for i := range s.records {
+		r := s.records[(s.start+i)%len(s.records)]
+		if q.Matches(r) {
+			out = append(out, r)
+		}
+	}
+	sortRecords(out)
+	return out, nil
+}
+
+// FileAuditSink appends audit records to a JSON-lines file
+type FileAuditSink struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileAuditSink creates a sink writing to path. The file is created on first write.
+func NewFileAuditSink(path string) *FileAuditSink {
+	return &FileAuditSink{path: path}
+}
+
+// Write appends one JSON line per record in a single write
+func (s *FileAuditSink) Write(records ...AuditRecord) error {
+	var buf []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Query scans the file and returns matching records ordered by time
+func (s *FileAuditSink) Query(q AuditQuery) ([]AuditRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if q.Matches(r) {
+			out = append(out, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sortRecords(out)
+	return out, nil
+}
+
+func sortRecords(records []AuditRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+}
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes user changes to actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor
+func ActorFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok && actor != ""
+}
+
+// diffUsers lists the fields that differ between before and after
+func diffUsers(before, after User) []FieldChange {
+	var changes []FieldChange
+	add := func(field, b, a string) {
+		if b != a {
+			changes = append(changes, FieldChange{Field: field, Before: b, After: a})
+		}
+	}
+	add("id", before.ID, after.ID)
+	add("name", before.Name, after.Name)
+	add("email", before.Email, after.Email)
+	return changes
+}
+
+// newRecord builds a record for a change made through m
+func (m *UserManager) newRecord(action AuditAction, before, after User) AuditRecord {
+	actor, ok := ActorFromContext(m.ctx)
+	if !ok {
+		actor = SystemActor
+	}
+	id := after.ID
+	if id == "" {
+		id = before.ID
+	}
+	return AuditRecord{
+		Action:  action,
+		UserID:  id,
+		Actor:   actor,
+		Time:    m.now(),
+		Changes: diffUsers(before, after),
+	}
+}
+
+// putRecord builds the create or update record for storing u. Caller must hold the lock.
+func (m *UserManager) putRecord(u User) AuditRecord {
+	if old, exists := m.users[u.ID]; exists {
+		return m.newRecord(ActionUpdate, old, u)
+	}
+	return m.newRecord(ActionCreate, User{}, u)
+}
+
+// recordLocked writes records that change something. Caller must hold the write lock.
+func (m *UserManager) recordLocked(records ...AuditRecord) error {
+	if m.audit == nil {
+		return nil
+	}
+	changed := records[:0:0]
+	for _, r := range records {
+		if len(r.Changes) > 0 {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return m.audit.Write(changed...)
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditRecordsChanges(t *testing.T) {
+	mgr := NewUserManager()
+	admin := mgr.WithContext(WithActor(context.Background(), "admin"))
+
+	mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"})
+	if err := admin.UpdateUser(User{Name: "Bob", Email: "robert@example.com", ID: "bob"}); err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	// An update that changes nothing is not recorded
+	admin.AddUser(User{Name: "Bob", Email: "robert@example.com", ID: "bob"})
+	if err := admin.RemoveUser("bob"); err != nil {
+		t.Fatalf("RemoveUser failed: %v", err)
+	}
+
+	records, err := mgr.AuditLog().Query(AuditQuery{UserID: "bob"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d: %v", len(records), records)
+	}
+	if records[0].Action != ActionCreate || records[0].Actor != SystemActor {
+		t.Errorf("unexpected create record: %+v", records[0])
+	}
+	want := FieldChange{Field: "email", Before: "bob@example.com", After: "robert@example.com"}
+	if records[1].Action != ActionUpdate || records[1].Actor != "admin" ||
+		len(records[1].Changes) != 1 || records[1].Changes[0] != want {
+		t.Errorf("unexpected update record: %+v", records[1])
+	}
+	if records[2].Action != ActionDelete || records[2].Actor != "admin" {
+		t.Errorf("unexpected delete record: %+v", records[2])
+	}
+}
+
+func TestUpdateUserMissing(t *testing.T) {
+	mgr := NewUserManager()
+	if err := mgr.UpdateUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"}); err != ErrNoUserWithID {
+		t.Errorf("expected ErrNoUserWithID, got %v", err)
+	}
+}
+
+func TestAuditQueryByFieldAndTime(t *testing.T) {
+	sink := NewFileAuditSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	mgr := NewUserManager()
+	mgr.SetAuditSink(sink)
+
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	clock := start
+	mgr.now = func() time.Time { return clock }
+
+	mgr.AddUser(User{Name: "Ann", Email: "ann@example.com", ID: "ann"})
+	clock = start.Add(time.Hour)
+	mgr.WithContext(WithActor(context.Background(), "alice")).
+		UpdateUser(User{Name: "Ann", Email: "ann2@example.com", ID: "ann"})
+	clock = start.Add(2 * time.Hour)
+	mgr.UpdateUser(User{Name: "Anna", Email: "ann2@example.com", ID: "ann"})
+
+	records, err := sink.Query(AuditQuery{UserID: "ann", Field: "email", Since: start.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Actor != "alice" {
+		t.Fatalf("expected one email change by alice, got %+v", records)
+	}
+
+	records, _ = sink.Query(AuditQuery{Until: start.Add(2 * time.Hour)})
+	if len(records) != 2 {
+		t.Errorf("expected 2 records before until, got %d", len(records))
+	}
+}
+
+func TestFileAuditSinkMissingFile(t *testing.T) {
+	sink := NewFileAuditSink(filepath.Join(t.TempDir(), "missing.jsonl"))
+	records, err := sink.Query(AuditQuery{})
+	if err != nil || len(records) != 0 {
+		t.Errorf("expected no records and no error, got %v, %v", records, err)
+	}
+}
+
+func TestMemoryAuditSinkCapacity(t *testing.T) {
+	sink := NewMemoryAuditSink(3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		r := AuditRecord{Action: ActionCreate, UserID: string(rune('a' + i)), Time: start.Add(time.Duration(i) * time.Second)}
+		if err := sink.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	records, err := sink.Query(AuditQuery{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var ids string
+	for _, r := range records {
+		ids += r.UserID
+	}
+	if ids != "cde" {
+		t.Errorf("expected the 3 latest records cde, got %q", ids)
+	}
+	if NewMemoryAuditSink(0).capacity != DefaultAuditCapacity {
+		t.Errorf("expected capacity 0 to mean %d", DefaultAuditCapacity)
+	}
+}
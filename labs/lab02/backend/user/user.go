@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"regexp"
+	"sort"
 	"sync"
+	"time"
 )
 
 // User represents a chat user
@@ -17,10 +19,14 @@ type User struct {
 }
 
 var (
-	ErrInvalidName  = errors.New("invalid name: must be between 1 and 30 characters")
-	ErrInvalidEmail = errors.New("invalid email format")
-	ErrInvalidID    = errors.New("invalid id: must be between 1 and 30 characters")
-	ErrNoUserWithID = errors.New("no user with given id")
+	ErrInvalidName        = errors.New("invalid name: must be between 1 and 30 characters")
+	ErrInvalidEmail       = errors.New("invalid email format")
+	ErrInvalidID          = errors.New("invalid id: must be between 1 and 30 characters")
+	ErrNoUserWithID       = errors.New("no user with given id")
+	ErrNoUserWithEmail    = errors.New("no user with given email")
+	ErrEmailTaken         = errors.New("email is already used by another user")
+	ErrDuplicateID        = errors.New("duplicate id in batch")
+	ErrInvalidListOptions = errors.New("invalid list options")
 )
 
 // IsValidEmail checks if the email format is valid
@@ -58,45 +64,59 @@ func (u *User) Validate() error {
 }
 
 // UserManager manages users
-// Contains a map of users, a mutex, and a context
+// Contains the shared user state and the context the manager is bound to
 
 type UserManager struct {
-	ctx   context.Context
-	users map[string]User // userID -> User
-	mutex sync.RWMutex    // Protects users map
-	// TODO: Add more fields if needed
+	ctx context.Context
+	*userState
+}
+
+// userState is shared by every view of a UserManager returned by WithContext
+type userState struct {
+	users  map[string]User   // userID -> User
+	emails map[string]string // email -> userID
+	audit  AuditSink         // Receives a record for every change
+	now    func() time.Time
+	mutex  sync.RWMutex // Protects users and emails maps
+}
+
+func newUserState() *userState {
+	return &userState{
+		users:  make(map[string]User),
+		emails: make(map[string]string),
+		audit:  NewMemoryAuditSink(DefaultAuditCapacity),
+		now:    time.Now,
+	}
 }
 
 // NewUserManager creates a new UserManager
 func NewUserManager() *UserManager {
-	// TODO: Initialize UserManager fields
-	return &UserManager{
-		users: make(map[string]User),
-	}
+	return &UserManager{userState: newUserState()}
 }
 
 // NewUserManagerWithContext creates a new UserManager with context
 func NewUserManagerWithContext(ctx context.Context) *UserManager {
-	// TODO: Initialize UserManager with context
-	return &UserManager{
-		ctx:   ctx,
-		users: make(map[string]User),
-	}
+	return &UserManager{ctx: ctx, userState: newUserState()}
 }
 
-// AddUser adds a user
-func (m *UserManager) AddUser(u User) error {
-	if err := m.inContext(); err != nil {
-		return err
-	}
-	err := u.Validate()
-	if err != nil {
-		return err
-	}
+// WithContext returns a manager bound to ctx that shares users with m.
+// Use it to attach a request context carrying the actor (see WithActor).
+func (m *UserManager) WithContext(ctx context.Context) *UserManager {
+	return &UserManager{ctx: ctx, userState: m.userState}
+}
+
+// SetAuditSink replaces the sink that receives audit records
+func (m *UserManager) SetAuditSink(sink AuditSink) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	m.users[u.ID] = u
-	return nil
+	m.audit = sink
+}
+
+// AuditLog returns the sink that receives audit records
+func (m *UserManager) AuditLog() AuditSink {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.audit
 }
 
 // Checks if context is existed and not done
@@ -112,23 +132,260 @@ func (m *UserManager) inContext() error {
 	}
 }
 
+// AddUser adds a user, replacing any user with the same id.
+// Returns ErrEmailTaken if the email belongs to a different user.
+func (m *UserManager) AddUser(u User) error {
+	if err := m.inContext(); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if owner, ok := m.emails[u.Email]; ok && owner != u.ID {
+		return ErrEmailTaken
+	}
+	if err := m.recordLocked(m.putRecord(u)); err != nil {
+		return err
+	}
+	m.putLocked(u)
+	return nil
+}
+
+// UpdateUser replaces the profile of an existing user.
+// Returns ErrNoUserWithID if the user does not exist.
+func (m *UserManager) UpdateUser(u User) error {
+	if err := m.inContext(); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, exists := m.users[u.ID]; !exists {
+		return ErrNoUserWithID
+	}
+	if owner, ok := m.emails[u.Email]; ok && owner != u.ID {
+		return ErrEmailTaken
+	}
+	if err := m.recordLocked(m.putRecord(u)); err != nil {
+		return err
+	}
+	m.putLocked(u)
+	return nil
+}
+
+// AddUsers adds all users or none of them.
+// The batch is checked as a whole before anything is stored.
+func (m *UserManager) AddUsers(users []User) error {
+	if err := m.inContext(); err != nil {
+		return err
+	}
+	for i := range users {
+		if err := users[i].Validate(); err != nil {
+			return err
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	seenIDs := make(map[string]bool, len(users))
+	// email -> owner as it would be after applying the batch
+	staged := make(map[string]string, len(users))
+	owner := func(email string) (string, bool) {
+		if id, ok := staged[email]; ok {
+			return id, id != ""
+		}
+		id, ok := m.emails[email]
+		return id, ok
+	}
+	for _, u := range users {
+		if seenIDs[u.ID] {
+			return ErrDuplicateID
+		}
+		seenIDs[u.ID] = true
+		if id, ok := owner(u.Email); ok && id != u.ID {
+			return ErrEmailTaken
+		}
+		if old, ok := m.users[u.ID]; ok && old.Email != u.Email {
+			staged[old.Email] = ""
+		}
+		staged[u.Email] = u.ID
+	}
+
+	records := make([]AuditRecord, 0, len(users))
+	for _, u := range users {
+		records = append(records, m.putRecord(u))
+	}
+	if err := m.recordLocked(records...); err != nil {
+		return err
+	}
+	for _, u := range users {
+		m.putLocked(u)
+	}
+	return nil
+}
+
+// putLocked stores u and keeps the email index in sync. Caller must hold the write lock.
+func (m *UserManager) putLocked(u User) {
+	if old, ok := m.users[u.ID]; ok && m.emails[old.Email] == u.ID {
+		delete(m.emails, old.Email)
+	}
+	m.users[u.ID] = u
+	m.emails[u.Email] = u.ID
+}
+
 // RemoveUser removes a user
 func (m *UserManager) RemoveUser(id string) error {
+	if err := m.inContext(); err != nil {
+		return err
+	}
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
-	_, exists := m.users[id]
+	usr, exists := m.users[id]
 	if !exists {
 		return ErrNoUserWithID
 	}
-	delete(m.users, id)
+	if err := m.recordLocked(m.newRecord(ActionDelete, usr, User{})); err != nil {
+		return err
+	}
+	m.deleteLocked(id)
 	return nil
 }
 
+// RemoveUsers removes all given users or none of them.
+// Returns ErrNoUserWithID if any id is unknown.
+func (m *UserManager) RemoveUsers(ids []string) error {
+	if err := m.inContext(); err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	records := make([]AuditRecord, 0, len(ids))
+	for _, id := range ids {
+		usr, exists := m.users[id]
+		if !exists {
+			return ErrNoUserWithID
+		}
+		records = append(records, m.newRecord(ActionDelete, usr, User{}))
+	}
+	if err := m.recordLocked(records...); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		m.deleteLocked(id)
+	}
+	return nil
+}
+
+// deleteLocked removes the user and its email index entry. Caller must hold the write lock.
+func (m *UserManager) deleteLocked(id string) {
+	if usr, ok := m.users[id]; ok {
+		delete(m.emails, usr.Email)
+		delete(m.users, id)
+	}
+}
+
 // GetUser retrieves a user by id
 func (m *UserManager) GetUser(id string) (User, error) {
+	if err := m.inContext(); err != nil {
+		return User{}, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 	usr, exists := m.users[id]
 	if !exists {
 		return usr, ErrNoUserWithID
 	}
 	return usr, nil
 }
+
+// GetUserByEmail retrieves a user by email
+func (m *UserManager) GetUserByEmail(email string) (User, error) {
+	if err := m.inContext(); err != nil {
+		return User{}, err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	id, exists := m.emails[email]
+	if !exists {
+		return User{}, ErrNoUserWithEmail
+	}
+	return m.users[id], nil
+}
+
+// SortField selects the field ListUsers sorts by
+type SortField string
+
+const (
+	SortByID    SortField = "id"
+	SortByName  SortField = "name"
+	SortByEmail SortField = "email"
+)
+
+// ListOptions controls paging and ordering of ListUsers.
+// A zero Limit means no limit; an empty SortBy sorts by id.
+type ListOptions struct {
+	Offset int
+	Limit  int
+	SortBy SortField
+	Desc   bool
+}
+
+// ListUsers returns one page of users and the total number of users
+func (m *UserManager) ListUsers(opts ListOptions) ([]User, int, error) {
+	if err := m.inContext(); err != nil {
+		return nil, 0, err
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return nil, 0, ErrInvalidListOptions
+	}
+	key, err := sortKey(opts.SortBy)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mutex.RLock()
+	all := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		all = append(all, u)
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		a, b := key(all[i]), key(all[j])
+		if a == b {
+			// Ties are broken by id so pages are stable
+			a, b = all[i].ID, all[j].ID
+		}
+		if opts.Desc {
+			return a > b
+		}
+		return a < b
+	})
+
+	total := len(all)
+	if opts.Offset >= total {
+		return []User{}, total, nil
+	}
+	end := total
+	if opts.Limit > 0 && opts.Offset+opts.Limit < total {
+		end = opts.Offset + opts.Limit
+	}
+	return all[opts.Offset:end], total, nil
+}
+
+func sortKey(field SortField) (func(User) string, error) {
+	switch field {
+	case "", SortByID:
+		return func(u User) string { return u.ID }, nil
+	case SortByName:
+		return func(u User) string { return u.Name }, nil
+	case SortByEmail:
+		return func(u User) string { return u.Email }, nil
+	default:
+		return nil, ErrInvalidListOptions
+	}
+}
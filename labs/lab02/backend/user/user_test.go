@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -56,3 +58,150 @@ func TestUserContextCancellation(t *testing.T) {
 		t.Error("expected error after context cancel, got nil")
 	}
 }
+
+func TestUserContextCancellationAllMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr := NewUserManagerWithContext(ctx)
+	if err := mgr.AddUser(User{Name: "Eve", Email: "eve@example.com", ID: "eve"}); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	cancel()
+	if _, err := mgr.GetUser("eve"); err == nil {
+		t.Error("GetUser: expected error after context cancel, got nil")
+	}
+	if _, err := mgr.GetUserByEmail("eve@example.com"); err == nil {
+		t.Error("GetUserByEmail: expected error after context cancel, got nil")
+	}
+	if err := mgr.RemoveUser("eve"); err == nil {
+		t.Error("RemoveUser: expected error after context cancel, got nil")
+	}
+	if _, _, err := mgr.ListUsers(ListOptions{}); err == nil {
+		t.Error("ListUsers: expected error after context cancel, got nil")
+	}
+}
+
+func TestUserEmailIndex(t *testing.T) {
+	mgr := NewUserManager()
+	if err := mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"}); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	if err := mgr.AddUser(User{Name: "Rob", Email: "bob@example.com", ID: "rob"}); err != ErrEmailTaken {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+	if u, err := mgr.GetUserByEmail("bob@example.com"); err != nil || u.ID != "bob" {
+		t.Errorf("GetUserByEmail = %v, %v", u, err)
+	}
+
+	// Changing the email frees the old one
+	if err := mgr.AddUser(User{Name: "Bob", Email: "robert@example.com", ID: "bob"}); err != nil {
+		t.Fatalf("AddUser update failed: %v", err)
+	}
+	if _, err := mgr.GetUserByEmail("bob@example.com"); err != ErrNoUserWithEmail {
+		t.Errorf("expected ErrNoUserWithEmail, got %v", err)
+	}
+	if err := mgr.AddUser(User{Name: "Rob", Email: "bob@example.com", ID: "rob"}); err != nil {
+		t.Errorf("AddUser with freed email failed: %v", err)
+	}
+
+	if err := mgr.RemoveUser("rob"); err != nil {
+		t.Fatalf("RemoveUser failed: %v", err)
+	}
+	if _, err := mgr.GetUserByEmail("bob@example.com"); err != ErrNoUserWithEmail {
+		t.Errorf("expected ErrNoUserWithEmail after remove, got %v", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	mgr := NewUserManager()
+	users := []User{
+		{Name: "Carol", Email: "carol@example.com", ID: "3"},
+		{Name: "Alice", Email: "alice@example.com", ID: "2"},
+		{Name: "Bob", Email: "bob@example.com", ID: "1"},
+	}
+	if err := mgr.AddUsers(users); err != nil {
+		t.Fatalf("AddUsers failed: %v", err)
+	}
+
+	page, total, err := mgr.ListUsers(ListOptions{SortBy: SortByName, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListUsers failed: %v", err)
+	}
+	if total != 3 || len(page) != 2 || page[0].Name != "Alice" || page[1].Name != "Bob" {
+		t.Errorf("unexpected first page: %v (total %d)", page, total)
+	}
+
+	page, _, _ = mgr.ListUsers(ListOptions{SortBy: SortByName, Offset: 2, Limit: 2})
+	if len(page) != 1 || page[0].Name != "Carol" {
+		t.Errorf("unexpected second page: %v", page)
+	}
+
+	page, _, _ = mgr.ListUsers(ListOptions{Desc: true})
+	if len(page) != 3 || page[0].ID != "3" || page[2].ID != "1" {
+		t.Errorf("unexpected descending order: %v", page)
+	}
+
+	if _, _, err := mgr.ListUsers(ListOptions{SortBy: "age"}); err != ErrInvalidListOptions {
+		t.Errorf("expected ErrInvalidListOptions, got %v", err)
+	}
+}
+
+func TestBulkAddRemoveAtomic(t *testing.T) {
+	mgr := NewUserManager()
+	mgr.AddUser(User{Name: "Bob", Email: "bob@example.com", ID: "bob"})
+
+	err := mgr.AddUsers([]User{
+		{Name: "Ann", Email: "ann@example.com", ID: "ann"},
+		{Name: "Rob", Email: "bob@example.com", ID: "rob"},
+	})
+	if err != ErrEmailTaken {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+	if _, err := mgr.GetUser("ann"); err == nil {
+		t.Error("failed batch must not add any user")
+	}
+
+	// Swapping emails inside one batch is fine
+	mgr.AddUser(User{Name: "Ann", Email: "ann@example.com", ID: "ann"})
+	err = mgr.AddUsers([]User{
+		{Name: "Ann", Email: "ann2@example.com", ID: "ann"},
+		{Name: "Bob", Email: "ann@example.com", ID: "bob"},
+	})
+	if err != nil {
+		t.Errorf("AddUsers failed: %v", err)
+	}
+
+	if err := mgr.RemoveUsers([]string{"ann", "missing"}); err != ErrNoUserWithID {
+		t.Errorf("expected ErrNoUserWithID, got %v", err)
+	}
+	if _, err := mgr.GetUser("ann"); err != nil {
+		t.Error("failed batch must not remove any user")
+	}
+	if err := mgr.RemoveUsers([]string{"ann", "bob"}); err != nil {
+		t.Errorf("RemoveUsers failed: %v", err)
+	}
+	if _, total, _ := mgr.ListUsers(ListOptions{}); total != 0 {
+		t.Errorf("expected no users, got %d", total)
+	}
+}
+
+func TestUserManagerConcurrent(t *testing.T) {
+	mgr := NewUserManager()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		id := fmt.Sprintf("u%d", i)
+		go func() {
+			defer wg.Done()
+			mgr.AddUser(User{Name: "User", Email: id + "@example.com", ID: id})
+		}()
+		go func() {
+			defer wg.Done()
+			mgr.GetUser(id)
+			mgr.ListUsers(ListOptions{Limit: 10})
+		}()
+	}
+	wg.Wait()
+	if _, total, _ := mgr.ListUsers(ListOptions{}); total != 50 {
+		t.Errorf("expected 50 users, got %d", total)
+	}
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"lab03-backend/blob"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"lab03-backend/validation"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultMaxAttachmentSize is the largest upload accepted unless configured otherwise
+const DefaultMaxAttachmentSize = 10 << 20
+
+// DefaultAttachmentTypes are the media types accepted unless configured otherwise.
+// Types are detected from the file contents, not from what the client claims.
+var DefaultAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+}
+
+// multipartOverhead allows for the multipart headers around the file
+const multipartOverhead = 64 << 10
+
+// AttachmentConfig configures uploads to /api/attachments
+type AttachmentConfig struct {
+	// Blobs keeps the uploaded files and their thumbnails
+	Blobs blob.Store
+	// MaxSize is the largest accepted file in bytes; 0 means DefaultMaxAttachmentSize
+	MaxSize int64
+	// AllowedTypes lists the accepted media types; nil means DefaultAttachmentTypes
+	AllowedTypes []string
+}
+
+// UseAttachments enables file uploads and lets messages reference them
+func (h *Handler) UseAttachments(cfg AttachmentConfig) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxAttachmentSize
+	}
+	if cfg.AllowedTypes == nil {
+		cfg.AllowedTypes = DefaultAttachmentTypes
+	}
+	h.attachments = &cfg
+}
+
+func (cfg *AttachmentConfig) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range cfg.AllowedTypes {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadAttachment handles POST /api/attachments.
+// The file is sent in the "file" field of a multipart/form-data body.
+func (h *Handler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	cfg := h.attachments
+	if cfg == nil {
+		h.writeError(w, http.StatusNotFound, "Attachments are not enabled on this server")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxSize+multipartOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Request must be multipart/form-data")
+		return
+	}
+	part, err := filePart(mr)
+	if err != nil {
+		h.writeUploadError(w, cfg, err)
+		return
+	}
+	defer part.Close()
+
+	// Sniff the type from the first bytes instead of trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		h.writeUploadError(w, cfg, err)
+		return
+	}
+	if n == 0 {
+		h.writeValidationError(w, validation.Errors{{Field: "file", Code: validation.CodeRequired, Message: "must not be empty"}})
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !cfg.allows(contentType) {
+		h.writeError(w, http.StatusUnsupportedMediaType, "Files of type "+contentType+" are not allowed")
+		return
+	}
+
+	id, err := newAttachmentID()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+	body := io.LimitReader(io.MultiReader(bytes.NewReader(head), part), cfg.MaxSize+1)
+	size, err := cfg.Blobs.Put(id, body)
+	if err == nil && size > cfg.MaxSize {
+		err = &http.MaxBytesError{Limit: cfg.MaxSize}
+	}
+	if err != nil {
+		cfg.Blobs.Delete(id)
+		h.writeUploadError(w, cfg, err)
+		return
+	}
+
+	att := &models.Attachment{
+		ID:          id,
+		Filename:    cleanFilename(part.FileName()),
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	if claims := claimsFromContext(r.Context()); claims != nil {
+		att.UploaderID = claims.UserID
+	}
+	if thumbnailTypes[contentType] {
+		// A broken image is still a valid upload, it just has no thumbnail
+		if err := h.addThumbnail(cfg.Blobs, att); err != nil {
+			log.Printf("No thumbnail for attachment %s: %v", id, err)
+		}
+	}
+	if err := h.storage.CreateAttachment(att); err != nil {
+		cfg.Blobs.Delete(id)
+		cfg.Blobs.Delete(thumbnailKey(id))
+		h.writeError(w, http.StatusInternalServerError, "Failed to store attachment")
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: att})
+}
+
+// filePart returns the "file" part of a multipart body
+func filePart(mr *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errNoFilePart
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+var errNoFilePart = errors.New("no file part")
+
+// writeUploadError maps errors reading or storing an upload to responses
+func (h *Handler) writeUploadError(w http.ResponseWriter, cfg *AttachmentConfig, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errNoFilePart):
+		h.writeValidationError(w, validation.Errors{{Field: "file", Code: validation.CodeRequired, Message: "is required"}})
+	case errors.As(err, &maxErr):
+		h.writeError(w, http.StatusRequestEntityTooLarge,
+			"Files must not exceed "+strconv.FormatInt(cfg.MaxSize, 10)+" bytes")
+	default:
+		h.writeError(w, http.StatusBadRequest, "Failed to read upload: "+err.Error())
+	}
+}
+
+// addThumbnail records the dimensions of an image attachment and stores its thumbnail
+func (h *Handler) addThumbnail(blobs blob.Store, att *models.Attachment) error {
+	f, err := blobs.Open(att.ID)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	width, height, err := imageSize(f)
+	if err != nil {
+		return err
+	}
+	att.Width, att.Height = width, height
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	thumb, err := makeThumbnail(f, width, height)
+	if err != nil {
+		return err
+	}
+	if _, err := blobs.Put(thumbnailKey(att.ID), bytes.NewReader(thumb)); err != nil {
+		return err
+	}
+	att.Thumbnail = true
+	return nil
+}
+
+// DownloadAttachment handles GET /api/attachments/{id}.
+// Range and If-Range requests are supported.
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	att, ok := h.attachment(w, r)
+	if !ok {
+		return
+	}
+	disposition := "attachment"
+	if strings.HasPrefix(att.ContentType, "image/") {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+	h.serveBlob(w, r, att.ID, att.ContentType, att)
+}
+
+// GetAttachmentThumbnail handles GET /api/attachments/{id}/thumbnail
+func (h *Handler) GetAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	att, ok := h.attachment(w, r)
+	if !ok {
+		return
+	}
+	if !att.Thumbnail {
+		h.writeError(w, http.StatusNotFound, "Attachment has no thumbnail")
+		return
+	}
+	h.serveBlob(w, r, thumbnailKey(att.ID), "image/png", att)
+}
+
+// attachment looks up the attachment in the path and writes 404 if there is none
+func (h *Handler) attachment(w http.ResponseWriter, r *http.Request) (*models.Attachment, bool) {
+	if h.attachments == nil {
+		h.writeError(w, http.StatusNotFound, "Attachments are not enabled on this server")
+		return nil, false
+	}
+	att, err := h.storage.GetAttachment(mux.Vars(r)["id"])
+	if errors.Is(err, storage.ErrAttachmentNotFound) {
+		h.writeError(w, http.StatusNotFound, "Attachment not found")
+		return nil, false
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Storage error")
+		return nil, false
+	}
+	return att, true
+}
+
+// serveBlob writes the blob under key. Attachments never change, so they
+// are cached for long and their ID serves as the ETag.
+func (h *Handler) serveBlob(w http.ResponseWriter, r *http.Request, key, contentType string, att *models.Attachment) {
+	f, err := h.attachments.Blobs.Open(key)
+	if errors.Is(err, blob.ErrNotFound) {
+		h.writeError(w, http.StatusNotFound, "Attachment content is missing")
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to read attachment")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, "", att.CreatedAt, f)
+}
+
+// resolveAttachments checks the attachments a new message refers to.
+// With auth enabled only the uploader may attach a file.
+func (h *Handler) resolveAttachments(r *http.Request, ids []string) ([]models.Attachment, validation.Errors) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	fail := func(msg string) validation.Errors {
+		return validation.Errors{{Field: "attachment_ids", Code: validation.CodeInvalidValue, Message: msg}}
+	}
+	if h.attachments == nil {
+		return nil, fail("attachments are not enabled on this server")
+	}
+	claims := claimsFromContext(r.Context())
+	seen := make(map[string]bool, len(ids))
+	refs := make([]models.Attachment, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fail("lists " + id + " more than once")
+		}
+		seen[id] = true
+		att, err := h.storage.GetAttachment(id)
+		if err != nil {
+			return nil, fail("refers to unknown attachment " + id)
+		}
+		if claims != nil && att.UploaderID != claims.UserID {
+			return nil, fail("refers to attachment " + id + " uploaded by someone else")
+		}
+		refs = append(refs, models.Attachment{ID: id})
+	}
+	return refs, nil
+}
+
+// newAttachmentID returns a random 128-bit hex ID, so attachment URLs can't be guessed
+func newAttachmentID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func thumbnailKey(id string) string {
+	return id + ".thumb"
+}
+
+// cleanFilename keeps the base name the client sent, without any path
+func cleanFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"lab03-backend/blob"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"lab05/jwtservice"
+)
+
+func setupAttachmentHandler(t *testing.T, maxSize int64) *Handler {
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(storage.NewMemoryStorage())
+	handler.UseAttachments(AttachmentConfig{Blobs: blobs, MaxSize: maxSize})
+	return handler
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func uploadFile(router http.Handler, token, filename string, data []byte) (*httptest.ResponseRecorder, *models.Attachment) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("comment", "ignored")
+	fw, _ := mw.CreateFormFile("file", filename)
+	fw.Write(data)
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", "/api/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response struct {
+		Data *models.Attachment `json:"data"`
+	}
+	json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+	return rr, response.Data
+}
+
+func TestAttachmentUploadAndDownload(t *testing.T) {
+	router := setupAttachmentHandler(t, 0).SetupRoutes()
+	data := encodePNG(t, 600, 300)
+
+	rr, att := uploadFile(router, "", `C:\photos\cat.png`, data)
+	if rr.Code != http.StatusCreated || att == nil {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if att.Filename != "cat.png" || att.ContentType != "image/png" || att.Size != int64(len(data)) {
+		t.Errorf("Unexpected attachment: %+v", att)
+	}
+	if att.Width != 600 || att.Height != 300 || !att.Thumbnail {
+		t.Errorf("Expected 600x300 image with thumbnail, got %+v", att)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/attachments/"+att.ID, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !bytes.Equal(rr.Body.Bytes(), data) {
+		t.Errorf("Expected full download, got %d with %d bytes", rr.Code, rr.Body.Len())
+	}
+	if rr.Header().Get("Content-Type") != "image/png" || rr.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Unexpected headers: %v", rr.Header())
+	}
+	if !strings.Contains(rr.Header().Get("Content-Disposition"), `filename=cat.png`) {
+		t.Errorf("Unexpected Content-Disposition: %s", rr.Header().Get("Content-Disposition"))
+	}
+
+	req, _ = http.NewRequest("GET", "/api/attachments/"+att.ID, nil)
+	req.Header.Set("Range", "bytes=1-3")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "PNG" {
+		t.Errorf("Expected bytes 1-3, got %d %q", rr.Code, rr.Body.String())
+	}
+	if want := "bytes 1-3/" + strconv.Itoa(len(data)); rr.Header().Get("Content-Range") != want {
+		t.Errorf("Expected Content-Range %q, got %q", want, rr.Header().Get("Content-Range"))
+	}
+
+	req, _ = http.NewRequest("GET", "/api/attachments/"+att.ID+"/thumbnail", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	thumb, err := png.Decode(rr.Body)
+	if rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("Expected thumbnail, got %d: %v", rr.Code, err)
+	}
+	if b := thumb.Bounds(); b.Dx() != thumbnailSize || b.Dy() != thumbnailSize/2 {
+		t.Errorf("Expected %dx%d thumbnail, got %v", thumbnailSize, thumbnailSize/2, b)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/attachments/0123456789abcdef", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown attachment, got %d", rr.Code)
+	}
+}
+
+func TestAttachmentUploadChecks(t *testing.T) {
+	router := setupAttachmentHandler(t, 1024).SetupRoutes()
+
+	rr, att := uploadFile(router, "", "notes.txt", []byte("plain text"))
+	if rr.Code != http.StatusCreated || att.ContentType != "text/plain; charset=utf-8" || att.Thumbnail {
+		t.Errorf("Expected text upload without thumbnail, got %d %+v", rr.Code, att)
+	}
+	// The type comes from the contents, not the file name
+	if rr, _ := uploadFile(router, "", "innocent.png", []byte("<html><script>alert(1)</script></html>")); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for HTML, got %d", rr.Code)
+	}
+	if rr, _ := uploadFile(router, "", "big.txt", bytes.Repeat([]byte("a"), 1025)); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for oversized file, got %d", rr.Code)
+	}
+	if rr, _ := uploadFile(router, "", "empty.txt", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty file, got %d", rr.Code)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/attachments", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for non-multipart body, got %d", rr.Code)
+	}
+
+	// Disabled attachments
+	router = setupTestHandler().SetupRoutes()
+	if rr, _ := uploadFile(router, "", "notes.txt", []byte("plain text")); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when attachments are disabled, got %d", rr.Code)
+	}
+}
+
+func TestMessageAttachments(t *testing.T) {
+	router := setupAttachmentHandler(t, 0).SetupRoutes()
+	_, att := uploadFile(router, "", "notes.txt", []byte("plain text"))
+
+	rr, response := doAuthRequest(router, "POST", "/api/messages", "", map[string]interface{}{
+		"username":       "alice",
+		"content":        "see attached",
+		"attachment_ids": []string{att.ID},
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	data, _ := json.Marshal(response.Data)
+	var msg models.Message
+	json.Unmarshal(data, &msg)
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "notes.txt" {
+		t.Errorf("Expected message with notes.txt attached, got %+v", msg.Attachments)
+	}
+
+	for _, ids := range [][]string{{"missing"}, {att.ID, att.ID}} {
+		rr, response := doAuthRequest(router, "POST", "/api/messages", "", map[string]interface{}{
+			"username":       "alice",
+			"content":        "broken",
+			"attachment_ids": ids,
+		})
+		if rr.Code != http.StatusBadRequest || len(response.Errors) != 1 || response.Errors[0].Field != "attachment_ids" {
+			t.Errorf("%v: expected attachment_ids error, got %d %+v", ids, rr.Code, response)
+		}
+	}
+}
+
+func TestAttachmentOwnership(t *testing.T) {
+	handler := setupAttachmentHandler(t, 0)
+	tokens, _ := jwtservice.NewJWTService("test-secret")
+	handler.UseAuth(tokens)
+	router := handler.SetupRoutes()
+	alice, _ := tokens.GenerateToken(1, "alice@example.com")
+	bob, _ := tokens.GenerateToken(2, "bob@example.com")
+
+	if rr, _ := uploadFile(router, "", "notes.txt", []byte("plain text")); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for anonymous upload, got %d", rr.Code)
+	}
+	_, att := uploadFile(router, alice, "notes.txt", []byte("plain text"))
+	if att.UploaderID != 1 {
+		t.Errorf("Expected uploader 1, got %d", att.UploaderID)
+	}
+
+	create := map[string]interface{}{"content": "mine", "attachment_ids": []string{att.ID}}
+	if rr, _ := doAuthRequest(router, "POST", "/api/messages", bob, create); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 attaching someone else's file, got %d", rr.Code)
+	}
+	if rr, _ := doAuthRequest(router, "POST", "/api/messages", alice, create); rr.Code != http.StatusCreated {
+		t.Errorf("Expected 201 attaching own file, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"lab03-backend/models"
+	"net/http"
+	"strings"
+
+	"lab05/jwtservice"
+)
+
+// RoleAdmin may edit and delete any message
+const RoleAdmin = "admin"
+
+// TokenValidator checks bearer tokens. *jwtservice.JWTService implements it.
+type TokenValidator interface {
+	ValidateToken(token string) (*jwtservice.Claims, error)
+}
+
+type claimsKey struct{}
+
+// UseAuth requires a valid bearer token for creating, editing and deleting messages.
+// Without a validator the API stays open, as in development.
+func (h *Handler) UseAuth(tokens TokenValidator) {
+	h.tokens = tokens
+}
+
+// requireAuth rejects requests without a valid bearer token and
+// passes the token claims to next through the request context.
+func (h *Handler) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.tokens == nil {
+			next(w, r)
+			return
+		}
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.writeError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+		claims, err := h.tokens.ValidateToken(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			if errors.Is(err, jwtservice.ErrTokenExpired) {
+				h.writeError(w, http.StatusUnauthorized, "Token expired")
+			} else {
+				h.writeError(w, http.StatusUnauthorized, "Invalid token")
+			}
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// claimsFromContext returns the claims stored by requireAuth, nil when auth is off
+func claimsFromContext(ctx context.Context) *jwtservice.Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*jwtservice.Claims)
+	return claims
+}
+
+// canModify reports whether the caller may update or delete msg
+func canModify(claims *jwtservice.Claims, msg *models.Message) bool {
+	if claims == nil {
+		return true
+	}
+	return claims.Role == RoleAdmin || (msg.AuthorID != 0 && msg.AuthorID == claims.UserID)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lab05/jwtservice"
+)
+
+func setupAuthTestHandler(t *testing.T) (http.Handler, *jwtservice.JWTService) {
+	tokens, err := jwtservice.NewJWTService("test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(storage.NewMemoryStorage())
+	handler.UseAuth(tokens)
+	return handler.SetupRoutes(), tokens
+}
+
+func doAuthRequest(router http.Handler, method, path, token string, body interface{}) (*httptest.ResponseRecorder, models.APIResponse) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if method == "PUT" {
+		req.Header.Set("If-Match", "*")
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response models.APIResponse
+	json.NewDecoder(bytes.NewReader(rr.Body.Bytes())).Decode(&response)
+	return rr, response
+}
+
+func TestAuthRequiredForChanges(t *testing.T) {
+	router, _ := setupAuthTestHandler(t)
+	create := models.CreateMessageRequest{Username: "mallory", Content: "hi"}
+
+	rr, response := doAuthRequest(router, "POST", "/api/messages", "", create)
+	if rr.Code != http.StatusUnauthorized || response.Success || response.Error == "" {
+		t.Errorf("Expected 401 error envelope, got %d %+v", rr.Code, response)
+	}
+	if rr.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected WWW-Authenticate header")
+	}
+
+	rr, _ = doAuthRequest(router, "DELETE", "/api/messages/1", "not-a-token", nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %v, got %v", http.StatusUnauthorized, rr.Code)
+	}
+
+	// Reading stays public
+	rr, _ = doAuthRequest(router, "GET", "/api/messages", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+}
+
+func TestMessageOwnership(t *testing.T) {
+	router, tokens := setupAuthTestHandler(t)
+	alice, _ := tokens.GenerateToken(1, "alice@example.com")
+	bob, _ := tokens.GenerateToken(2, "bob@example.com")
+	admin, _ := tokens.GenerateTokenWithRole(3, "admin@example.com", RoleAdmin)
+
+	create := models.CreateMessageRequest{Username: "someone-else", Content: "hi"}
+	rr, response := doAuthRequest(router, "POST", "/api/messages", alice, create)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v", http.StatusCreated, rr.Code)
+	}
+	data := response.Data.(map[string]interface{})
+	if data["username"] != "alice@example.com" || data["author_id"] != float64(1) {
+		t.Errorf("Expected author from token, got %v", data)
+	}
+
+	update := models.UpdateMessageRequest{Content: "edited"}
+	rr, response = doAuthRequest(router, "PUT", "/api/messages/1", bob, update)
+	if rr.Code != http.StatusForbidden || response.Success {
+		t.Errorf("Expected 403 for other user, got %d %+v", rr.Code, response)
+	}
+	rr, _ = doAuthRequest(router, "DELETE", "/api/messages/1", bob, nil)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for other user, got %d", rr.Code)
+	}
+
+	rr, _ = doAuthRequest(router, "PUT", "/api/messages/1", alice, update)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected author to update, got %d", rr.Code)
+	}
+	rr, _ = doAuthRequest(router, "PUT", "/api/messages/99", alice, update)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing message, got %d", rr.Code)
+	}
+	rr, _ = doAuthRequest(router, "DELETE", "/api/messages/1", admin, nil)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected admin to delete, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"lab03-backend/validation"
+	"net/http"
+	"strconv"
+)
+
+// BatchMessages handles POST /api/messages/batch.
+// Every operation is checked like the single message endpoints would check
+// it, and gets the status code it would have had there. The response is
+// 200 when every operation succeeded and 207 otherwise.
+func (h *Handler) BatchMessages(w http.ResponseWriter, r *http.Request) {
+	var req models.BatchRequest
+	if !h.bindJSON(w, r, &req) {
+		return
+	}
+
+	results := make([]models.BatchItemResult, len(req.Operations))
+	ops := make([]storage.BatchOp, 0, len(req.Operations))
+	indexes := make([]int, 0, len(req.Operations)) // Position in the request of each op
+	rejected := false
+	for i := range req.Operations {
+		op, result := h.prepareBatchOp(r, &req.Operations[i])
+		result.Index = i
+		results[i] = result
+		if result.Status != 0 {
+			rejected = true
+			continue
+		}
+		ops = append(ops, op)
+		indexes = append(indexes, i)
+	}
+
+	if req.Atomic && rejected {
+		for _, i := range indexes {
+			results[i] = abortedBatchItem(i)
+		}
+		h.writeBatchResponse(w, req.Atomic, results)
+		return
+	}
+
+	stored, err := h.storage.Batch(ops, req.Atomic)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to apply batch")
+		return
+	}
+	for j, res := range stored {
+		i := indexes[j]
+		results[i] = batchItemResult(i, ops[j].Type, res)
+		if res.Message != nil {
+			h.schedulePreviews(res.Message)
+		}
+	}
+	h.writeBatchResponse(w, req.Atomic, results)
+}
+
+// prepareBatchOp validates and authorizes one operation. It returns a
+// result with a non-zero status when the operation is rejected.
+func (h *Handler) prepareBatchOp(r *http.Request, op *models.BatchOperation) (storage.BatchOp, models.BatchItemResult) {
+	if errs := validateBatchOp(op); errs != nil {
+		return storage.BatchOp{}, invalidBatchItem(errs)
+	}
+
+	if op.Op == string(storage.BatchCreate) {
+		req := models.CreateMessageRequest{
+			Username:      op.Username,
+			Content:       op.Content,
+			Format:        op.Format,
+			AttachmentIDs: op.AttachmentIDs,
+		}
+		// The token decides who the author is
+		authorID := 0
+		if claims := claimsFromContext(r.Context()); claims != nil {
+			req.Username = claims.Email
+			authorID = claims.UserID
+		}
+		if req.Format == "" {
+			req.Format = content.FormatPlain
+		}
+		if errs := validateStruct(&req); errs != nil {
+			return storage.BatchOp{}, invalidBatchItem(errs)
+		}
+		attachments, errs := h.resolveAttachments(r, req.AttachmentIDs)
+		if errs != nil {
+			return storage.BatchOp{}, invalidBatchItem(errs)
+		}
+		return storage.BatchOp{Type: storage.BatchCreate, Draft: &models.Message{
+			Username:    req.Username,
+			Content:     req.Content,
+			Format:      req.Format,
+			AuthorID:    authorID,
+			Attachments: attachments,
+		}}, models.BatchItemResult{}
+	}
+
+	if op.Op == string(storage.BatchUpdate) {
+		if errs := validateStruct(&models.UpdateMessageRequest{Content: op.Content}); errs != nil {
+			return storage.BatchOp{}, invalidBatchItem(errs)
+		}
+		// Updates must say which version they change, like If-Match on PUT
+		if op.Version == 0 {
+			return storage.BatchOp{}, models.BatchItemResult{
+				Status: http.StatusPreconditionRequired,
+				Error:  "version is required for updates",
+			}
+		}
+	}
+	if status, message := h.changeDenied(r, op.ID); status != 0 {
+		return storage.BatchOp{}, models.BatchItemResult{Status: status, Error: message}
+	}
+	return storage.BatchOp{
+		Type:    storage.BatchOpType(op.Op),
+		ID:      op.ID,
+		Version: op.Version,
+		Content: op.Content,
+	}, models.BatchItemResult{}
+}
+
+// validateBatchOp checks the tags of op and the fields each kind of operation uses
+func validateBatchOp(op *models.BatchOperation) validation.Errors {
+	if errs := validateStruct(op); errs != nil {
+		return errs
+	}
+	var errs validation.Errors
+	notAllowed := func(field string) {
+		errs = append(errs, validation.FieldError{Field: field, Code: validation.CodeNotAllowed, Message: "is not allowed for " + op.Op})
+	}
+	switch storage.BatchOpType(op.Op) {
+	case storage.BatchCreate:
+		if op.ID != 0 {
+			notAllowed("id")
+		}
+		if op.Version != 0 {
+			notAllowed("version")
+		}
+	case storage.BatchUpdate, storage.BatchDelete:
+		if op.ID == 0 {
+			errs = append(errs, validation.FieldError{Field: "id", Code: validation.CodeRequired, Message: "is required"})
+		}
+		if op.Username != "" {
+			notAllowed("username")
+		}
+		if op.Format != "" {
+			notAllowed("format")
+		}
+		if len(op.AttachmentIDs) > 0 {
+			notAllowed("attachment_ids")
+		}
+		if op.Op == string(storage.BatchDelete) && op.Content != "" {
+			notAllowed("content")
+		}
+	}
+	return errs
+}
+
+// validateStruct returns the field errors of v, or nil if it is valid.
+// v is always one of the request models, so no other error can occur.
+func validateStruct(v interface{}) validation.Errors {
+	errs, _ := validation.Struct(v).(validation.Errors)
+	return errs
+}
+
+func invalidBatchItem(errs validation.Errors) models.BatchItemResult {
+	return models.BatchItemResult{Status: http.StatusBadRequest, Error: "Validation failed", Errors: errs}
+}
+
+func abortedBatchItem(index int) models.BatchItemResult {
+	return models.BatchItemResult{
+		Index:  index,
+		Status: http.StatusFailedDependency,
+		Error:  "Not applied because another operation failed",
+	}
+}
+
+// batchItemResult maps the outcome of an applied operation to its result
+func batchItemResult(index int, typ storage.BatchOpType, res storage.BatchResult) models.BatchItemResult {
+	result := models.BatchItemResult{Index: index, Message: res.Message}
+	switch {
+	case res.Err == nil && typ == storage.BatchCreate:
+		result.Status = http.StatusCreated
+	case res.Err == nil && typ == storage.BatchDelete:
+		result.Status = http.StatusNoContent
+	case res.Err == nil:
+		result.Status = http.StatusOK
+	case errors.Is(res.Err, storage.ErrBatchAborted):
+		return abortedBatchItem(index)
+	case errors.Is(res.Err, storage.ErrMessageNotFound), errors.Is(res.Err, storage.ErrInvalidID):
+		result.Status, result.Error = http.StatusNotFound, "Message not found"
+	case errors.Is(res.Err, storage.ErrVersionConflict):
+		result.Status, result.Error = http.StatusPreconditionFailed, "Message was changed; version does not match"
+	case errors.Is(res.Err, storage.ErrAttachmentNotFound):
+		return models.BatchItemResult{Index: index, Status: http.StatusBadRequest, Error: "Validation failed", Errors: validation.Errors{{
+			Field: "attachment_ids", Code: validation.CodeInvalidValue, Message: "refers to an unknown attachment",
+		}}}
+	default:
+		result.Status, result.Error = http.StatusInternalServerError, "Storage error"
+	}
+	return result
+}
+
+// writeBatchResponse writes the results with 200 if all succeeded, 207 otherwise
+func (h *Handler) writeBatchResponse(w http.ResponseWriter, atomic bool, results []models.BatchItemResult) {
+	data := models.BatchResponse{Atomic: atomic, Results: results}
+	for _, res := range results {
+		if res.Status < 300 {
+			data.Succeeded++
+		} else {
+			data.Failed++
+		}
+	}
+	response := models.APIResponse{Success: data.Failed == 0, Data: data}
+	status := http.StatusOK
+	if data.Failed > 0 {
+		status = http.StatusMultiStatus
+		response.Error = strconv.Itoa(data.Failed) + " of " + strconv.Itoa(len(results)) + " operations failed"
+		if atomic {
+			response.Error = "Batch rolled back because an operation failed"
+		}
+	}
+	h.writeJSON(w, status, response)
+}
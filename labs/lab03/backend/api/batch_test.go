@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// doBatch posts ops and decodes the batch response
+func doBatch(t *testing.T, router http.Handler, token string, req models.BatchRequest) (int, models.APIResponse, models.BatchResponse) {
+	t.Helper()
+	rr, response := doAuthRequest(router, "POST", "/api/messages/batch", token, req)
+	var data models.BatchResponse
+	if response.Data != nil {
+		raw, _ := json.Marshal(response.Data)
+		json.Unmarshal(raw, &data)
+	}
+	return rr.Code, response, data
+}
+
+func statuses(results []models.BatchItemResult) []int {
+	out := make([]int, len(results))
+	for i, res := range results {
+		out[i] = res.Status
+	}
+	return out
+}
+
+func TestBatchMessagesBestEffort(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	store.Create("alice", "one")
+	store.Create("bob", "two")
+	router := NewHandler(store).SetupRoutes()
+
+	code, response, data := doBatch(t, router, "", models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "create", Username: "carol", Content: "three"},
+		{Op: "update", ID: 1, Version: 1, Content: "one, edited"},
+		{Op: "update", ID: 2, Content: "no version"},
+		{Op: "delete", ID: 2},
+		{Op: "delete", ID: 99},
+		{Op: "create", Username: "dave"},
+		{Op: "update", ID: 1, Version: 1, Content: "stale"},
+	}})
+	if code != http.StatusMultiStatus || response.Success || response.Error == "" {
+		t.Errorf("Expected 207 with an error summary, got %d %+v", code, response)
+	}
+	want := []int{201, 200, 428, 204, 404, 400, 412}
+	if got := statuses(data.Results); !slices.Equal(got, want) {
+		t.Errorf("Expected statuses %v, got %v", want, got)
+	}
+	if data.Succeeded != 3 || data.Failed != 4 {
+		t.Errorf("Expected 3 succeeded and 4 failed, got %d and %d", data.Succeeded, data.Failed)
+	}
+	if data.Results[0].Message == nil || data.Results[0].Message.Username != "carol" {
+		t.Errorf("Expected created message in result, got %+v", data.Results[0])
+	}
+	if errs := data.Results[5].Errors; len(errs) != 1 || errs[0].Field != "content" {
+		t.Errorf("Expected content field error, got %+v", errs)
+	}
+	for i, res := range data.Results {
+		if res.Index != i {
+			t.Errorf("Expected index %d, got %d", i, res.Index)
+		}
+	}
+
+	if count, _ := store.Count(); count != 2 {
+		t.Errorf("Expected 2 messages, got %d", count)
+	}
+	if msg, _ := store.GetByID(1); msg.Content != "one, edited" {
+		t.Errorf("Expected update applied, got %q", msg.Content)
+	}
+}
+
+func TestBatchMessagesAtomic(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	store.Create("alice", "one")
+	store.Create("bob", "two")
+	router := NewHandler(store).SetupRoutes()
+
+	// Rejected before reaching storage
+	code, response, data := doBatch(t, router, "", models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "create", Username: "carol"},
+	}})
+	if code != http.StatusMultiStatus || response.Success || !data.Atomic {
+		t.Errorf("Expected rolled back batch, got %d %+v", code, response)
+	}
+	if got := statuses(data.Results); !slices.Equal(got, []int{424, 400}) {
+		t.Errorf("Unexpected statuses %v", got)
+	}
+
+	// Failing in storage
+	_, _, data = doBatch(t, router, "", models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "update", ID: 2, Version: 5, Content: "stale"},
+	}})
+	if got := statuses(data.Results); !slices.Equal(got, []int{424, 412}) {
+		t.Errorf("Unexpected statuses %v", got)
+	}
+	if count, _ := store.Count(); count != 2 {
+		t.Errorf("Rolled back batch changed the count to %d", count)
+	}
+
+	code, response, data = doBatch(t, router, "", models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "delete", ID: 2, Version: 1},
+	}})
+	if code != http.StatusOK || !response.Success || data.Succeeded != 2 {
+		t.Errorf("Expected atomic batch applied, got %d %+v", code, data)
+	}
+	if count, _ := store.Count(); count != 0 {
+		t.Errorf("Expected no messages left, got %d", count)
+	}
+}
+
+func TestBatchMessagesValidation(t *testing.T) {
+	router := NewHandler(storage.NewMemoryStorage()).SetupRoutes()
+
+	code, response, _ := doBatch(t, router, "", models.BatchRequest{})
+	if code != http.StatusBadRequest || len(response.Errors) != 1 || response.Errors[0].Field != "operations" {
+		t.Errorf("Expected operations to be required, got %d %+v", code, response)
+	}
+
+	ops := make([]models.BatchOperation, 501)
+	for i := range ops {
+		ops[i] = models.BatchOperation{Op: "delete", ID: i + 1}
+	}
+	if code, _, _ := doBatch(t, router, "", models.BatchRequest{Operations: ops}); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for too many operations, got %d", code)
+	}
+
+	_, _, data := doBatch(t, router, "", models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "move", ID: 1},
+		{Op: "delete"},
+		{Op: "create", ID: 3, Username: "alice", Content: "hi"},
+		{Op: "delete", ID: 1, Content: "why"},
+	}})
+	fields := []string{"op", "id", "id", "content"}
+	for i, res := range data.Results {
+		if res.Status != http.StatusBadRequest || len(res.Errors) == 0 || res.Errors[0].Field != fields[i] {
+			t.Errorf("Operation %d: expected 400 on %s, got %+v", i, fields[i], res)
+		}
+	}
+}
+
+func TestBatchMessagesAuth(t *testing.T) {
+	router, tokens := setupAuthTestHandler(t)
+	alice, _ := tokens.GenerateToken(1, "alice@example.com")
+	bob, _ := tokens.GenerateToken(2, "bob@example.com")
+	admin, _ := tokens.GenerateTokenWithRole(3, "admin@example.com", RoleAdmin)
+
+	if code, _, _ := doBatch(t, router, "", models.BatchRequest{Operations: []models.BatchOperation{{Op: "delete", ID: 1}}}); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+
+	_, _, data := doBatch(t, router, alice, models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "create", Username: "someone-else", Content: "one"},
+		{Op: "create", Content: "two"},
+	}})
+	if data.Succeeded != 2 || data.Results[0].Message.Username != "alice@example.com" || data.Results[1].Message.AuthorID != 1 {
+		t.Fatalf("Expected author from token, got %+v", data.Results)
+	}
+
+	_, _, data = doBatch(t, router, bob, models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "update", ID: 2, Version: 1, Content: "mine now"},
+		{Op: "delete", ID: 99},
+	}})
+	if got := statuses(data.Results); !slices.Equal(got, []int{403, 403, 404}) {
+		t.Errorf("Expected other user rejected, got %v", got)
+	}
+
+	// Moderators clean up in one request
+	_, _, data = doBatch(t, router, admin, models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "delete", ID: 1},
+		{Op: "delete", ID: 2},
+	}})
+	if got := statuses(data.Results); !slices.Equal(got, []int{204, 204}) {
+		t.Errorf("Expected admin to delete, got %v", got)
+	}
+}
+
+func TestBatchMessagesPublishesEvents(t *testing.T) {
+	handler := NewHandler(storage.NewMemoryStorage())
+	router := handler.SetupRoutes()
+	_, events, cancel, _ := handler.events.Subscribe(0)
+	defer cancel()
+
+	doBatch(t, router, "", models.BatchRequest{Operations: []models.BatchOperation{
+		{Op: "create", Username: "alice", Content: "hi"},
+		{Op: "delete", ID: 1},
+	}})
+	for _, typ := range []storage.EventType{storage.EventCreate, storage.EventDelete} {
+		if ev := <-events; ev.Type != typ || ev.MessageID != 1 {
+			t.Errorf("Expected %s event for 1, got %+v", typ, ev)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"lab03-backend/validation"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodyBytes limits the size of JSON request bodies
+const maxBodyBytes = 1 << 20
+
+// bindJSON decodes the request body into dst and validates it.
+// On failure it writes the error response and returns false.
+func (h *Handler) bindJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return h.decodeJSON(w, r, dst) && h.validateRequest(w, dst)
+}
+
+// decodeJSON decodes exactly one JSON object into dst, rejecting unknown
+// fields and bodies over maxBodyBytes. On failure it writes the error response.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.More() {
+		err = errors.New("request body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		h.writeError(w, http.StatusRequestEntityTooLarge,
+			"Request body must not exceed "+strconv.FormatInt(maxErr.Limit, 10)+" bytes")
+	case errors.Is(err, io.EOF):
+		h.writeError(w, http.StatusBadRequest, "Request body is empty")
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		h.writeValidationError(w, validation.Errors{{
+			Field:   field,
+			Code:    validation.CodeInvalidType,
+			Message: "must be of type " + typeErr.Type.String(),
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		h.writeValidationError(w, validation.Errors{{
+			Field:   name,
+			Code:    validation.CodeUnknownField,
+			Message: "is not a known field",
+		}})
+	default:
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+	}
+	return false
+}
+
+// validateRequest checks dst against its validate tags and writes field errors on failure
+func (h *Handler) validateRequest(w http.ResponseWriter, dst interface{}) bool {
+	err := validation.Struct(dst)
+	if err == nil {
+		return true
+	}
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		h.writeValidationError(w, errs)
+	} else {
+		h.writeError(w, http.StatusInternalServerError, "Failed to validate request")
+	}
+	return false
+}
+
+// writeValidationError writes a 400 response listing the rejected fields
+func (h *Handler) writeValidationError(w http.ResponseWriter, errs validation.Errors) {
+	h.writeJSON(w, http.StatusBadRequest, models.APIResponse{
+		Success: false,
+		Error:   "Validation failed",
+		Errors:  errs,
+	})
+}
+
+// pathInt reads an integer path variable and writes a 400 response if it is not one
+func (h *Handler) pathInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	n, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		h.writeValidationError(w, validation.Errors{{
+			Field:   name,
+			Code:    validation.CodeInvalidValue,
+			Message: "must be an integer",
+		}})
+		return 0, false
+	}
+	return n, true
+}
+
+// writeStorageError maps storage errors to HTTP responses
+func (h *Handler) writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrMessageNotFound), errors.Is(err, storage.ErrInvalidID):
+		h.writeError(w, http.StatusNotFound, "Message not found")
+	default:
+		h.writeError(w, http.StatusInternalServerError, "Storage error")
+	}
+}
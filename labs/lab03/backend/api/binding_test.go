@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/validation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestBinding(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		field  string
+		code   string
+	}{
+		{"valid", "POST", "/api/messages", `{"username":"ann","content":"hi"}`, http.StatusCreated, "", ""},
+		{"missing content", "POST", "/api/messages", `{"username":"ann"}`, http.StatusBadRequest, "content", validation.CodeRequired},
+		{"unknown field", "POST", "/api/messages", `{"username":"ann","content":"hi","admin":true}`, http.StatusBadRequest, "admin", validation.CodeUnknownField},
+		{"wrong type", "POST", "/api/messages", `{"username":"ann","content":5}`, http.StatusBadRequest, "content", validation.CodeInvalidType},
+		{"malformed", "POST", "/api/messages", `{"username":`, http.StatusBadRequest, "", ""},
+		{"empty body", "POST", "/api/messages", ``, http.StatusBadRequest, "", ""},
+		{"trailing data", "POST", "/api/messages", `{"username":"ann","content":"hi"} {}`, http.StatusBadRequest, "", ""},
+		{"too large", "POST", "/api/messages", `{"username":"ann","content":"` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, "", ""},
+		{"update missing", "PUT", "/api/messages/42", `{"content":"hi"}`, http.StatusNotFound, "", ""},
+		{"id overflow", "PUT", "/api/messages/99999999999999999999", `{"content":"hi"}`, http.StatusBadRequest, "id", validation.CodeInvalidValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", "*")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %v, got %v: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.field == "" {
+				return
+			}
+			var response models.APIResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Could not decode response: %v", err)
+			}
+			if len(response.Errors) != 1 || response.Errors[0].Field != tt.field || response.Errors[0].Code != tt.code {
+				t.Errorf("Expected %s error on %s, got %+v", tt.code, tt.field, response.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"lab03-backend/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag identifies one version of a message
+func etag(msg *models.Message) string {
+	return `"` + strconv.Itoa(msg.ID) + "-" + strconv.Itoa(msg.Version) + `"`
+}
+
+// matchesETag reports whether an If-Match header value accepts msg.
+// Weak tags never match, as RFC 9110 requires strong comparison for If-Match.
+func matchesETag(header string, msg *models.Message) bool {
+	current := etag(msg)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch compares the If-Match header with the stored message.
+// It writes 428 when the header is required but missing, 404 when the
+// message is gone and 412 when it has changed. On success it returns
+// the version the client saw, or 0 when the header was absent.
+func (h *Handler) checkIfMatch(w http.ResponseWriter, r *http.Request, id int, required bool) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if required {
+			h.writeError(w, http.StatusPreconditionRequired, "If-Match header is required")
+			return 0, false
+		}
+		return 0, true
+	}
+	msg, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return 0, false
+	}
+	if !matchesETag(header, msg) {
+		h.writePreconditionFailed(w, msg)
+		return 0, false
+	}
+	return msg.Version, true
+}
+
+// writePreconditionFailed tells the client its copy is stale and what the current one is
+func (h *Handler) writePreconditionFailed(w http.ResponseWriter, current *models.Message) {
+	w.Header().Set("ETag", etag(current))
+	h.writeJSON(w, http.StatusPreconditionFailed, models.APIResponse{
+		Success: false,
+		Error:   "Message was changed by someone else",
+		Data:    current,
+	})
+}
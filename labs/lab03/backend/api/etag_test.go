@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+	send := func(method, path, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, path, &buf)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	send("POST", "/api/messages", "", models.CreateMessageRequest{Username: "ann", Content: "v1"})
+
+	rr := send("GET", "/api/messages/1", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+	tag := rr.Header().Get("ETag")
+	if tag != `"1-1"` {
+		t.Fatalf("Expected ETag \"1-1\", got %s", tag)
+	}
+
+	update := models.UpdateMessageRequest{Content: "v2"}
+	if rr := send("PUT", "/api/messages/1", "", update); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected status %v without If-Match, got %v", http.StatusPreconditionRequired, rr.Code)
+	}
+	rr = send("PUT", "/api/messages/1", tag, update)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1-2"` {
+		t.Fatalf("Expected update with new ETag, got %v %s", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	// The old tag is stale now
+	rr = send("PUT", "/api/messages/1", tag, models.UpdateMessageRequest{Content: "lost"})
+	if rr.Code != http.StatusPreconditionFailed || rr.Header().Get("ETag") != `"1-2"` {
+		t.Errorf("Expected 412 with current ETag, got %v %s", rr.Code, rr.Header().Get("ETag"))
+	}
+	if rr := send("DELETE", "/api/messages/1", tag, nil); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for stale delete, got %v", rr.Code)
+	}
+
+	rr = send("GET", "/api/messages/1/revisions", "", nil)
+	var response struct {
+		Data []models.MessageRevision `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.Data) != 2 || response.Data[0].Content != "v1" || response.Data[1].Content != "v2" {
+		t.Errorf("Unexpected revisions: %+v", response.Data)
+	}
+
+	if rr := send("GET", "/api/messages/2/revisions", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing message, got %v", rr.Code)
+	}
+}
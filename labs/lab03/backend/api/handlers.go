@@ -4,28 +4,48 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"lab03-backend/content"
+	"lab03-backend/httpstatus"
 	"lab03-backend/models"
 	"lab03-backend/storage"
+	"lab03-backend/validation"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
 )
 
 // Handler holds the storage instance
 type Handler struct {
-	// TODO: Add storage field of type *storage.MemoryStorage
-	storage *storage.MemoryStorage
+	storage   storage.MessageRepository
+	events    *storage.EventLog
+	heartbeat time.Duration  // Interval between keep-alives on event streams
+	tokens    TokenValidator // Nil disables authentication
+	images    *statusImages  // Nil links status images to upstream
+	// Nil disables uploads
+	attachments *AttachmentConfig
+	// Nil disables link previews
+	previews     content.Fetcher
+	previewSlots chan struct{} // Holds a token per running preview fetch
+	previewJobs  sync.WaitGroup
 }
 
-// NewHandler creates a new handler instance
-func NewHandler(storage *storage.MemoryStorage) *Handler {
-	// TODO: Return a new Handler instance with provided storage
+// NewHandler creates a new handler instance.
+// Changes made through the handler are published to its event log.
+func NewHandler(repo storage.MessageRepository) *Handler {
 	h := new(Handler)
-	h.storage = storage
+	notifying, ok := repo.(*storage.NotifyingRepository)
+	if !ok {
+		notifying = storage.NewNotifyingRepository(repo, storage.NewEventLog(eventReplaySize))
+	}
+	h.storage = notifying
+	h.events = notifying.Events()
+	h.heartbeat = defaultHeartbeat
 	return h
 }
 
@@ -41,145 +61,248 @@ func (h *Handler) SetupRoutes() *mux.Router {
 	// GET /messages -> h.GetMessages
 	api.HandleFunc("/messages", h.GetMessages).Methods("GET")
 	// POST /messages -> h.CreateMessage
-	api.HandleFunc("/messages", h.CreateMessage).Methods("POST")
+	api.HandleFunc("/messages", h.requireAuth(h.CreateMessage)).Methods("POST")
+	// POST /messages/batch -> h.BatchMessages
+	api.HandleFunc("/messages/batch", h.requireAuth(h.BatchMessages)).Methods("POST")
+	// GET /messages/stream -> h.StreamMessages (Server-Sent Events)
+	api.HandleFunc("/messages/stream", h.StreamMessages).Methods("GET")
+	// GET /messages/ws -> h.MessagesWebSocket
+	api.HandleFunc("/messages/ws", h.MessagesWebSocket).Methods("GET")
+	// GET /messages/{id} -> h.GetMessage
+	api.HandleFunc("/messages/{id:[0-9]+}", h.GetMessage).Methods("GET")
+	// GET /messages/{id}/revisions -> h.GetMessageRevisions
+	api.HandleFunc("/messages/{id:[0-9]+}/revisions", h.GetMessageRevisions).Methods("GET")
 	// PUT /messages/{id} -> h.UpdateMessage
-	api.HandleFunc("/messages/{id:[0-9]+}", h.UpdateMessage).Methods("PUT")
+	api.HandleFunc("/messages/{id:[0-9]+}", h.requireAuth(h.UpdateMessage)).Methods("PUT")
 	// DELETE /messages/{id} -> h.DeleteMessage
-	api.HandleFunc("/messages/{id:[0-9]+}", h.DeleteMessage).Methods("DELETE")
+	api.HandleFunc("/messages/{id:[0-9]+}", h.requireAuth(h.DeleteMessage)).Methods("DELETE")
+	// POST /attachments -> h.UploadAttachment (multipart/form-data)
+	api.HandleFunc("/attachments", h.requireAuth(h.UploadAttachment)).Methods("POST")
+	// GET /attachments/{id} -> h.DownloadAttachment
+	api.HandleFunc("/attachments/{id:[0-9a-f]+}", h.DownloadAttachment).Methods("GET")
+	// GET /attachments/{id}/thumbnail -> h.GetAttachmentThumbnail
+	api.HandleFunc("/attachments/{id:[0-9a-f]+}/thumbnail", h.GetAttachmentThumbnail).Methods("GET")
 	// GET /status/{code} -> h.GetHTTPStatus
 	api.HandleFunc("/status/{code:[0-9]+}", h.GetHTTPStatus).Methods("GET")
+	// GET /status/{code}/image -> h.GetHTTPStatusImage
+	api.HandleFunc("/status/{code:[0-9]+}/image", h.GetHTTPStatusImage).Methods("GET")
+	// GET /status -> h.ListHTTPStatuses
+	api.HandleFunc("/status", h.ListHTTPStatuses).Methods("GET")
 	// GET /health -> h.HealthCheck
 	api.HandleFunc("/health", h.HealthCheck).Methods("GET")
+	// GET /openapi.json and /docs describe all of the above; see OpenAPI
+	r.Handle("/openapi.json", openapi.Handler(h.OpenAPI())).Methods("GET")
+	r.Handle("/docs", openapi.DocsHandler("/openapi.json")).Methods("GET")
 	// TODO: Return the router
 	return r
 }
 
 // GetMessages handles GET /api/messages
+// Supports limit, cursor, username, since, until and sort query parameters.
 func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetMessages handler
-	// Get all messages from storage
-	msgs := h.storage.GetAll()
-	// Create successful API response
+	query, err := parseMessageQuery(r.URL.Query())
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.storage.List(query)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get messages")
+		return
+	}
 	response := models.APIResponse{
 		Success: true,
-		Data:    msgs,
+		Data:    page.Messages,
 	}
-	// Write JSON response with status 200
+	if page.HasMore {
+		last := page.Messages[len(page.Messages)-1]
+		response.NextCursor = encodeCursor(query.Sort, storage.KeyOf(last))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
 	h.writeJSON(w, 200, response)
-	// Handle any errors appropriately
+}
+
+// GetMessage handles GET /api/messages/{id}
+// The ETag header identifies the version for later If-Match requests.
+func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	msgID, ok := h.pathInt(w, r, "id")
+	if !ok {
+		return
+	}
+	msg, err := h.storage.GetByID(msgID)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(msg))
+	h.writeJSON(w, 200, models.APIResponse{Success: true, Data: msg})
+}
+
+// GetMessageRevisions handles GET /api/messages/{id}/revisions
+func (h *Handler) GetMessageRevisions(w http.ResponseWriter, r *http.Request) {
+	msgID, ok := h.pathInt(w, r, "id")
+	if !ok {
+		return
+	}
+	revs, err := h.storage.Revisions(msgID)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	h.writeJSON(w, 200, models.APIResponse{Success: true, Data: revs})
 }
 
 // CreateMessage handles POST /api/messages
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement CreateMessage handler
-	// Parse JSON request body into CreateMessageRequest
-	// Validate the request
 	var req models.CreateMessageRequest
-
-	if err := h.parseJSON(r, req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
-	// Create message in storage
-	msg, _ := h.storage.Create(req.Username, req.Content)
-	// Create successful API response
+	// The token decides who the author is
+	authorID := 0
+	if claims := claimsFromContext(r.Context()); claims != nil {
+		req.Username = claims.Email
+		authorID = claims.UserID
+	}
+	if req.Format == "" {
+		req.Format = content.FormatPlain
+	}
+	if !h.validateRequest(w, &req) {
+		return
+	}
+	attachments, errs := h.resolveAttachments(r, req.AttachmentIDs)
+	if errs != nil {
+		h.writeValidationError(w, errs)
+		return
+	}
+	msg, err := h.storage.Insert(&models.Message{
+		Username:    req.Username,
+		Content:     req.Content,
+		Format:      req.Format,
+		AuthorID:    authorID,
+		Attachments: attachments,
+	})
+	if errors.Is(err, storage.ErrAttachmentNotFound) {
+		h.writeValidationError(w, validation.Errors{{Field: "attachment_ids", Code: validation.CodeInvalidValue, Message: "refers to an unknown attachment"}})
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create message")
+		return
+	}
+	h.schedulePreviews(msg)
 	response := models.APIResponse{
 		Success: true,
 		Data:    msg,
 	}
-	// Write JSON response with status 201
 	h.writeJSON(w, 201, response)
-	// Handle validation and storage errors appropriately
 }
 
 // UpdateMessage handles PUT /api/messages/{id}
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement UpdateMessage handler
-	// Extract ID from URL path variables
-	path := r.URL.Path
-	parts := strings.Split(path, "/")
-	if len(parts) < 3 {
-		h.writeError(w, http.StatusBadRequest, "Invalid path")
+	msgID, ok := h.pathInt(w, r, "id")
+	if !ok {
 		return
 	}
-	msgID, _ := strconv.Atoi(parts[3])
-	// Parse JSON request body into UpdateMessageRequest
-	// Validate the request
 	var req models.UpdateMessageRequest
-
-	if err := h.parseJSON(r, req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+	if !h.bindJSON(w, r, &req) {
 		return
 	}
-	// Update message in storage
-	msg, err := h.storage.Update(msgID, req.Content)
-
+	if !h.authorizeChange(w, r, msgID) {
+		return
+	}
+	version, ok := h.checkIfMatch(w, r, msgID, true)
+	if !ok {
+		return
+	}
+	msg, err := h.storage.UpdateIfVersion(msgID, req.Content, version)
+	if errors.Is(err, storage.ErrVersionConflict) {
+		// Changed between the If-Match check and the update
+		if current, err := h.storage.GetByID(msgID); err == nil {
+			h.writePreconditionFailed(w, current)
+			return
+		}
+	}
 	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+		h.writeStorageError(w, err)
 		return
 	}
-	// Create successful API response
+	h.schedulePreviews(msg)
+	w.Header().Set("ETag", etag(msg))
 	response := models.APIResponse{
 		Success: true,
 		Data:    msg,
 	}
-	// Write JSON response with status 200
 	h.writeJSON(w, 200, response)
-	// Handle validation, parsing, and storage errors appropriately
 }
 
 // DeleteMessage handles DELETE /api/messages/{id}
 func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement DeleteMessage handler
-	// Extract ID from URL path variables
-	path := r.URL.Path
-	parts := strings.Split(path, "/")
-	if len(parts) < 3 {
-		h.writeError(w, http.StatusBadRequest, "Invalid path")
+	msgID, ok := h.pathInt(w, r, "id")
+	if !ok {
 		return
 	}
-	msgID, _ := strconv.Atoi(parts[3])
-	// Delete message from storage
-	err := h.storage.Delete(msgID)
-
-	if err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid JSON")
+	if !h.authorizeChange(w, r, msgID) {
 		return
 	}
-	// Write response with status 204 (No Content)
-	response := models.APIResponse{
-		Success: true,
-		Data:    nil,
+	// If-Match is optional for deletes
+	if _, ok := h.checkIfMatch(w, r, msgID, false); !ok {
+		return
+	}
+	if err := h.storage.Delete(msgID); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+	// 204 responses carry no body
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeChange checks that the caller may change message id and writes
+// 404 or 403 when not. It reports whether the handler should continue.
+func (h *Handler) authorizeChange(w http.ResponseWriter, r *http.Request, id int) bool {
+	if status, message := h.changeDenied(r, id); status != 0 {
+		h.writeError(w, status, message)
+		return false
 	}
+	return true
+}
 
-	h.writeJSON(w, 204, response)
-	// Handle parsing and storage errors appropriately
+// changeDenied returns the status and error message for a caller who may
+// not change message id, or 0 if the change is allowed
+func (h *Handler) changeDenied(r *http.Request, id int) (int, string) {
+	claims := claimsFromContext(r.Context())
+	if claims == nil {
+		return 0, ""
+	}
+	msg, err := h.storage.GetByID(id)
+	if err != nil {
+		return http.StatusNotFound, "Message not found"
+	}
+	if !canModify(claims, msg) {
+		return http.StatusForbidden, "Only the author or an admin can change this message"
+	}
+	return 0, ""
 }
 
 // GetHTTPStatus handles GET /api/status/{code}
 func (h *Handler) GetHTTPStatus(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetHTTPStatus handler
-	// Extract status code from URL path variables
-	path := r.URL.Path
-	parts := strings.Split(path, "/")
-	if len(parts) < 3 {
-		h.writeError(w, http.StatusBadRequest, "Invalid path")
+	statusCode, ok := h.pathInt(w, r, "code")
+	if !ok {
 		return
 	}
-	statusCode, _ := strconv.Atoi(parts[3])
 	// Validate status code (must be between 100-599)
 	if (statusCode < 100) || (statusCode > 599) {
-		h.writeError(w, http.StatusBadRequest, "Invalid path")
+		h.writeError(w, http.StatusBadRequest, "Status code must be between 100 and 599")
 		return
 	}
-	// Create HTTPStatusResponse with:
-	//   - StatusCode: parsed code
-	//   - ImageURL: "https://http.cat/{code}"
-	//   - Description: HTTP status description
 	resp := models.HTTPStatusResponse{
 		StatusCode:  statusCode,
-		ImageURL:    fmt.Sprintf("https://http.cat/%d.jpg", statusCode),
+		ImageURL:    h.statusImageURL(statusCode),
 		Description: getHTTPStatusDescription(statusCode),
+		Category:    string(httpstatus.CategoryOf(statusCode)),
+	}
+	if status, ok := httpstatus.Lookup(statusCode); ok {
+		resp.Reference = status.Reference
 	}
 	// Create successful API response
 	response := models.APIResponse{
@@ -199,17 +322,19 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	//   - message: "API is running"
 	//   - timestamp: current time
 	//   - total_messages: count from storage
+	count, err := h.storage.Count()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to count messages")
+		return
+	}
 	resp := models.APIResponse{
 		Success: true,
-		Data: struct {
-			status         string
-			message        string
-			timestamp      time.Time
-			total_messages int
-		}{status: "ok",
-			message:        "Api is running",
-			timestamp:      time.Now(),
-			total_messages: h.storage.Count()},
+		Data: models.HealthResponse{
+			Status:        "ok",
+			Message:       "API is running",
+			Timestamp:     time.Now(),
+			TotalMessages: count,
+		},
 	}
 	// Write JSON response with status 200
 	h.writeJSON(w, 200, resp)
@@ -248,47 +373,26 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message string)
 	}
 }
 
-// Helper function to parse JSON request body
-func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
-	// TODO: Implement parseJSON helper
-	// Create JSON decoder from request body
-	decoder := json.NewDecoder(r.Body)
-	// Decode into destination interface
-	if err := decoder.Decode(&dst); err != nil {
-		return errors.New("decode error")
-	}
-	defer r.Body.Close()
-	// Return any decoding errors
-	return nil
-}
-
 // Helper function to get HTTP status description
+// Returns the IANA registered name, or "Unknown Status" for unassigned codes
 func getHTTPStatusDescription(code int) string {
-	// TODO: Implement getHTTPStatusDescription
-	// Return appropriate description for common HTTP status codes
-	str := "Unknown Status"
-	// Use a switch statement or map to handle:
-	// 200: "OK", 201: "Created", 204: "No Content"
-	// 400: "Bad Request", 401: "Unauthorized", 404: "Not Found"
-	// 500: "Internal Server Error", etc.
-	switch code {
-	case 200:
-		str = "OK"
-	case 201:
-		str = "Created"
-	case 204:
-		str = "No Content"
-	case 400:
-		str = "Bad Request"
-	case 401:
-		str = "Unauthorized"
-	case 404:
-		str = "Not Found"
-	case 500:
-		str = "Internal Server Error"
-	}
-	// Return "Unknown Status" for unrecognized codes
-	return str
+	if status, ok := httpstatus.Lookup(code); ok {
+		return status.Description
+	}
+	return "Unknown Status"
+}
+
+// statusImageURL links to the local image proxy when enabled, upstream otherwise
+func (h *Handler) statusImageURL(code int) string {
+	if h.images != nil {
+		return fmt.Sprintf("/api/status/%d/image", code)
+	}
+	return strings.ReplaceAll(DefaultStatusImageUpstream, "{code}", strconv.Itoa(code))
+}
+
+// ListHTTPStatuses handles GET /api/status
+func (h *Handler) ListHTTPStatuses(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, 200, models.APIResponse{Success: true, Data: httpstatus.All()})
 }
 
 // CORS middleware
@@ -304,7 +408,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		// TODO: Implement CORS logic here
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, If-Match, Range")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, ETag, Content-Range, Content-Disposition")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
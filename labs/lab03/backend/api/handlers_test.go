@@ -104,6 +104,7 @@ func TestUpdateMessage(t *testing.T) {
 		t.Fatal(err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1-1"`)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -209,3 +210,52 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected Content-Type application/json, got %s", contentType)
 	}
 }
+
+func TestGetMessagesPagination(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	for _, user := range []string{"alice", "bob", "alice", "alice"} {
+		store.Create(user, "hello")
+	}
+	router := NewHandler(store).SetupRoutes()
+
+	get := func(query string) (*httptest.ResponseRecorder, models.APIResponse) {
+		req, _ := http.NewRequest("GET", "/api/messages?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.APIResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr, response
+	}
+
+	rr, response := get("username=alice&sort=-id&limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("Expected X-Total-Count 3, got %q", total)
+	}
+	if len(response.Data.([]interface{})) != 2 || response.NextCursor == "" {
+		t.Fatalf("Expected 2 messages and a cursor, got %+v", response)
+	}
+
+	_, response = get("username=alice&sort=-id&limit=2&cursor=" + response.NextCursor)
+	data := response.Data.([]interface{})
+	if len(data) != 1 || data[0].(map[string]interface{})["id"].(float64) != 1 {
+		t.Errorf("Expected last page with message 1, got %+v", response.Data)
+	}
+	if response.NextCursor != "" {
+		t.Errorf("Expected no cursor on last page, got %q", response.NextCursor)
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "sort=name", "since=yesterday", "cursor=not-a-cursor"} {
+		if rr, _ := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %v, got %v", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	// A cursor is bound to the sort order it was issued for
+	_, response = get("limit=1")
+	if rr, _ := get("sort=-id&cursor=" + response.NextCursor); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v for mismatched cursor, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"fmt"
+	"lab03-backend/httpstatus"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+)
+
+// bearerScheme names the security scheme of endpoints behind requireAuth
+const bearerScheme = "bearerAuth"
+
+// OpenAPI describes the routes set up by SetupRoutes.
+// Keep it in step with SetupRoutes; TestOpenAPIMatchesRoutes fails when they drift.
+func (h *Handler) OpenAPI() *openapi.Document {
+	spec := openapi.New("Lab03 Messages API", "1.0.0",
+		"Chat messages with revisions, attachments, link previews and live updates.")
+	spec.AddBearerAuth(bearerScheme)
+
+	// Responses are wrapped in APIResponse with the payload in data
+	envelope := spec.Schema(models.APIResponse{})
+	data := func(schema *openapi.Schema) *openapi.Schema {
+		return openapi.Extend(envelope, map[string]*openapi.Schema{"data": schema})
+	}
+	failure := func(description string) *openapi.Response {
+		return openapi.JSON(description, envelope)
+	}
+	message := spec.Schema(models.Message{})
+	// Message.MarshalJSON adds the rendered content
+	spec.Document().Components.Schemas["Message"].Properties["html"] = &openapi.Schema{
+		Type: "string", Description: "Content rendered as sanitized HTML",
+	}
+	var security []map[string][]string
+	if h.tokens != nil {
+		security = openapi.Bearer(bearerScheme)
+	}
+
+	messageID := openapi.PathParam("id", "Message ID", openapi.Integer())
+	attachmentID := openapi.PathParam("id", "Attachment ID", openapi.String())
+	statusCode := openapi.PathParam("code", "HTTP status code, 100-599", openapi.Integer())
+	ifMatch := openapi.HeaderParam("If-Match", "ETag of the version being changed", openapi.String())
+	etagHeader := map[string]openapi.Header{"ETag": {Description: "Identifies the message version", Schema: openapi.String()}}
+
+	spec.Add("GET", "/api/messages", openapi.Operation{
+		Summary: "List messages",
+		Tags:    []string{"messages"},
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("limit", fmt.Sprintf("Page size, 1-%d, %d by default", maxPageLimit, defaultPageLimit), openapi.Integer()),
+			openapi.QueryParam("cursor", "next_cursor of the previous page", openapi.String()),
+			openapi.QueryParam("username", "Only messages by this user", openapi.String()),
+			openapi.QueryParam("since", "RFC 3339 timestamp, inclusive", &openapi.Schema{Type: "string", Format: "date-time"}),
+			openapi.QueryParam("until", "RFC 3339 timestamp, exclusive", &openapi.Schema{Type: "string", Format: "date-time"}),
+			openapi.QueryParam("sort", "Sort order", &openapi.Schema{Type: "string", Enum: []interface{}{
+				string(storage.SortIDAsc), string(storage.SortIDDesc), string(storage.SortTimestampAsc), string(storage.SortTimestampDesc),
+			}}),
+		},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: {
+				Description: "A page of messages",
+				Headers:     map[string]openapi.Header{"X-Total-Count": {Description: "Messages matching the filters", Schema: openapi.Integer()}},
+				Content:     openapi.JSON("", data(openapi.ArrayOf(message))).Content,
+			},
+			400: failure("Invalid query parameter"),
+		}),
+	})
+	spec.Add("POST", "/api/messages", openapi.Operation{
+		Summary:     "Create a message",
+		Description: "With authentication enabled the author comes from the token and username is ignored.",
+		Tags:        []string{"messages"},
+		RequestBody: openapi.JSONBody(spec.Schema(models.CreateMessageRequest{})),
+		Security:    security,
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			201: openapi.JSON("Created message", data(message)),
+			400: failure("Invalid request"),
+			401: failure("Missing or invalid token"),
+		}),
+	})
+	spec.Add("POST", "/api/messages/batch", openapi.Operation{
+		Summary: "Create, update and delete messages in one request",
+		Description: "Each operation gets the status it would have had on its own endpoint. " +
+			"Atomic batches apply every operation or none; the others get 424. " +
+			"The response is 207 when any operation failed.",
+		Tags:        []string{"messages"},
+		RequestBody: openapi.JSONBody(spec.Schema(models.BatchRequest{})),
+		Security:    security,
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Every operation succeeded", data(spec.Schema(models.BatchResponse{}))),
+			207: openapi.JSON("Some operations failed", data(spec.Schema(models.BatchResponse{}))),
+			400: failure("Invalid request"),
+			401: failure("Missing or invalid token"),
+		}),
+	})
+	spec.Add("GET", "/api/messages/stream", openapi.Operation{
+		Summary:     "Stream message events",
+		Description: "Server-Sent Events carrying create, update and delete events. Resume with Last-Event-ID.",
+		Tags:        []string{"messages"},
+		Parameters: []openapi.Parameter{
+			openapi.HeaderParam("Last-Event-ID", "ID of the last event received", openapi.String()),
+			openapi.QueryParam("last_event_id", "Same as Last-Event-ID, for clients that cannot set headers", openapi.String()),
+		},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("Event stream; each data line is a MessageEvent", "text/event-stream", spec.Schema(storage.MessageEvent{})),
+			400: failure("Invalid Last-Event-ID"),
+		}),
+	})
+	spec.Add("GET", "/api/messages/ws", openapi.Operation{
+		Summary:     "Message events over WebSocket",
+		Description: "Each text frame is a MessageEvent.",
+		Tags:        []string{"messages"},
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("last_event_id", "ID of the last event received", openapi.String()),
+		},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			101: {Description: "Switched to the WebSocket protocol"},
+			400: failure("Invalid last_event_id or not a WebSocket handshake"),
+		}),
+	})
+	spec.Add("GET", "/api/messages/{id}", openapi.Operation{
+		Summary:    "Get a message",
+		Tags:       []string{"messages"},
+		Parameters: []openapi.Parameter{messageID},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: {Description: "The message", Headers: etagHeader, Content: openapi.JSON("", data(message)).Content},
+			404: failure("Message not found"),
+		}),
+	})
+	spec.Add("GET", "/api/messages/{id}/revisions", openapi.Operation{
+		Summary:    "List the versions of a message",
+		Tags:       []string{"messages"},
+		Parameters: []openapi.Parameter{messageID},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Revisions, oldest first", data(openapi.ArrayOf(spec.Schema(models.MessageRevision{})))),
+			404: failure("Message not found"),
+		}),
+	})
+	spec.Add("PUT", "/api/messages/{id}", openapi.Operation{
+		Summary:     "Update a message",
+		Tags:        []string{"messages"},
+		Parameters:  []openapi.Parameter{messageID, ifMatch},
+		RequestBody: openapi.JSONBody(spec.Schema(models.UpdateMessageRequest{})),
+		Security:    security,
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: {Description: "Updated message", Headers: etagHeader, Content: openapi.JSON("", data(message)).Content},
+			400: failure("Invalid request"),
+			401: failure("Missing or invalid token"),
+			403: failure("Not the author or an admin"),
+			404: failure("Message not found"),
+			412: failure("If-Match does not match the current version"),
+			428: failure("If-Match is required"),
+		}),
+	})
+	spec.Add("DELETE", "/api/messages/{id}", openapi.Operation{
+		Summary:    "Delete a message",
+		Tags:       []string{"messages"},
+		Parameters: []openapi.Parameter{messageID, ifMatch},
+		Security:   security,
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			204: {Description: "Deleted"},
+			401: failure("Missing or invalid token"),
+			403: failure("Not the author or an admin"),
+			404: failure("Message not found"),
+			412: failure("If-Match does not match the current version"),
+		}),
+	})
+
+	spec.Add("POST", "/api/attachments", openapi.Operation{
+		Summary:     "Upload an attachment",
+		Description: "The file goes in the file field. Its type is detected from the contents.",
+		Tags:        []string{"attachments"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"multipart/form-data": {Schema: &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"file": openapi.Binary()},
+				Required:   []string{"file"},
+			}},
+		}},
+		Security: security,
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			201: openapi.JSON("Stored attachment", data(spec.Schema(models.Attachment{}))),
+			400: failure("Missing or unreadable file"),
+			401: failure("Missing or invalid token"),
+			404: failure("Attachments are not enabled"),
+			413: failure("File too large"),
+			415: failure("File type not allowed"),
+		}),
+	})
+	spec.Add("GET", "/api/attachments/{id}", openapi.Operation{
+		Summary:    "Download an attachment",
+		Tags:       []string{"attachments"},
+		Parameters: []openapi.Parameter{attachmentID, openapi.HeaderParam("Range", "Byte range to download", openapi.String())},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("File contents", "application/octet-stream", openapi.Binary()),
+			206: openapi.Body("Requested range", "application/octet-stream", openapi.Binary()),
+			404: failure("Attachment not found"),
+			416: {Description: "Range not satisfiable"},
+		}),
+	})
+	spec.Add("GET", "/api/attachments/{id}/thumbnail", openapi.Operation{
+		Summary:    "Download an attachment thumbnail",
+		Tags:       []string{"attachments"},
+		Parameters: []openapi.Parameter{attachmentID},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("PNG thumbnail", "image/png", openapi.Binary()),
+			404: failure("Attachment or thumbnail not found"),
+		}),
+	})
+
+	spec.Add("GET", "/api/status/{code}", openapi.Operation{
+		Summary:    "Describe an HTTP status code",
+		Tags:       []string{"status"},
+		Parameters: []openapi.Parameter{statusCode},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Status description", data(spec.Schema(models.HTTPStatusResponse{}))),
+			400: failure("Code outside 100-599"),
+		}),
+	})
+	spec.Add("GET", "/api/status/{code}/image", openapi.Operation{
+		Summary:    "Image for an HTTP status code",
+		Tags:       []string{"status"},
+		Parameters: []openapi.Parameter{statusCode},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("Image", "image/*", openapi.Binary()),
+			400: failure("Code outside 100-599"),
+			404: failure("Status images are not served"),
+			502: failure("No image available"),
+		}),
+	})
+	spec.Add("GET", "/api/status", openapi.Operation{
+		Summary: "List registered HTTP status codes",
+		Tags:    []string{"status"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("IANA registry", data(openapi.ArrayOf(spec.Schema(httpstatus.Status{})))),
+		}),
+	})
+	spec.Add("GET", "/api/health", openapi.Operation{
+		Summary: "Report API health",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("API is running", data(spec.Schema(models.HealthResponse{}))),
+			500: failure("Storage is unavailable"),
+		}),
+	})
+
+	spec.Add("GET", "/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("OpenAPI 3 document", &openapi.Schema{Type: "object"}),
+		}),
+	})
+	spec.Add("GET", "/docs", openapi.Operation{
+		Summary: "API documentation page",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("HTML page", "text/html", openapi.String()),
+		}),
+	})
+
+	return spec.Document()
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"lab03-backend/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+)
+
+// servedRoutes lists the routes of router as "METHOD /path"
+func servedRoutes(t *testing.T, router *mux.Router) []string {
+	var routes []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Prefixes like /api have no methods of their own
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+openapi.NormalizePath(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return routes
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	h := NewHandler(storage.NewMemoryStorage())
+	undocumented, unserved := openapi.Diff(servedRoutes(t, h.SetupRoutes()), h.OpenAPI().Routes())
+	for _, route := range undocumented {
+		t.Errorf("Route %s is missing from the OpenAPI document", route)
+	}
+	for _, route := range unserved {
+		t.Errorf("OpenAPI documents %s, which is not routed", route)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	router := NewHandler(storage.NewMemoryStorage()).SetupRoutes()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(rr.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	create := doc.Components.Schemas["CreateMessageRequest"]
+	if create == nil {
+		t.Fatal("Expected CreateMessageRequest in the components")
+	}
+	if len(create.Required) != 2 || create.Properties["format"] == nil || len(create.Properties["format"].Enum) != 3 {
+		t.Errorf("Unexpected CreateMessageRequest schema: %+v", create)
+	}
+	if doc.Components.Schemas["Message"].Properties["html"] == nil {
+		t.Error("Expected Message to include the rendered html")
+	}
+	limit := doc.Paths["/api/messages"]["get"].Parameters[0]
+	if want := fmt.Sprintf("1-%d", maxPageLimit); limit.Name != "limit" || !strings.Contains(limit.Description, want) {
+		t.Errorf("Expected the limit parameter to allow %s, got %+v", want, limit)
+	}
+	if op := doc.Paths["/api/messages"]["post"]; op == nil || op.Security != nil {
+		t.Errorf("Expected POST /api/messages without security when auth is off, got %+v", op)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/docs", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected the docs page, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
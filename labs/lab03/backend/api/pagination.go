@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"lab03-backend/storage"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// cursor is the decoded form of the opaque next_cursor value.
+// It records the sort order so it cannot be reused with another one.
+type cursor struct {
+	Sort      storage.SortOrder `json:"s"`
+	ID        int               `json:"i"`
+	Timestamp time.Time         `json:"t"`
+}
+
+func encodeCursor(sort storage.SortOrder, key storage.MessageKey) string {
+	data, _ := json.Marshal(cursor{Sort: sort, ID: key.ID, Timestamp: key.Timestamp})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort storage.SortOrder) (*storage.MessageKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	if c.Sort != sort {
+		return nil, errors.New("cursor does not match sort order")
+	}
+	return &storage.MessageKey{ID: c.ID, Timestamp: c.Timestamp}, nil
+}
+
+// parseMessageQuery reads limit, cursor, username, since, until and sort
+func parseMessageQuery(values url.Values) (storage.MessageQuery, error) {
+	q := storage.MessageQuery{
+		Username: values.Get("username"),
+		Sort:     storage.SortOrder(values.Get("sort")),
+		Limit:    defaultPageLimit,
+	}
+	if q.Sort == "" {
+		q.Sort = storage.SortIDAsc
+	}
+	if !q.Sort.Valid() {
+		return q, errors.New("sort must be one of id, -id, timestamp, -timestamp")
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return q, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		q.Limit = limit
+	}
+
+	var err error
+	if q.Since, err = parseTimeParam(values, "since"); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTimeParam(values, "until"); err != nil {
+		return q, err
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		if q.After, err = decodeCursor(v, q.Sort); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+func parseTimeParam(values url.Values, name string) (time.Time, error) {
+	v := values.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
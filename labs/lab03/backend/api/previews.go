@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"log"
+	"time"
+)
+
+// previewTimeout bounds fetching all previews of one message
+const previewTimeout = 15 * time.Second
+
+// maxPreviewJobs is how many messages get their previews fetched at once
+const maxPreviewJobs = 8
+
+// UsePreviews fetches previews of the links in new and edited messages.
+// Fetching happens in the background; the previews reach clients through
+// the message event streams and later reads. At most maxPreviewJobs
+// messages are fetched at a time, and messages arriving while all of them
+// are busy get no previews.
+func (h *Handler) UsePreviews(fetcher content.Fetcher) {
+	h.previews = fetcher
+	h.previewSlots = make(chan struct{}, maxPreviewJobs)
+}
+
+// schedulePreviews fetches and stores the previews of msg in the background
+func (h *Handler) schedulePreviews(msg *models.Message) {
+	if h.previews == nil {
+		return
+	}
+	urls := content.ExtractURLs(msg.Content)
+	if len(urls) == 0 {
+		return
+	}
+	select {
+	case h.previewSlots <- struct{}{}:
+	default:
+		log.Printf("Skipping previews of message %d: %d fetches already running", msg.ID, maxPreviewJobs)
+		return
+	}
+	h.previewJobs.Add(1)
+	go func() {
+		defer h.previewJobs.Done()
+		defer func() { <-h.previewSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+		defer cancel()
+
+		previews := make([]content.LinkPreview, 0, len(urls))
+		for _, url := range urls {
+			p, err := h.previews.Fetch(ctx, url)
+			if err != nil {
+				log.Printf("No preview for %s: %v", url, err)
+				continue
+			}
+			p.URL = url
+			previews = append(previews, *p)
+		}
+		if len(previews) == 0 {
+			return
+		}
+		err := h.storage.SetPreviews(msg.ID, msg.Version, previews)
+		// The message was edited or deleted meanwhile; the edit schedules its own previews
+		if err != nil && !errors.Is(err, storage.ErrVersionConflict) && !errors.Is(err, storage.ErrMessageNotFound) {
+			log.Printf("Failed to store previews of message %d: %v", msg.ID, err)
+		}
+	}()
+}
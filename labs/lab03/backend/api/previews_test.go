@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lab03-backend/content"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubFetcher returns canned previews and records the links asked for
+type stubFetcher struct {
+	mutex   sync.Mutex
+	fetched []string
+}
+
+func (f *stubFetcher) Fetch(ctx context.Context, url string) (*content.LinkPreview, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.fetched = append(f.fetched, url)
+	if strings.Contains(url, "broken") {
+		return nil, errors.New("unreachable")
+	}
+	return &content.LinkPreview{Title: "Title of " + url, Description: "About " + url}, nil
+}
+
+type messageWithHTML struct {
+	ID       int                   `json:"id"`
+	Content  string                `json:"content"`
+	Format   string                `json:"format"`
+	HTML     string                `json:"html"`
+	Previews []content.LinkPreview `json:"previews"`
+}
+
+func getMessageWithHTML(t *testing.T, router http.Handler, path string) messageWithHTML {
+	req, _ := http.NewRequest("GET", path, nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response struct {
+		Data messageWithHTML `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode %s: %v", path, err)
+	}
+	return response.Data
+}
+
+func TestMessageFormats(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+
+	rr, response := doAuthRequest(router, "POST", "/api/messages", "", map[string]string{
+		"username": "alice",
+		"content":  "**hi** <script>x</script>",
+		"format":   "markdown",
+	})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	data := response.Data.(map[string]interface{})
+	if data["format"] != "markdown" || data["content"] != "**hi** <script>x</script>" {
+		t.Errorf("Expected raw markdown content, got %v", data)
+	}
+	if data["html"] != "<p><strong>hi</strong> x</p>" {
+		t.Errorf("Unexpected html: %v", data["html"])
+	}
+
+	doAuthRequest(router, "POST", "/api/messages", "", map[string]string{"username": "bob", "content": "a < b"})
+	if msg := getMessageWithHTML(t, router, "/api/messages/2"); msg.Format != "plain" || msg.HTML != "a &lt; b" {
+		t.Errorf("Expected escaped plain message, got %+v", msg)
+	}
+
+	rr, response = doAuthRequest(router, "POST", "/api/messages", "", map[string]string{
+		"username": "alice", "content": "x", "format": "rtf",
+	})
+	if rr.Code != http.StatusBadRequest || len(response.Errors) != 1 || response.Errors[0].Field != "format" {
+		t.Errorf("Expected format validation error, got %d %+v", rr.Code, response)
+	}
+}
+
+func TestLinkPreviews(t *testing.T) {
+	handler := setupTestHandler()
+	fetcher := &stubFetcher{}
+	handler.UsePreviews(fetcher)
+	router := handler.SetupRoutes()
+
+	doAuthRequest(router, "POST", "/api/messages", "", map[string]string{
+		"username": "alice",
+		"content":  "read https://example.com/post and https://broken.example",
+	})
+	handler.previewJobs.Wait()
+
+	msg := getMessageWithHTML(t, router, "/api/messages/1")
+	want := []content.LinkPreview{{
+		URL:         "https://example.com/post",
+		Title:       "Title of https://example.com/post",
+		Description: "About https://example.com/post",
+	}}
+	if len(msg.Previews) != 1 || msg.Previews[0] != want[0] {
+		t.Errorf("Expected %+v, got %+v", want, msg.Previews)
+	}
+
+	// Editing the content replaces the previews
+	req, _ := http.NewRequest("PUT", "/api/messages/1", strings.NewReader(`{"content": "now https://example.org"}`))
+	req.Header.Set("If-Match", "*")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	handler.previewJobs.Wait()
+	if msg := getMessageWithHTML(t, router, "/api/messages/1"); len(msg.Previews) != 1 || msg.Previews[0].URL != "https://example.org" {
+		t.Errorf("Expected preview of the new link, got %+v", msg.Previews)
+	}
+	if len(fetcher.fetched) != 3 {
+		t.Errorf("Expected 3 fetches, got %v", fetcher.fetched)
+	}
+
+	// Messages without links are not fetched
+	doAuthRequest(router, "POST", "/api/messages", "", map[string]string{"username": "bob", "content": "no links"})
+	handler.previewJobs.Wait()
+	if len(fetcher.fetched) != 3 {
+		t.Errorf("Unexpected fetch: %v", fetcher.fetched)
+	}
+}
+
+// blockingFetcher counts fetches and holds each one until release is closed
+type blockingFetcher struct {
+	mutex   sync.Mutex
+	started int
+	release chan struct{}
+}
+
+func (f *blockingFetcher) Fetch(ctx context.Context, url string) (*content.LinkPreview, error) {
+	f.mutex.Lock()
+	f.started++
+	f.mutex.Unlock()
+	<-f.release
+	return &content.LinkPreview{Title: "Title of " + url}, nil
+}
+
+func TestLinkPreviewsBounded(t *testing.T) {
+	handler := setupTestHandler()
+	fetcher := &blockingFetcher{release: make(chan struct{})}
+	handler.UsePreviews(fetcher)
+	router := handler.SetupRoutes()
+
+	for i := 0; i < maxPreviewJobs+5; i++ {
+		doAuthRequest(router, "POST", "/api/messages", "", map[string]string{
+			"username": "alice",
+			"content":  "read https://example.com/post",
+		})
+	}
+	close(fetcher.release)
+	handler.previewJobs.Wait()
+
+	if fetcher.started != maxPreviewJobs {
+		t.Errorf("Expected %d fetches while the others were busy, got %d", maxPreviewJobs, fetcher.started)
+	}
+	// Finished fetches free their slot
+	doAuthRequest(router, "POST", "/api/messages", "", map[string]string{"username": "bob", "content": "see https://example.org"})
+	handler.previewJobs.Wait()
+	if fetcher.started != maxPreviewJobs+1 {
+		t.Errorf("Expected a fetch after the others finished, got %d", fetcher.started)
+	}
+}
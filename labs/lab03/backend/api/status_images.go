@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStatusImageUpstream is where status images come from unless configured otherwise
+const DefaultStatusImageUpstream = "https://http.cat/{code}.jpg"
+
+// maxStatusImageBytes caps the size of images fetched from upstream
+const maxStatusImageBytes = 5 << 20
+
+// StatusImageConfig configures the GET /api/status/{code}/image proxy
+type StatusImageConfig struct {
+	// Upstream is a URL template in which {code} is replaced by the status code.
+	// Leave it empty to never fetch, e.g. in an air-gapped environment.
+	Upstream string
+	// CacheDir keeps fetched images across restarts. Empty caches in memory only.
+	CacheDir string
+	// FallbackDir holds local images named <code>.jpg, <code>.png,
+	// default.jpg or default.png, used when nothing else is available.
+	FallbackDir string
+	// Client fetches upstream images; nil uses a client with a 10 second timeout
+	Client *http.Client
+}
+
+// UseStatusImages serves status images through the server instead of linking to upstream
+func (h *Handler) UseStatusImages(cfg StatusImageConfig) {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	h.images = &statusImages{cfg: cfg, memory: make(map[int]statusImage)}
+}
+
+// errNoStatusImage means no image was found anywhere
+var errNoStatusImage = errors.New("no image available")
+
+// Where a status image was found, reported in the X-Image-Source header
+const (
+	imageSourceCache    = "cache"
+	imageSourceUpstream = "upstream"
+	imageSourceFallback = "fallback"
+)
+
+type statusImage struct {
+	contentType string
+	data        []byte
+}
+
+// statusImages looks images up in memory, then CacheDir, then upstream, then FallbackDir
+type statusImages struct {
+	cfg    StatusImageConfig
+	mutex  sync.RWMutex
+	memory map[int]statusImage
+}
+
+func (s *statusImages) get(ctx context.Context, code int) (statusImage, string, error) {
+	s.mutex.RLock()
+	img, ok := s.memory[code]
+	s.mutex.RUnlock()
+	if ok {
+		return img, imageSourceCache, nil
+	}
+
+	if s.cfg.CacheDir != "" {
+		if img, err := readImage(filepath.Join(s.cfg.CacheDir, strconv.Itoa(code))); err == nil {
+			s.remember(code, img)
+			return img, imageSourceCache, nil
+		}
+	}
+
+	if s.cfg.Upstream != "" {
+		img, err := s.fetch(ctx, code)
+		if err == nil {
+			s.remember(code, img)
+			if s.cfg.CacheDir != "" {
+				s.persist(code, img)
+			}
+			return img, imageSourceUpstream, nil
+		}
+	}
+
+	if s.cfg.FallbackDir != "" {
+		for _, name := range []string{strconv.Itoa(code) + ".jpg", strconv.Itoa(code) + ".png", "default.jpg", "default.png"} {
+			if img, err := readImage(filepath.Join(s.cfg.FallbackDir, name)); err == nil {
+				return img, imageSourceFallback, nil
+			}
+		}
+	}
+	return statusImage{}, "", errNoStatusImage
+}
+
+func (s *statusImages) remember(code int, img statusImage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.memory[code] = img
+}
+
+// persist writes img to the cache directory; failures only cost a refetch later
+func (s *statusImages) persist(code int, img statusImage) {
+	if err := os.MkdirAll(s.cfg.CacheDir, 0o755); err != nil {
+		return
+	}
+	path := filepath.Join(s.cfg.CacheDir, strconv.Itoa(code))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, img.data, 0o644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+func (s *statusImages) fetch(ctx context.Context, code int) (statusImage, error) {
+	url := strings.ReplaceAll(s.cfg.Upstream, "{code}", strconv.Itoa(code))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return statusImage{}, err
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return statusImage{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusImage{}, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxStatusImageBytes+1))
+	if err != nil {
+		return statusImage{}, err
+	}
+	if len(data) > maxStatusImageBytes {
+		return statusImage{}, errors.New("upstream image too large")
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return statusImage{}, fmt.Errorf("upstream returned %s, not an image", contentType)
+	}
+	return statusImage{contentType: contentType, data: data}, nil
+}
+
+func readImage(path string) (statusImage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return statusImage{}, err
+	}
+	return statusImage{contentType: http.DetectContentType(data), data: data}, nil
+}
+
+// GetHTTPStatusImage handles GET /api/status/{code}/image
+func (h *Handler) GetHTTPStatusImage(w http.ResponseWriter, r *http.Request) {
+	if h.images == nil {
+		h.writeError(w, http.StatusNotFound, "Status images are not served by this server")
+		return
+	}
+	code, ok := h.pathInt(w, r, "code")
+	if !ok {
+		return
+	}
+	if code < 100 || code > 599 {
+		h.writeError(w, http.StatusBadRequest, "Status code must be between 100 and 599")
+		return
+	}
+	img, source, err := h.images.get(r.Context(), code)
+	if err != nil {
+		h.writeError(w, http.StatusBadGateway, "No image available for this status code")
+		return
+	}
+	w.Header().Set("Content-Type", img.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.data)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("X-Image-Source", source)
+	w.WriteHeader(http.StatusOK)
+	w.Write(img.data)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"lab03-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func getStatusImage(router http.Handler, code string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/api/status/"+code+"/image", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestStatusImageProxy(t *testing.T) {
+	fetches := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.URL.Path != "/418.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(pngImage)
+	}))
+
+	cacheDir := t.TempDir()
+	handler := setupTestHandler()
+	handler.UseStatusImages(StatusImageConfig{Upstream: upstream.URL + "/{code}.png", CacheDir: cacheDir})
+	router := handler.SetupRoutes()
+
+	rr := getStatusImage(router, "418")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected png image, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("X-Image-Source") != imageSourceUpstream {
+		t.Errorf("Expected upstream source, got %s", rr.Header().Get("X-Image-Source"))
+	}
+	if rr := getStatusImage(router, "418"); rr.Header().Get("X-Image-Source") != imageSourceCache || fetches != 1 {
+		t.Errorf("Expected cached image without refetch, got %s after %d fetches", rr.Header().Get("X-Image-Source"), fetches)
+	}
+	if rr := getStatusImage(router, "404"); rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 when upstream has no image, got %d", rr.Code)
+	}
+
+	// A new server with upstream gone still has the disk cache
+	upstream.Close()
+	handler = setupTestHandler()
+	handler.UseStatusImages(StatusImageConfig{Upstream: upstream.URL + "/{code}.png", CacheDir: cacheDir})
+	if rr := getStatusImage(handler.SetupRoutes(), "418"); rr.Code != http.StatusOK || rr.Header().Get("X-Image-Source") != imageSourceCache {
+		t.Errorf("Expected image from disk cache, got %d %s", rr.Code, rr.Header().Get("X-Image-Source"))
+	}
+}
+
+func TestStatusImageFallback(t *testing.T) {
+	fallbackDir := t.TempDir()
+	os.WriteFile(filepath.Join(fallbackDir, "default.png"), pngImage, 0o644)
+
+	// No upstream, as in an air-gapped environment
+	handler := setupTestHandler()
+	handler.UseStatusImages(StatusImageConfig{FallbackDir: fallbackDir})
+	router := handler.SetupRoutes()
+
+	rr := getStatusImage(router, "503")
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Image-Source") != imageSourceFallback {
+		t.Errorf("Expected fallback image, got %d %s", rr.Code, rr.Header().Get("X-Image-Source"))
+	}
+	if rr := getStatusImage(router, "999"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid code, got %d", rr.Code)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/status/503", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response struct {
+		Data models.HTTPStatusResponse `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&response)
+	want := models.HTTPStatusResponse{
+		StatusCode:  503,
+		ImageURL:    "/api/status/503/image",
+		Description: "Service Unavailable",
+		Category:    "Server Error",
+		Reference:   "RFC 9110, Section 15.6.4",
+	}
+	if response.Data != want {
+		t.Errorf("Expected %+v, got %+v", want, response.Data)
+	}
+}
+
+func TestStatusImageDisabled(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+	if rr := getStatusImage(router, "200"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when images are not served, got %d", rr.Code)
+	}
+}
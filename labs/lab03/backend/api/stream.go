@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"lab03-backend/storage"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// eventReplaySize is how many recent events clients can resume from
+	eventReplaySize = 256
+	// defaultHeartbeat keeps idle streams open through proxies
+	defaultHeartbeat = 15 * time.Second
+)
+
+// resetEvent tells a client it missed events and should reload messages
+const resetEvent = "reset"
+
+var upgrader = websocket.Upgrader{
+	// CORS already allows any origin for this API
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// lastEventID reads the resume position from the Last-Event-ID header
+// or, for clients that cannot set headers, the last_event_id query parameter.
+func lastEventID(r *http.Request) (uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// StreamMessages handles GET /api/messages/stream
+// Sends create, update and delete events as Server-Sent Events.
+func (h *Handler) StreamMessages(w http.ResponseWriter, r *http.Request) {
+	lastID, err := lastEventID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.writeError(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	replay, events, cancel, complete := h.events.Subscribe(lastID)
+	defer cancel()
+
+	// The server write timeout would otherwise cut the stream off
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", resetEvent)
+	}
+	for _, ev := range replay {
+		writeSSE(w, ev)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client resumes with Last-Event-ID
+				return
+			}
+			writeSSE(w, ev)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, ev storage.MessageEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error encoding event: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+}
+
+// MessagesWebSocket handles GET /api/messages/ws
+// Sends the same events as StreamMessages as JSON WebSocket messages.
+func (h *Handler) MessagesWebSocket(w http.ResponseWriter, r *http.Request) {
+	lastID, err := lastEventID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid last_event_id")
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	replay, events, cancel, complete := h.events.Subscribe(lastID)
+	defer cancel()
+
+	// Read until the client goes away; incoming messages are ignored
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if !complete {
+		if err := conn.WriteJSON(map[string]string{"type": resetEvent}); err != nil {
+			return
+		}
+	}
+	for _, ev := range replay {
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind"))
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(h.heartbeat)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
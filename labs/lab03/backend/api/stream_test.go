@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"lab03-backend/models"
+	"lab03-backend/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readSSE reads one event from the stream, skipping comments
+func readSSE(t *testing.T, r *bufio.Reader) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Reading stream failed: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			fields["comment"] = line
+			return fields
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		fields[name] = value
+	}
+}
+
+func postMessage(t *testing.T, url, content string) {
+	t.Helper()
+	body, _ := json.Marshal(models.CreateMessageRequest{Username: "alice", Content: content})
+	resp, err := http.Post(url+"/api/messages", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestStreamMessages(t *testing.T) {
+	handler := NewHandler(storage.NewMemoryStorage())
+	handler.heartbeat = 50 * time.Millisecond
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	postMessage(t, server.URL, "before")
+
+	// Resume after the first event: nothing to replay yet
+	req, _ := http.NewRequest("GET", server.URL+"/api/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	postMessage(t, server.URL, "after")
+	ev := readSSE(t, reader)
+	if ev["id"] != "2" || ev["event"] != "create" || !strings.Contains(ev["data"], `"content":"after"`) {
+		t.Errorf("Unexpected event: %v", ev)
+	}
+
+	if ev := readSSE(t, reader); ev["comment"] != ": heartbeat" {
+		t.Errorf("Expected heartbeat, got %v", ev)
+	}
+
+	// Reconnecting from the start replays both events
+	req, _ = http.NewRequest("GET", server.URL+"/api/messages/stream?last_event_id=0", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+	if resp2.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp2.StatusCode)
+	}
+}
+
+func TestStreamMessagesInvalidLastEventID(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+	req, _ := http.NewRequest("GET", "/api/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %v, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestMessagesWebSocket(t *testing.T) {
+	handler := NewHandler(storage.NewMemoryStorage())
+	server := httptest.NewServer(handler.SetupRoutes())
+	defer server.Close()
+
+	postMessage(t, server.URL, "first")
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/messages/ws?last_event_id=0"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	postMessage(t, server.URL, "second")
+
+	var ev storage.MessageEvent
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if ev.ID != 2 || ev.Type != storage.EventCreate || ev.Message == nil || ev.Message.Content != "second" {
+		t.Errorf("Unexpected event: %+v", ev)
+	}
+}
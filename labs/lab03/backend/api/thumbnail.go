@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // Register decoders for thumbnails
+	_ "image/jpeg"
+	"image/png"
+	"io"
+)
+
+// thumbnailSize is the longest side of a thumbnail in pixels
+const thumbnailSize = 256
+
+// maxImagePixels guards against decompression bombs when making thumbnails
+const maxImagePixels = 40_000_000
+
+// thumbnailTypes are the image formats thumbnails are made for
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+var errImageTooLarge = errors.New("image is too large to decode")
+
+// imageSize reads the dimensions of an image without decoding it
+func imageSize(r io.Reader) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// makeThumbnail decodes the image in r and returns a PNG no larger than thumbnailSize
+func makeThumbnail(r io.Reader, width, height int) ([]byte, error) {
+	if width*height > maxImagePixels {
+		return nil, errImageTooLarge
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleDown(src, thumbnailSize)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleDown shrinks src to fit in a max x max square, averaging the source
+// pixels that fall into each destination pixel. Smaller images are returned as is.
+func scaleDown(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	tw, th := max, h*max/w
+	if h > w {
+		tw, th = w*max/h, max
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// RGBA() is premultiplied 16-bit, RGBA pixels are premultiplied 8-bit
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
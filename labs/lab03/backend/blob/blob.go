@@ -0,0 +1,100 @@
+// Package blob stores opaque binary objects by key.
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Common errors
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps blobs by key. Keys may contain letters, digits, '.', '_' and '-'
+// and must not start with '.'.
+type Store interface {
+	// Put stores the contents of r under key and returns the number of bytes written.
+	// An existing blob with the same key is replaced.
+	Put(key string, r io.Reader) (int64, error)
+	// Open returns the blob stored under key, or ErrNotFound
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(key string) error
+}
+
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// ValidKey reports whether key can be used with a Store
+func ValidKey(key string) bool {
+	return len(key) <= 200 && validKey.MatchString(key)
+}
+
+// FSStore is a Store that keeps each blob as a file in one directory
+type FSStore struct {
+	dir string
+}
+
+var _ Store = (*FSStore)(nil)
+
+// NewFSStore returns a store in dir, creating the directory if needed
+func NewFSStore(dir string) (*FSStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSStore{dir: dir}, nil
+}
+
+// Put writes to a temporary file first, so readers never see a partial blob
+func (s *FSStore) Put(key string, r io.Reader) (int64, error) {
+	if !ValidKey(key) {
+		return 0, ErrInvalidKey
+	}
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// Open opens the file holding key
+func (s *FSStore) Open(key string) (io.ReadSeekCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	f, err := os.Open(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Delete removes the file holding key
+func (s *FSStore) Delete(key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
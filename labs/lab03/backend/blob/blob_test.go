@@ -0,0 +1,60 @@
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFSStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFSStore(dir)
+	if err != nil {
+		t.Fatalf("NewFSStore failed: %v", err)
+	}
+
+	n, err := store.Put("abc", strings.NewReader("hello world"))
+	if err != nil || n != 11 {
+		t.Fatalf("Put returned %d, %v", n, err)
+	}
+	f, err := store.Open("abc")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	f.Seek(6, io.SeekStart)
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "world" {
+		t.Errorf("Expected %q after seek, got %q", "world", data)
+	}
+
+	if err := store.Delete("abc"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Open("abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete("abc"); err != nil {
+		t.Errorf("Deleting a missing blob should succeed, got %v", err)
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected empty directory, found %d entries", len(entries))
+	}
+}
+
+func TestFSStoreRejectsInvalidKeys(t *testing.T) {
+	store, _ := NewFSStore(t.TempDir())
+	for _, key := range []string{"", "../escape", "a/b", ".hidden", "a b"} {
+		if _, err := store.Put(key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+		if _, err := store.Open(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q): expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
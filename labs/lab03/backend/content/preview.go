@@ -0,0 +1,226 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// MaxPreviews is how many links per message get a preview
+const MaxPreviews = 3
+
+// maxPreviewText caps titles and descriptions in runes
+const maxPreviewText = 300
+
+// LinkPreview describes a page linked from a message
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Fetcher looks up the preview of a link
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*LinkPreview, error)
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// ExtractURLs returns the distinct http(s) links in text, in order, at most MaxPreviews
+func ExtractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = trimURL(u)
+		if seen[u] || u == "http://" || u == "https://" {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+		if len(urls) == MaxPreviews {
+			break
+		}
+	}
+	return urls
+}
+
+// trimURL drops punctuation that ends the sentence around a link, and
+// closing brackets that were not opened inside it, as in "(see https://x.y/a)."
+func trimURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?*_~")
+		for _, pair := range []string{"()", "[]"} {
+			if strings.HasSuffix(trimmed, pair[1:]) &&
+				strings.Count(trimmed, pair[:1]) < strings.Count(trimmed, pair[1:]) {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+		}
+		if trimmed == u {
+			return u
+		}
+		u = trimmed
+	}
+}
+
+// ErrNotHTML is returned by HTTPFetcher for links that are not web pages
+var ErrNotHTML = errors.New("link is not an HTML page")
+
+// errPrivateAddress stops previews from probing the server's own network
+var errPrivateAddress = errors.New("link resolves to a private address")
+
+// HTTPFetcher fetches pages and reads their title and description from
+// Open Graph tags, falling back to <title> and <meta name="description">.
+type HTTPFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewHTTPFetcher returns a fetcher that refuses loopback, private and
+// link-local addresses unless allowPrivate is set, e.g. in tests.
+func NewHTTPFetcher(allowPrivate bool) *HTTPFetcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	// No proxy, so the address check sees the real destination
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	}
+	return &HTTPFetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+		maxBytes: 512 << 10,
+	}
+}
+
+// Fetch downloads the start of the page at url and extracts its metadata
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (*LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "lab03-link-preview/1.0")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return nil, ErrNotHTML
+	}
+	preview := parsePreview(io.LimitReader(resp.Body, f.maxBytes))
+	preview.URL = url
+	return preview, nil
+}
+
+// parsePreview reads the page head for its title and description
+func parsePreview(r io.Reader) *LinkPreview {
+	var title, ogTitle, description, ogDescription string
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return finishPreview(ogTitle, title, ogDescription, description)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				var key, value string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(string(v))
+					case "content":
+						value = string(v)
+					}
+				}
+				switch key {
+				case "og:title":
+					ogTitle = value
+				case "og:description":
+					ogDescription = value
+				case "description":
+					description = value
+				}
+			case "body":
+				// Metadata lives in the head
+				return finishPreview(ogTitle, title, ogDescription, description)
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		}
+	}
+}
+
+func finishPreview(ogTitle, title, ogDescription, description string) *LinkPreview {
+	return &LinkPreview{
+		Title:       clip(firstNonEmpty(ogTitle, title)),
+		Description: clip(firstNonEmpty(ogDescription, description)),
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clip collapses whitespace and shortens s to maxPreviewText runes
+func clip(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxPreviewText {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxPreviewText-1]) + "…"
+}
@@ -0,0 +1,84 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"no links here", nil},
+		{"see https://example.com/a.", []string{"https://example.com/a"}},
+		{"(see https://en.wikipedia.org/wiki/Go_(programming_language))", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"[docs](https://go.dev/doc) and https://go.dev/doc again", []string{"https://go.dev/doc"}},
+		{"ftp://old.example http://a.example https://b.example http://c.example http://d.example",
+			[]string{"http://a.example", "https://b.example", "http://c.example"}},
+	}
+	for _, tt := range tests {
+		if got := ExtractURLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExtractURLs(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/og":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Plain title</title>
+				<meta property="og:title" content="OG title">
+				<meta name="description" content="  Plain
+				description ">
+				</head><body><meta property="og:description" content="ignored"></body></html>`))
+		case "/title":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<title>Only &amp; title</title><meta name="description" content="Desc">`))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	fetcher := NewHTTPFetcher(true)
+
+	p, err := fetcher.Fetch(context.Background(), server.URL+"/og")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	want := LinkPreview{URL: server.URL + "/og", Title: "OG title", Description: "Plain description"}
+	if *p != want {
+		t.Errorf("Expected %+v, got %+v", want, *p)
+	}
+	if p, _ := fetcher.Fetch(context.Background(), server.URL+"/title"); p.Title != "Only & title" || p.Description != "Desc" {
+		t.Errorf("Unexpected preview: %+v", p)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/image"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Expected ErrNotHTML, got %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("Expected error for 404")
+	}
+
+	// The default fetcher refuses to reach the local network
+	if _, err := NewHTTPFetcher(false).Fetch(context.Background(), server.URL+"/og"); err == nil || !strings.Contains(err.Error(), "private address") {
+		t.Errorf("Expected private address error, got %v", err)
+	}
+}
+
+func TestClip(t *testing.T) {
+	long := strings.Repeat("é", maxPreviewText+10)
+	if got := []rune(clip(long)); len(got) != maxPreviewText || got[len(got)-1] != '…' {
+		t.Errorf("Expected %d runes ending in an ellipsis, got %d", maxPreviewText, len(got))
+	}
+}
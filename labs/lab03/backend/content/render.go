@@ -0,0 +1,95 @@
+// Package content renders message content and extracts link previews from it.
+package content
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Format is how message content is written
+type Format string
+
+const (
+	// FormatPlain is shown as typed
+	FormatPlain Format = "plain"
+	// FormatMarkdown is Markdown in which raw HTML is dropped
+	FormatMarkdown Format = "markdown"
+	// FormatHTML is Markdown that may contain HTML; elements and
+	// attributes outside the allowlist are removed
+	FormatHTML Format = "html"
+)
+
+// Formats lists every supported format
+var Formats = []Format{FormatPlain, FormatMarkdown, FormatHTML}
+
+// Valid reports whether f is a supported format
+func (f Format) Valid() bool {
+	for _, known := range Formats {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(gmhtml.WithHardWraps()),
+	)
+	markdownWithHTML = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(gmhtml.WithHardWraps(), gmhtml.WithUnsafe()),
+	)
+	allowlist = newAllowlist()
+)
+
+// newAllowlist returns the policy all rendered HTML passes through.
+// Images are left out so messages can't embed tracking pixels.
+func newAllowlist() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "strong", "b", "em", "i", "del", "s", "sub", "sup",
+		"code", "pre", "blockquote", "ul", "ol", "li",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	return p
+}
+
+// Render returns content as HTML that is safe to insert into a page.
+// An empty format is treated as plain.
+func Render(format Format, text string) string {
+	switch format {
+	case FormatMarkdown, FormatHTML:
+		md := markdown
+		if format == FormatHTML {
+			md = markdownWithHTML
+		}
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(text), &buf); err != nil {
+			// Rendering into a buffer only fails on broken extensions
+			return renderPlain(text)
+		}
+		return strings.TrimSpace(allowlist.Sanitize(buf.String()))
+	default:
+		return renderPlain(text)
+	}
+}
+
+func renderPlain(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n")
+}
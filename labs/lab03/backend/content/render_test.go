@@ -0,0 +1,52 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		text   string
+		want   string
+	}{
+		{"plain escapes", FormatPlain, "<b>hi</b>\nthere", "&lt;b&gt;hi&lt;/b&gt;<br>\nthere"},
+		{"empty format is plain", "", "*not bold*", "*not bold*"},
+		{"markdown", FormatMarkdown, "**bold** and `code`", "<p><strong>bold</strong> and <code>code</code></p>"},
+		{"markdown drops raw html", FormatMarkdown, "hi <b>there</b>", "<p>hi there</p>"},
+		{"html keeps allowed tags", FormatHTML, "hi <b>there</b>", "<p>hi <b>there</b></p>"},
+		{"html removes scripts", FormatHTML, "<script>alert(1)</script>ok", "ok"},
+		{"html removes handlers", FormatHTML, `<b onclick="alert(1)">x</b>`, "<p><b>x</b></p>"},
+		{"links are nofollow", FormatMarkdown, "[site](https://example.com)",
+			`<p><a href="https://example.com" rel="nofollow noopener" target="_blank">site</a></p>`},
+		{"javascript links are dropped", FormatMarkdown, "[x](javascript:alert(1))", "<p>x</p>"},
+		{"images are dropped", FormatMarkdown, "![pixel](https://tracker.example/p.gif)", "<p></p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.format, tt.text); got != tt.want {
+				t.Errorf("Render(%q, %q)\n got: %s\nwant: %s", tt.format, tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderCodeBlockLanguage(t *testing.T) {
+	got := Render(FormatMarkdown, "```go\nfmt.Println(\"<hi>\")\n```")
+	if !strings.Contains(got, `<code class="language-go">`) || !strings.Contains(got, "&lt;hi&gt;") {
+		t.Errorf("Unexpected code block: %s", got)
+	}
+}
+
+func TestFormatValid(t *testing.T) {
+	for _, f := range Formats {
+		if !f.Valid() {
+			t.Errorf("%q should be valid", f)
+		}
+	}
+	if Format("rtf").Valid() || Format("").Valid() {
+		t.Error("Unknown formats should be invalid")
+	}
+}
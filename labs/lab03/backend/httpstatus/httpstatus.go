@@ -0,0 +1,135 @@
+// Package httpstatus is the IANA HTTP Status Code Registry.
+//
+// Source: https://www.iana.org/assignments/http-status-codes
+package httpstatus
+
+import "sort"
+
+// Category groups status codes by their first digit
+type Category string
+
+const (
+	Informational Category = "Informational"
+	Success       Category = "Success"
+	Redirection   Category = "Redirection"
+	ClientError   Category = "Client Error"
+	ServerError   Category = "Server Error"
+)
+
+// Status is one registered status code
+type Status struct {
+	Code        int      `json:"code"`
+	Description string   `json:"description"`
+	Category    Category `json:"category"`
+	Reference   string   `json:"reference"`
+}
+
+// CategoryOf returns the category of any code in 100-599, or "" outside that range
+func CategoryOf(code int) Category {
+	switch code / 100 {
+	case 1:
+		return Informational
+	case 2:
+		return Success
+	case 3:
+		return Redirection
+	case 4:
+		return ClientError
+	case 5:
+		return ServerError
+	}
+	return ""
+}
+
+// Lookup returns the registered status for code
+func Lookup(code int) (Status, bool) {
+	s, ok := registry[code]
+	return s, ok
+}
+
+// All returns every registered status ordered by code
+func All() []Status {
+	out := make([]Status, 0, len(registry))
+	for _, s := range registry {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+var registry = func() map[int]Status {
+	entries := []struct {
+		code int
+		desc string
+		ref  string
+	}{
+		{100, "Continue", "RFC 9110, Section 15.2.1"},
+		{101, "Switching Protocols", "RFC 9110, Section 15.2.2"},
+		{102, "Processing", "RFC 2518"},
+		{103, "Early Hints", "RFC 8297"},
+		{200, "OK", "RFC 9110, Section 15.3.1"},
+		{201, "Created", "RFC 9110, Section 15.3.2"},
+		{202, "Accepted", "RFC 9110, Section 15.3.3"},
+		{203, "Non-Authoritative Information", "RFC 9110, Section 15.3.4"},
+		{204, "No Content", "RFC 9110, Section 15.3.5"},
+		{205, "Reset Content", "RFC 9110, Section 15.3.6"},
+		{206, "Partial Content", "RFC 9110, Section 15.3.7"},
+		{207, "Multi-Status", "RFC 4918"},
+		{208, "Already Reported", "RFC 5842"},
+		{226, "IM Used", "RFC 3229"},
+		{300, "Multiple Choices", "RFC 9110, Section 15.4.1"},
+		{301, "Moved Permanently", "RFC 9110, Section 15.4.2"},
+		{302, "Found", "RFC 9110, Section 15.4.3"},
+		{303, "See Other", "RFC 9110, Section 15.4.4"},
+		{304, "Not Modified", "RFC 9110, Section 15.4.5"},
+		{305, "Use Proxy", "RFC 9110, Section 15.4.6"},
+		{306, "(Unused)", "RFC 9110, Section 15.4.7"},
+		{307, "Temporary Redirect", "RFC 9110, Section 15.4.8"},
+		{308, "Permanent Redirect", "RFC 9110, Section 15.4.9"},
+		{400, "Bad Request", "RFC 9110, Section 15.5.1"},
+		{401, "Unauthorized", "RFC 9110, Section 15.5.2"},
+		{402, "Payment Required", "RFC 9110, Section 15.5.3"},
+		{403, "Forbidden", "RFC 9110, Section 15.5.4"},
+		{404, "Not Found", "RFC 9110, Section 15.5.5"},
+		{405, "Method Not Allowed", "RFC 9110, Section 15.5.6"},
+		{406, "Not Acceptable", "RFC 9110, Section 15.5.7"},
+		{407, "Proxy Authentication Required", "RFC 9110, Section 15.5.8"},
+		{408, "Request Timeout", "RFC 9110, Section 15.5.9"},
+		{409, "Conflict", "RFC 9110, Section 15.5.10"},
+		{410, "Gone", "RFC 9110, Section 15.5.11"},
+		{411, "Length Required", "RFC 9110, Section 15.5.12"},
+		{412, "Precondition Failed", "RFC 9110, Section 15.5.13"},
+		{413, "Content Too Large", "RFC 9110, Section 15.5.14"},
+		{414, "URI Too Long", "RFC 9110, Section 15.5.15"},
+		{415, "Unsupported Media Type", "RFC 9110, Section 15.5.16"},
+		{416, "Range Not Satisfiable", "RFC 9110, Section 15.5.17"},
+		{417, "Expectation Failed", "RFC 9110, Section 15.5.18"},
+		{418, "(Unused)", "RFC 9110, Section 15.5.19"},
+		{421, "Misdirected Request", "RFC 9110, Section 15.5.20"},
+		{422, "Unprocessable Content", "RFC 9110, Section 15.5.21"},
+		{423, "Locked", "RFC 4918"},
+		{424, "Failed Dependency", "RFC 4918"},
+		{425, "Too Early", "RFC 8470"},
+		{426, "Upgrade Required", "RFC 9110, Section 15.5.22"},
+		{428, "Precondition Required", "RFC 6585"},
+		{429, "Too Many Requests", "RFC 6585"},
+		{431, "Request Header Fields Too Large", "RFC 6585"},
+		{451, "Unavailable For Legal Reasons", "RFC 7725"},
+		{500, "Internal Server Error", "RFC 9110, Section 15.6.1"},
+		{501, "Not Implemented", "RFC 9110, Section 15.6.2"},
+		{502, "Bad Gateway", "RFC 9110, Section 15.6.3"},
+		{503, "Service Unavailable", "RFC 9110, Section 15.6.4"},
+		{504, "Gateway Timeout", "RFC 9110, Section 15.6.5"},
+		{505, "HTTP Version Not Supported", "RFC 9110, Section 15.6.6"},
+		{506, "Variant Also Negotiates", "RFC 2295"},
+		{507, "Insufficient Storage", "RFC 4918"},
+		{508, "Loop Detected", "RFC 5842"},
+		{510, "Not Extended (OBSOLETED)", "RFC 2774"},
+		{511, "Network Authentication Required", "RFC 6585"},
+	}
+	m := make(map[int]Status, len(entries))
+	for _, e := range entries {
+		m[e.code] = Status{Code: e.code, Description: e.desc, Category: CategoryOf(e.code), Reference: e.ref}
+	}
+	return m
+}()
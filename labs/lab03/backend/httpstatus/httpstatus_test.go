@@ -0,0 +1,47 @@
+package httpstatus
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	s, ok := Lookup(429)
+	if !ok {
+		t.Fatal("429 should be registered")
+	}
+	if s.Description != "Too Many Requests" || s.Category != ClientError || s.Reference != "RFC 6585" {
+		t.Errorf("Unexpected status: %+v", s)
+	}
+	if _, ok := Lookup(299); ok {
+		t.Error("299 should not be registered")
+	}
+}
+
+func TestRegistryMatchesStdlib(t *testing.T) {
+	// net/http still uses the pre-RFC 9110 names for some codes
+	renamed := map[int]bool{306: true, 413: true, 414: true, 416: true, 418: true, 422: true, 510: true}
+	for _, s := range All() {
+		if renamed[s.Code] {
+			continue
+		}
+		if text := http.StatusText(s.Code); text != s.Description {
+			t.Errorf("%d: registry says %q, net/http says %q", s.Code, s.Description, text)
+		}
+	}
+}
+
+func TestAllSortedWithCategories(t *testing.T) {
+	all := All()
+	for i, s := range all {
+		if i > 0 && all[i-1].Code >= s.Code {
+			t.Errorf("Not sorted at %d", s.Code)
+		}
+		if s.Category != CategoryOf(s.Code) || s.Category == "" {
+			t.Errorf("%d: unexpected category %q", s.Code, s.Category)
+		}
+	}
+	if CategoryOf(600) != "" {
+		t.Error("600 should have no category")
+	}
+}
@@ -2,18 +2,65 @@ package main
 
 import (
 	"lab03-backend/api"
+	"lab03-backend/blob"
+	"lab03-backend/content"
 	"lab03-backend/storage"
 	"log"
 	"net/http"
+	"os"
 	"time"
+
+	"lab05/jwtservice"
 )
 
 func main() {
 
-	// TODO: Create a new memory storage instance
-	ms := new(storage.MemoryStorage)
-	// TODO: Create a new API handler with the storage
-	apiHandler := api.NewHandler(ms)
+	// Use SQLite when MESSAGES_DB is set, memory storage otherwise
+	var repo storage.MessageRepository = storage.NewMemoryStorage()
+	if path := os.Getenv("MESSAGES_DB"); path != "" {
+		sqlite, err := storage.OpenSQLiteStorage(path)
+		if err != nil {
+			log.Fatalf("Failed to open message database: %v", err)
+		}
+		defer sqlite.Close()
+		repo = sqlite
+	}
+	apiHandler := api.NewHandler(repo)
+	// Require tokens issued by the lab05 JWT service for changes
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		tokens, err := jwtservice.NewJWTService(secret)
+		if err != nil {
+			log.Fatalf("Failed to create JWT service: %v", err)
+		}
+		apiHandler.UseAuth(tokens)
+	} else {
+		log.Println("JWT_SECRET is not set, message changes are not authenticated")
+	}
+	// Serve status images locally when a cache or fallback directory is configured
+	cacheDir, fallbackDir := os.Getenv("STATUS_IMAGE_CACHE"), os.Getenv("STATUS_IMAGE_FALLBACK")
+	if cacheDir != "" || fallbackDir != "" {
+		upstream, ok := os.LookupEnv("STATUS_IMAGE_UPSTREAM")
+		if !ok {
+			upstream = api.DefaultStatusImageUpstream
+		}
+		apiHandler.UseStatusImages(api.StatusImageConfig{
+			Upstream:    upstream,
+			CacheDir:    cacheDir,
+			FallbackDir: fallbackDir,
+		})
+	}
+	// Accept file uploads when ATTACHMENTS_DIR is set
+	if dir := os.Getenv("ATTACHMENTS_DIR"); dir != "" {
+		blobs, err := blob.NewFSStore(dir)
+		if err != nil {
+			log.Fatalf("Failed to open attachment store: %v", err)
+		}
+		apiHandler.UseAttachments(api.AttachmentConfig{Blobs: blobs})
+	}
+	// Fetch link previews when LINK_PREVIEWS is set
+	if os.Getenv("LINK_PREVIEWS") != "" {
+		apiHandler.UsePreviews(content.NewHTTPFetcher(false))
+	}
 	// TODO: Setup routes using the handler
 	router := apiHandler.SetupRoutes()
 	// TODO: Configure server with:
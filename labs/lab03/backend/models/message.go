@@ -1,7 +1,9 @@
 package models
 
 import (
-	"errors"
+	"encoding/json"
+	"lab03-backend/content"
+	"lab03-backend/validation"
 	"time"
 )
 
@@ -15,6 +17,54 @@ type Message struct {
 	Content string `json:"content"`
 	// TODO: Add Timestamp field of type time.Time with json tag "timestamp"
 	Timestamp time.Time `json:"timestamp"`
+	// AuthorID is the user ID from the author's token, 0 for anonymous messages
+	AuthorID int `json:"author_id,omitempty"`
+	// Version starts at 1 and increases with every update
+	Version int `json:"version"`
+	// Attachments are the uploaded files the message refers to, in order
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Format says how Content is rendered; see HTML
+	Format content.Format `json:"format"`
+	// Previews describe the links in Content; they are added after the message is saved
+	Previews []content.LinkPreview `json:"previews,omitempty"`
+}
+
+// HTML returns the content rendered according to the message format
+func (m *Message) HTML() string {
+	return content.Render(m.Format, m.Content)
+}
+
+// MarshalJSON adds the rendered content as "html" next to the raw "content"
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message // Drops this method to avoid recursion
+	return json.Marshal(struct {
+		message
+		HTML string `json:"html"`
+	}{message(m), m.HTML()})
+}
+
+// Attachment describes an uploaded file.
+// It is downloaded from /api/attachments/{id}, and its thumbnail, if
+// Thumbnail is set, from /api/attachments/{id}/thumbnail.
+type Attachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// Width and Height are set for images
+	Width     int  `json:"width,omitempty"`
+	Height    int  `json:"height,omitempty"`
+	Thumbnail bool `json:"thumbnail"`
+	// UploaderID is the user ID from the uploader's token, 0 for anonymous uploads
+	UploaderID int       `json:"uploader_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// MessageRevision is the content of a message at one version
+type MessageRevision struct {
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // CreateMessageRequest represents the request to create a new message
@@ -23,6 +73,10 @@ type CreateMessageRequest struct {
 	Username string `json:"username" validate:"required"`
 	// TODO: Add Content field of type string with json tag "content" and validation tag "required"
 	Content string `json:"content" validate:"required"`
+	// Format is plain (the default), markdown or html
+	Format content.Format `json:"format,omitempty" validate:"omitempty,oneof=plain markdown html"`
+	// AttachmentIDs references previously uploaded attachments
+	AttachmentIDs []string `json:"attachment_ids,omitempty" validate:"max=10"`
 }
 
 // UpdateMessageRequest represents the request to update a message
@@ -31,6 +85,46 @@ type UpdateMessageRequest struct {
 	Content string `json:"content" validate:"required"`
 }
 
+// BatchRequest is the body of POST /api/messages/batch
+type BatchRequest struct {
+	// Atomic applies every operation or none; otherwise each one is applied on its own
+	Atomic     bool             `json:"atomic"`
+	Operations []BatchOperation `json:"operations" validate:"required,max=500"`
+}
+
+// BatchOperation is one create, update or delete in a batch.
+// Creates use the fields of CreateMessageRequest, updates ID, Version and
+// Content, and deletes ID and optionally Version.
+type BatchOperation struct {
+	Op string `json:"op" validate:"required,oneof=create update delete"`
+	ID int    `json:"id,omitempty" validate:"min=0"`
+	// Version plays the role of If-Match: required for updates, optional for deletes
+	Version       int            `json:"version,omitempty" validate:"min=0"`
+	Username      string         `json:"username,omitempty"`
+	Content       string         `json:"content,omitempty"`
+	Format        content.Format `json:"format,omitempty" validate:"omitempty,oneof=plain markdown html"`
+	AttachmentIDs []string       `json:"attachment_ids,omitempty" validate:"max=10"`
+}
+
+// BatchItemResult is the outcome of the operation at Index.
+// Status is the HTTP status the operation would have had on its own,
+// or 424 for operations not applied because another one failed.
+type BatchItemResult struct {
+	Index   int                     `json:"index"`
+	Status  int                     `json:"status"`
+	Message *Message                `json:"message,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+	Errors  []validation.FieldError `json:"errors,omitempty"`
+}
+
+// BatchResponse is the data of POST /api/messages/batch
+type BatchResponse struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
 // HTTPStatusResponse represents the response for HTTP status code endpoint
 type HTTPStatusResponse struct {
 	// TODO: Add StatusCode field of type int with json tag "status_code"
@@ -39,6 +133,18 @@ type HTTPStatusResponse struct {
 	ImageURL string `json:"image_url"`
 	// TODO: Add Description field of type string with json tag "description"
 	Description string `json:"description"`
+	// Category is the class of the code, e.g. "Client Error"
+	Category string `json:"category,omitempty"`
+	// Reference is the specification that defines a registered code
+	Reference string `json:"reference,omitempty"`
+}
+
+// HealthResponse is the data of GET /api/health
+type HealthResponse struct {
+	Status        string    `json:"status"`
+	Message       string    `json:"message"`
+	Timestamp     time.Time `json:"timestamp"`
+	TotalMessages int       `json:"total_messages"`
 }
 
 // APIResponse represents a generic API response
@@ -49,8 +155,15 @@ type APIResponse struct {
 	Data interface{} `json:"data,omitempty"`
 	// TODO: Add Error field of type string with json tag "error,omitempty"
 	Error string `json:"error,omitempty"`
+	// Errors lists rejected request fields when Success is false
+	Errors []validation.FieldError `json:"errors,omitempty"`
+	// NextCursor is set on list responses when another page follows
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
+// defaultFormat is the format of messages that don't choose one
+const defaultFormat = content.FormatPlain
+
 // NewMessage creates a new message with the current timestamp
 func NewMessage(id int, username, content string) *Message {
 	// TODO: Return a new Message instance with provided parameters and current timestamp
@@ -59,31 +172,17 @@ func NewMessage(id int, username, content string) *Message {
 	msg.Username = username
 	msg.Content = content
 	msg.Timestamp = time.Now()
+	msg.Version = 1
+	msg.Format = defaultFormat
 	return msg
 }
 
-// Validate checks if the create message request is valid
+// Validate checks the create message request against its validate tags
 func (r *CreateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Username is not empty
-	if len(r.Username) == 0 {
-		return errors.New("invalid username: must be not empty")
-	}
-	// Check if Content is not empty
-	if len(r.Content) == 0 {
-		return errors.New("invalid content: must be not empty")
-	}
-	// Return appropriate error messages
-	return nil
-}
-
-// Validate checks if the update message request is valid
+	return validation.Struct(r)
+}
+
+// Validate checks the update message request against its validate tags
 func (r *UpdateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Content is not empty
-	if len(r.Content) == 0 {
-		return errors.New("invalid content: must be not empty")
-	}
-	// Return appropriate error messages
-	return nil
+	return validation.Struct(r)
 }
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"errors"
+	"lab03-backend/models"
+)
+
+// BatchOpType names the kind of a batch operation
+type BatchOpType string
+
+const (
+	BatchCreate BatchOpType = "create"
+	BatchUpdate BatchOpType = "update"
+	BatchDelete BatchOpType = "delete"
+)
+
+// BatchOp is one operation of a batch
+type BatchOp struct {
+	Type BatchOpType
+	// Draft is the message to create, as passed to Insert
+	Draft *models.Message
+	// ID and Version select the message to update or delete.
+	// A zero Version skips the version check.
+	ID      int
+	Version int
+	// Content is the new content of an update
+	Content string
+}
+
+// BatchResult is the outcome of one BatchOp
+type BatchResult struct {
+	// Message is the created or updated message, nil for deletes and failures
+	Message *models.Message
+	Err     error
+}
+
+// ErrBatchAborted is the result of operations that were not applied because
+// another operation of an atomic batch failed
+var ErrBatchAborted = errors.New("batch aborted by a failed operation")
+
+// errUnknownBatchOp is the result of an operation with an unknown type
+var errUnknownBatchOp = errors.New("unknown batch operation")
+
+// abortBatch marks every result except the failed one as aborted
+func abortBatch(results []BatchResult, failed int) []BatchResult {
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+	return results
+}
@@ -0,0 +1,478 @@
+package storage
+
+import (
+	"fmt"
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"testing"
+	"time"
+)
+
+// runRepositoryConformance runs the shared MessageRepository suite.
+// newRepo must return an empty repository for every call.
+func runRepositoryConformance(t *testing.T, newRepo func(t *testing.T) MessageRepository) {
+	t.Run("CRUD", func(t *testing.T) { testRepositoryCRUD(t, newRepo(t)) })
+	t.Run("Errors", func(t *testing.T) { testRepositoryErrors(t, newRepo(t)) })
+	t.Run("Concurrency", func(t *testing.T) { testRepositoryConcurrency(t, newRepo(t)) })
+	t.Run("List", func(t *testing.T) { testRepositoryList(t, newRepo(t)) })
+	t.Run("Versions", func(t *testing.T) { testRepositoryVersions(t, newRepo(t)) })
+	t.Run("Attachments", func(t *testing.T) { testRepositoryAttachments(t, newRepo(t)) })
+	t.Run("FormatAndPreviews", func(t *testing.T) { testRepositoryFormatAndPreviews(t, newRepo(t)) })
+	t.Run("Batch", func(t *testing.T) { testRepositoryBatch(t, newRepo(t)) })
+	t.Run("BatchSameID", func(t *testing.T) { testRepositoryBatchSameID(t, newRepo(t)) })
+}
+
+func testRepositoryCRUD(t *testing.T, storage MessageRepository) {
+
+	// Test Create
+	message, err := storage.Create("testuser", "test content")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if message == nil {
+		t.Fatal("Create returned nil message")
+	}
+
+	// Test GetByID
+	retrieved, err := storage.GetByID(1)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+
+	if retrieved == nil {
+		t.Fatal("GetByID returned nil message")
+	}
+
+	// Test GetAll
+	messages, err := storage.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("Expected 1 message, got %d", len(messages))
+	}
+
+	// Test Update
+	updated, err := storage.Update(1, "updated content")
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if updated == nil {
+		t.Fatal("Update returned nil message")
+	}
+
+	// Test Delete
+	err = storage.Delete(1)
+	if err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Verify deletion
+	count, err := storage.Count()
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected empty storage after delete, got %d messages", count)
+	}
+}
+
+func testRepositoryErrors(t *testing.T, storage MessageRepository) {
+
+	// Test GetByID with non-existent ID
+	_, err := storage.GetByID(999)
+	if err == nil {
+		t.Error("Expected error for non-existent ID")
+	}
+
+	// Test Update with non-existent ID
+	_, err = storage.Update(999, "content")
+	if err == nil {
+		t.Error("Expected error for updating non-existent message")
+	}
+
+	// Test Delete with non-existent ID
+	err = storage.Delete(999)
+	if err == nil {
+		t.Error("Expected error for deleting non-existent message")
+	}
+}
+
+func testRepositoryConcurrency(t *testing.T, storage MessageRepository) {
+
+	// Test concurrent writes
+	done := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		go func(id int) {
+			_, err := storage.Create("user", "content")
+			if err != nil {
+				t.Errorf("Concurrent create failed: %v", err)
+			}
+			done <- true
+		}(i)
+	}
+
+	// Wait for all goroutines
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	count, err := storage.Count()
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 10 {
+		t.Errorf("Expected 10 messages after concurrent writes, got %d", count)
+	}
+}
+
+func testRepositoryList(t *testing.T, storage MessageRepository) {
+	for i, user := range []string{"alice", "bob", "alice", "carol", "alice"} {
+		if _, err := storage.Create(user, fmt.Sprintf("message %d", i+1)); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	// Walk alice's messages newest first, two at a time
+	q := MessageQuery{Username: "alice", Sort: SortIDDesc, Limit: 2}
+	var ids []int
+	for {
+		page, err := storage.List(q)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if q.After == nil && page.Total != 3 {
+			t.Errorf("Expected total 3, got %d", page.Total)
+		}
+		for _, msg := range page.Messages {
+			ids = append(ids, msg.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		key := KeyOf(page.Messages[len(page.Messages)-1])
+		q.After = &key
+
+		// Inserts between pages must not shift the cursor
+		storage.Create("alice", "late message")
+	}
+	if fmt.Sprint(ids) != "[5 3 1]" {
+		t.Errorf("Expected ids [5 3 1], got %v", ids)
+	}
+
+	page, err := storage.List(MessageQuery{Sort: SortTimestampAsc})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	for i := 1; i < len(page.Messages); i++ {
+		if page.Messages[i].Timestamp.Before(page.Messages[i-1].Timestamp) {
+			t.Errorf("Messages not sorted by timestamp at %d", i)
+		}
+	}
+
+	future := page.Messages[len(page.Messages)-1].Timestamp.Add(time.Hour)
+	page, err = storage.List(MessageQuery{Since: future})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if page.Total != 0 || len(page.Messages) != 0 {
+		t.Errorf("Expected no messages since %v, got %d", future, page.Total)
+	}
+	page, _ = storage.List(MessageQuery{Until: future})
+	if len(page.Messages) != page.Total || page.Total == 0 {
+		t.Errorf("Expected all %d messages before %v, got %d", page.Total, future, len(page.Messages))
+	}
+
+	if _, err := storage.List(MessageQuery{Sort: "name"}); err != ErrInvalidSort {
+		t.Errorf("Expected ErrInvalidSort, got %v", err)
+	}
+}
+
+func testRepositoryVersions(t *testing.T, storage MessageRepository) {
+	created, err := storage.Create("alice", "v1")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Version != 1 {
+		t.Errorf("Expected version 1, got %d", created.Version)
+	}
+
+	// Returned messages are copies
+	read, _ := storage.GetByID(created.ID)
+	read.Content = "tampered"
+	if again, _ := storage.GetByID(created.ID); again.Content != "v1" {
+		t.Errorf("Changing a returned message changed storage: %q", again.Content)
+	}
+
+	updated, err := storage.UpdateIfVersion(created.ID, "v2", 1)
+	if err != nil {
+		t.Fatalf("UpdateIfVersion failed: %v", err)
+	}
+	if updated.Version != 2 || updated.Content != "v2" {
+		t.Errorf("Expected v2 at version 2, got %+v", updated)
+	}
+	if read.Version != 1 {
+		t.Errorf("Earlier copy changed by update: %+v", read)
+	}
+
+	if _, err := storage.UpdateIfVersion(created.ID, "stale", 1); err != ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+	if _, err := storage.UpdateIfVersion(999, "missing", 1); err == nil || err == ErrVersionConflict {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	for i := 3; i <= MaxRevisions+5; i++ {
+		if _, err := storage.Update(created.ID, fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	revs, err := storage.Revisions(created.ID)
+	if err != nil {
+		t.Fatalf("Revisions failed: %v", err)
+	}
+	if len(revs) != MaxRevisions {
+		t.Fatalf("Expected %d revisions, got %d", MaxRevisions, len(revs))
+	}
+	last := revs[len(revs)-1]
+	if last.Version != MaxRevisions+5 || last.Content != fmt.Sprintf("v%d", MaxRevisions+5) {
+		t.Errorf("Unexpected latest revision: %+v", last)
+	}
+	if revs[0].Version != 6 {
+		t.Errorf("Expected oldest kept revision 6, got %d", revs[0].Version)
+	}
+
+	storage.Delete(created.ID)
+	if _, err := storage.Revisions(created.ID); err == nil {
+		t.Error("Expected error for revisions of deleted message")
+	}
+}
+
+func testRepositoryAttachments(t *testing.T, storage MessageRepository) {
+	created := time.Date(2025, 7, 16, 9, 0, 0, 0, time.UTC)
+	for _, att := range []models.Attachment{
+		{ID: "a1", Filename: "cat.png", ContentType: "image/png", Size: 1024, Width: 64, Height: 32, Thumbnail: true, UploaderID: 7, CreatedAt: created},
+		{ID: "a2", Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", Size: 12, CreatedAt: created},
+	} {
+		if err := storage.CreateAttachment(&att); err != nil {
+			t.Fatalf("CreateAttachment failed: %v", err)
+		}
+	}
+	if err := storage.CreateAttachment(&models.Attachment{ID: "a1", CreatedAt: created}); err != ErrDuplicateAttachment {
+		t.Errorf("Expected ErrDuplicateAttachment, got %v", err)
+	}
+	att, err := storage.GetAttachment("a1")
+	if err != nil {
+		t.Fatalf("GetAttachment failed: %v", err)
+	}
+	if att.Filename != "cat.png" || att.Width != 64 || !att.Thumbnail || att.UploaderID != 7 || !att.CreatedAt.Equal(created) {
+		t.Errorf("Unexpected attachment: %+v", att)
+	}
+	if _, err := storage.GetAttachment("missing"); err != ErrAttachmentNotFound {
+		t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+	}
+
+	msg, err := storage.Insert(&models.Message{
+		Username:    "alice",
+		Content:     "files",
+		AuthorID:    7,
+		Attachments: []models.Attachment{{ID: "a2"}, {ID: "a1"}},
+	})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if msg.AuthorID != 7 || msg.Version != 1 || len(msg.Attachments) != 2 || msg.Attachments[0].Filename != "notes.txt" {
+		t.Errorf("Unexpected inserted message: %+v", msg)
+	}
+
+	// Attachments survive updates and are returned in order everywhere
+	updated, err := storage.Update(msg.ID, "edited")
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	storage.Create("bob", "no files")
+	read, _ := storage.GetByID(msg.ID)
+	page, _ := storage.List(MessageQuery{})
+	for _, got := range []*models.Message{updated, read, page.Messages[0]} {
+		if len(got.Attachments) != 2 || got.Attachments[0].ID != "a2" || got.Attachments[1].ID != "a1" {
+			t.Errorf("Unexpected attachments: %+v", got.Attachments)
+		}
+	}
+	if len(page.Messages[1].Attachments) != 0 {
+		t.Errorf("Message without attachments got %+v", page.Messages[1].Attachments)
+	}
+
+	if _, err := storage.Insert(&models.Message{Username: "alice", Content: "x", Attachments: []models.Attachment{{ID: "missing"}}}); err != ErrAttachmentNotFound {
+		t.Errorf("Expected ErrAttachmentNotFound, got %v", err)
+	}
+	if count, _ := storage.Count(); count != 2 {
+		t.Errorf("Failed insert left a message behind, count %d", count)
+	}
+}
+
+func testRepositoryFormatAndPreviews(t *testing.T, storage MessageRepository) {
+	plain, _ := storage.Create("alice", "hello")
+	if plain.Format != content.FormatPlain {
+		t.Errorf("Expected default format plain, got %q", plain.Format)
+	}
+	msg, err := storage.Insert(&models.Message{Username: "alice", Content: "see https://example.com", Format: content.FormatMarkdown})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if read, _ := storage.GetByID(msg.ID); read.Format != content.FormatMarkdown {
+		t.Errorf("Expected markdown format, got %q", read.Format)
+	}
+
+	previews := []content.LinkPreview{
+		{URL: "https://example.com", Title: "Example", Description: "An example"},
+		{URL: "https://example.org", Title: "Other"},
+	}
+	if err := storage.SetPreviews(msg.ID, msg.Version, previews); err != nil {
+		t.Fatalf("SetPreviews failed: %v", err)
+	}
+	read, _ := storage.GetByID(msg.ID)
+	if len(read.Previews) != 2 || read.Previews[0] != previews[0] || read.Previews[1] != previews[1] {
+		t.Errorf("Unexpected previews: %+v", read.Previews)
+	}
+	page, _ := storage.List(MessageQuery{})
+	if len(page.Messages[0].Previews) != 0 || len(page.Messages[1].Previews) != 2 {
+		t.Errorf("Previews attached to the wrong messages in list")
+	}
+
+	// Editing drops previews of the old content and makes late ones stale
+	updated, _ := storage.Update(msg.ID, "no links now")
+	if len(updated.Previews) != 0 {
+		t.Errorf("Expected previews cleared by update, got %+v", updated.Previews)
+	}
+	if err := storage.SetPreviews(msg.ID, msg.Version, previews); err != ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict for stale previews, got %v", err)
+	}
+	if err := storage.SetPreviews(999, 1, previews); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+	if updated.Format != content.FormatMarkdown {
+		t.Errorf("Update changed the format to %q", updated.Format)
+	}
+}
+
+func testRepositoryBatch(t *testing.T, storage MessageRepository) {
+	first, _ := storage.Create("alice", "one")
+	second, _ := storage.Create("bob", "two")
+
+	// Best effort: failures don't stop the other operations
+	results, err := storage.Batch([]BatchOp{
+		{Type: BatchCreate, Draft: &models.Message{Username: "carol", Content: "three"}},
+		{Type: BatchUpdate, ID: first.ID, Content: "one, edited", Version: first.Version},
+		{Type: BatchDelete, ID: 999},
+		{Type: BatchDelete, ID: second.ID, Version: second.Version + 1},
+		{Type: BatchDelete, ID: second.ID},
+	}, false)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[0].Message == nil || results[0].Message.Username != "carol" {
+		t.Errorf("Unexpected create result: %+v", results[0])
+	}
+	if results[1].Err != nil || results[1].Message == nil || results[1].Message.Version != 2 {
+		t.Errorf("Unexpected update result: %+v", results[1])
+	}
+	if results[2].Err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID, got %v", results[2].Err)
+	}
+	if results[3].Err != ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict, got %v", results[3].Err)
+	}
+	if results[4].Err != nil || results[4].Message != nil {
+		t.Errorf("Unexpected delete result: %+v", results[4])
+	}
+	if count, _ := storage.Count(); count != 2 {
+		t.Errorf("Expected 2 messages, got %d", count)
+	}
+
+	// Atomic: one failure rolls back everything
+	created := results[0].Message
+	results, err = storage.Batch([]BatchOp{
+		{Type: BatchCreate, Draft: &models.Message{Username: "dave", Content: "four"}},
+		{Type: BatchDelete, ID: created.ID},
+		{Type: BatchUpdate, ID: first.ID, Content: "stale", Version: first.Version},
+	}, true)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if results[0].Err != ErrBatchAborted || results[1].Err != ErrBatchAborted || results[0].Message != nil {
+		t.Errorf("Expected other operations aborted, got %+v", results)
+	}
+	if results[2].Err != ErrVersionConflict {
+		t.Errorf("Expected ErrVersionConflict, got %v", results[2].Err)
+	}
+	if count, _ := storage.Count(); count != 2 {
+		t.Errorf("Rolled back batch changed the count to %d", count)
+	}
+	if _, err := storage.GetByID(created.ID); err != nil {
+		t.Errorf("Rolled back delete removed the message: %v", err)
+	}
+	if revs, _ := storage.Revisions(first.ID); len(revs) != 2 {
+		t.Errorf("Expected 2 revisions after rollback, got %d", len(revs))
+	}
+
+	// Atomic success applies everything
+	results, err = storage.Batch([]BatchOp{
+		{Type: BatchCreate, Draft: &models.Message{Username: "dave", Content: "four"}},
+		{Type: BatchDelete, ID: created.ID},
+		{Type: BatchUpdate, ID: first.ID, Content: "one, edited again", Version: 2},
+	}, true)
+	if err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Errorf("Operation %d failed: %v", i, res.Err)
+		}
+	}
+	if results[0].Message == nil || results[0].Message.ID == created.ID {
+		t.Errorf("Unexpected created message: %+v", results[0].Message)
+	}
+	if results[2].Message == nil || results[2].Message.Content != "one, edited again" || results[2].Message.Version != 3 {
+		t.Errorf("Unexpected updated message: %+v", results[2].Message)
+	}
+	if count, _ := storage.Count(); count != 2 {
+		t.Errorf("Expected 2 messages, got %d", count)
+	}
+}
+
+func testRepositoryBatchSameID(t *testing.T, storage MessageRepository) {
+	for _, atomic := range []bool{false, true} {
+		msg, _ := storage.Create("alice", "one")
+
+		// Every update reports its own version, even when later ops change
+		// or delete the message
+		results, err := storage.Batch([]BatchOp{
+			{Type: BatchUpdate, ID: msg.ID, Content: "two"},
+			{Type: BatchUpdate, ID: msg.ID, Content: "three"},
+			{Type: BatchDelete, ID: msg.ID},
+		}, atomic)
+		if err != nil {
+			t.Fatalf("Batch (atomic %v) failed: %v", atomic, err)
+		}
+		for i, want := range []struct {
+			content string
+			version int
+		}{{"two", 2}, {"three", 3}} {
+			res := results[i]
+			if res.Err != nil || res.Message == nil || res.Message.Content != want.content || res.Message.Version != want.version {
+				t.Errorf("Batch (atomic %v): unexpected update result %d: %+v", atomic, i, res)
+			}
+		}
+		if results[2].Err != nil {
+			t.Errorf("Batch (atomic %v): unexpected delete result: %+v", atomic, results[2])
+		}
+		if _, err := storage.GetByID(msg.ID); err != ErrMessageNotFound {
+			t.Errorf("Batch (atomic %v): expected the message deleted, got %v", atomic, err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"sync"
+	"time"
+)
+
+// EventType names a change to a message
+type EventType string
+
+const (
+	EventCreate EventType = "create"
+	EventUpdate EventType = "update"
+	EventDelete EventType = "delete"
+)
+
+// MessageEvent describes one change to a message.
+// Message is nil for delete events.
+type MessageEvent struct {
+	ID        uint64          `json:"id"`
+	Type      EventType       `json:"type"`
+	MessageID int             `json:"message_id"`
+	Message   *models.Message `json:"message,omitempty"`
+	Time      time.Time       `json:"time"`
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// EventLog fans message events out to subscribers and keeps
+// the most recent ones so clients can resume after reconnecting.
+type EventLog struct {
+	mutex    sync.Mutex
+	nextID   uint64
+	capacity int
+	buffer   []MessageEvent // Oldest first, at most capacity events
+	subs     map[chan MessageEvent]struct{}
+}
+
+// NewEventLog creates an event log that replays up to capacity events
+func NewEventLog(capacity int) *EventLog {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &EventLog{
+		nextID:   1,
+		capacity: capacity,
+		subs:     make(map[chan MessageEvent]struct{}),
+	}
+}
+
+// Publish records an event and delivers it to all subscribers.
+// Subscribers that cannot keep up are dropped; their channel is closed.
+func (l *EventLog) Publish(typ EventType, id int, msg *models.Message) MessageEvent {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ev := MessageEvent{ID: l.nextID, Type: typ, MessageID: id, Message: msg, Time: time.Now()}
+	l.nextID++
+	if len(l.buffer) == l.capacity {
+		l.buffer = append(l.buffer[:0], l.buffer[1:]...)
+	}
+	l.buffer = append(l.buffer, ev)
+
+	for ch := range l.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+	return ev
+}
+
+// Subscribe returns the buffered events after lastID and a channel of new ones.
+// complete is false when events after lastID were already evicted from the buffer,
+// in which case the client has missed changes and should reload.
+// Call cancel to stop receiving events.
+func (l *EventLog) Subscribe(lastID uint64) (replay []MessageEvent, events <-chan MessageEvent, cancel func(), complete bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		if len(l.buffer) > 0 && l.buffer[0].ID > lastID+1 {
+			complete = false
+		}
+		for _, ev := range l.buffer {
+			if ev.ID > lastID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	ch := make(chan MessageEvent, subscriberBuffer)
+	l.subs[ch] = struct{}{}
+	cancel = func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, cancel, complete
+}
+
+// NotifyingRepository publishes an event for every successful change made through it.
+// Changes are made one at a time and published before the next one starts,
+// so event IDs follow the order the changes were committed in.
+type NotifyingRepository struct {
+	MessageRepository
+	events *EventLog
+	// writes serializes each change with the publishing of its events
+	writes sync.Mutex
+}
+
+// NewNotifyingRepository wraps repo so its changes are published to events
+func NewNotifyingRepository(repo MessageRepository, events *EventLog) *NotifyingRepository {
+	return &NotifyingRepository{MessageRepository: repo, events: events}
+}
+
+// Events returns the log changes are published to
+func (r *NotifyingRepository) Events() *EventLog {
+	return r.events
+}
+
+// Create adds a message and publishes a create event
+func (r *NotifyingRepository) Create(username, content string) (*models.Message, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	msg, err := r.MessageRepository.Create(username, content)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(EventCreate, msg.ID, snapshot(msg))
+	return msg, nil
+}
+
+// CreateWithAuthor adds an owned message and publishes a create event
+func (r *NotifyingRepository) CreateWithAuthor(username, content string, authorID int) (*models.Message, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	msg, err := r.MessageRepository.CreateWithAuthor(username, content, authorID)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(EventCreate, msg.ID, snapshot(msg))
+	return msg, nil
+}
+
+// Insert adds a message and publishes a create event
+func (r *NotifyingRepository) Insert(draft *models.Message) (*models.Message, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	msg, err := r.MessageRepository.Insert(draft)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(EventCreate, msg.ID, snapshot(msg))
+	return msg, nil
+}
+
+// Update changes a message and publishes an update event
+func (r *NotifyingRepository) Update(id int, content string) (*models.Message, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	msg, err := r.MessageRepository.Update(id, content)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(EventUpdate, msg.ID, snapshot(msg))
+	return msg, nil
+}
+
+// UpdateIfVersion changes a message at version and publishes an update event
+func (r *NotifyingRepository) UpdateIfVersion(id int, content string, version int) (*models.Message, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	msg, err := r.MessageRepository.UpdateIfVersion(id, content, version)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(EventUpdate, msg.ID, snapshot(msg))
+	return msg, nil
+}
+
+// SetPreviews stores link previews and publishes an update event
+func (r *NotifyingRepository) SetPreviews(id, version int, previews []content.LinkPreview) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	if err := r.MessageRepository.SetPreviews(id, version, previews); err != nil {
+		return err
+	}
+	if msg, err := r.MessageRepository.GetByID(id); err == nil {
+		r.events.Publish(EventUpdate, msg.ID, msg)
+	}
+	return nil
+}
+
+// Batch applies ops and publishes an event for every applied op
+func (r *NotifyingRepository) Batch(ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	results, err := r.MessageRepository.Batch(ops, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		switch ops[i].Type {
+		case BatchCreate:
+			r.events.Publish(EventCreate, res.Message.ID, snapshot(res.Message))
+		case BatchUpdate:
+			r.events.Publish(EventUpdate, res.Message.ID, snapshot(res.Message))
+		case BatchDelete:
+			r.events.Publish(EventDelete, ops[i].ID, nil)
+		}
+	}
+	return results, nil
+}
+
+// Delete removes a message and publishes a delete event
+func (r *NotifyingRepository) Delete(id int) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+	if err := r.MessageRepository.Delete(id); err != nil {
+		return err
+	}
+	r.events.Publish(EventDelete, id, nil)
+	return nil
+}
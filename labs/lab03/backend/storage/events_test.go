@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"lab03-backend/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventLogReplay(t *testing.T) {
+	log := NewEventLog(3)
+	for i := 1; i <= 5; i++ {
+		log.Publish(EventCreate, i, nil)
+	}
+
+	// Events 3..5 are still buffered
+	replay, _, cancel, complete := log.Subscribe(3)
+	cancel()
+	if !complete {
+		t.Error("Expected complete replay after event 3")
+	}
+	if len(replay) != 2 || replay[0].ID != 4 || replay[1].ID != 5 {
+		t.Errorf("Expected events 4 and 5, got %+v", replay)
+	}
+
+	// Event 2 was evicted, so resuming after event 1 misses it
+	replay, _, cancel, complete = log.Subscribe(1)
+	cancel()
+	if complete {
+		t.Error("Expected incomplete replay after evicted event")
+	}
+	if len(replay) != 3 {
+		t.Errorf("Expected 3 buffered events, got %d", len(replay))
+	}
+
+	// New subscribers get no replay
+	replay, _, cancel, complete = log.Subscribe(0)
+	cancel()
+	if len(replay) != 0 || !complete {
+		t.Errorf("Expected empty complete replay, got %+v", replay)
+	}
+}
+
+func TestEventLogDropsSlowSubscriber(t *testing.T) {
+	log := NewEventLog(1)
+	_, events, cancel, _ := log.Subscribe(0)
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		log.Publish(EventCreate, i, nil)
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d events before drop, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestNotifyingRepository(t *testing.T) {
+	log := NewEventLog(10)
+	repo := NewNotifyingRepository(NewMemoryStorage(), log)
+	_, events, cancel, _ := log.Subscribe(0)
+	defer cancel()
+
+	msg, _ := repo.Create("alice", "hi")
+	repo.Update(msg.ID, "hello")
+	repo.Delete(msg.ID)
+	if err := repo.Delete(msg.ID); err == nil {
+		t.Error("Expected error deleting missing message")
+	}
+
+	want := []EventType{EventCreate, EventUpdate, EventDelete}
+	for _, typ := range want {
+		ev := <-events
+		if ev.Type != typ || ev.MessageID != msg.ID {
+			t.Errorf("Expected %s event for %d, got %+v", typ, msg.ID, ev)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Unexpected event for failed delete: %+v", ev)
+	default:
+	}
+}
+
+func TestNotifyingRepositoryBatch(t *testing.T) {
+	log := NewEventLog(10)
+	repo := NewNotifyingRepository(NewMemoryStorage(), log)
+	msg, _ := repo.Create("alice", "hi")
+	_, events, cancel, _ := log.Subscribe(1)
+	defer cancel()
+
+	repo.Batch([]BatchOp{
+		{Type: BatchUpdate, ID: msg.ID, Content: "hello"},
+		{Type: BatchDelete, ID: 999},
+		{Type: BatchDelete, ID: msg.ID},
+	}, false)
+	// Nothing is applied, so nothing is published
+	repo.Batch([]BatchOp{
+		{Type: BatchCreate, Draft: &models.Message{Username: "bob", Content: "x"}},
+		{Type: BatchDelete, ID: 999},
+	}, true)
+
+	for _, typ := range []EventType{EventUpdate, EventDelete} {
+		ev := <-events
+		if ev.Type != typ || ev.MessageID != msg.ID {
+			t.Errorf("Expected %s event for %d, got %+v", typ, msg.ID, ev)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("Unexpected event for failed operation: %+v", ev)
+	default:
+	}
+}
+
+// slowFirstUpdate holds back the result of its first update after applying it
+type slowFirstUpdate struct {
+	MessageRepository
+	once    sync.Once
+	applied chan struct{}
+}
+
+func (r *slowFirstUpdate) Update(id int, content string) (*models.Message, error) {
+	msg, err := r.MessageRepository.Update(id, content)
+	first := false
+	r.once.Do(func() { first = true })
+	if first {
+		close(r.applied)
+		time.Sleep(50 * time.Millisecond)
+	}
+	return msg, err
+}
+
+func TestNotifyingRepositoryOrder(t *testing.T) {
+	log := NewEventLog(10)
+	slow := &slowFirstUpdate{MessageRepository: NewMemoryStorage(), applied: make(chan struct{})}
+	repo := NewNotifyingRepository(slow, log)
+	msg, _ := repo.Create("alice", "hi")
+	_, events, cancel, _ := log.Subscribe(1)
+	defer cancel()
+
+	// The second update starts once the first is applied but not yet
+	// published; its event must still come second
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		repo.Update(msg.ID, "first")
+	}()
+	<-slow.applied
+	go func() {
+		defer wg.Done()
+		repo.Update(msg.ID, "second")
+	}()
+	wg.Wait()
+
+	for _, want := range []int{2, 3} {
+		ev := <-events
+		if ev.Message == nil || ev.Message.Version != want {
+			t.Errorf("Expected version %d next, got %+v", want, ev.Message)
+		}
+	}
+}
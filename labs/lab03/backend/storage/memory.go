@@ -2,8 +2,13 @@ package storage
 
 import (
 	"errors"
+	"lab03-backend/content"
 	"lab03-backend/models"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 )
 
 // MemoryStorage implements in-memory storage for messages
@@ -14,6 +19,10 @@ type MemoryStorage struct {
 	messages map[int]*models.Message
 	// TODO: Add nextID field of type int for auto-incrementing IDs
 	nextID int
+	// Most recent revisions per message, oldest first
+	revisions map[int][]models.MessageRevision
+	// Uploaded attachments by ID
+	attachments map[string]*models.Attachment
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
@@ -22,13 +31,15 @@ func NewMemoryStorage() *MemoryStorage {
 	// Initialize messages as empty map
 	ms := new(MemoryStorage)
 	ms.messages = make(map[int]*models.Message)
+	ms.revisions = make(map[int][]models.MessageRevision)
+	ms.attachments = make(map[string]*models.Attachment)
 	// Set nextID to 1
 	ms.nextID = 1
 	return ms
 }
 
 // GetAll returns all messages
-func (ms *MemoryStorage) GetAll() []*models.Message {
+func (ms *MemoryStorage) GetAll() ([]*models.Message, error) {
 	// TODO: Implement GetAll method
 	// Use read lock for thread safety
 	ms.mutex.Lock()
@@ -36,10 +47,48 @@ func (ms *MemoryStorage) GetAll() []*models.Message {
 	// Convert map values to slice
 	msgs := make([]*models.Message, 0, len(ms.messages))
 	for _, msg := range ms.messages {
-		msgs = append(msgs, msg)
+		msgs = append(msgs, snapshot(msg))
 	}
 	// Return slice of all messages
-	return msgs
+	return msgs, nil
+}
+
+// List returns one page of messages matching q
+func (ms *MemoryStorage) List(q MessageQuery) (*MessagePage, error) {
+	if q.Sort == "" {
+		q.Sort = SortIDAsc
+	}
+	if !q.Sort.Valid() {
+		return nil, ErrInvalidSort
+	}
+
+	ms.mutex.RLock()
+	matched := make([]*models.Message, 0)
+	for _, msg := range ms.messages {
+		if q.Matches(msg) {
+			matched = append(matched, snapshot(msg))
+		}
+	}
+	ms.mutex.RUnlock()
+
+	slices.SortFunc(matched, func(a, b *models.Message) int {
+		return q.Sort.Compare(KeyOf(a), KeyOf(b))
+	})
+
+	page := &MessagePage{Total: len(matched)}
+	start := 0
+	if q.After != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return q.Sort.Compare(KeyOf(matched[i]), *q.After) > 0
+		})
+	}
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		page.HasMore = true
+	}
+	page.Messages = matched[start:end]
+	return page, nil
 }
 
 // GetByID returns a message by its ID
@@ -54,41 +103,129 @@ func (ms *MemoryStorage) GetByID(id int) (*models.Message, error) {
 	if !exists {
 		return nil, ErrMessageNotFound
 	}
-	return msg, nil
+	return snapshot(msg), nil
 }
 
-// Create adds a new message to storage
+// Create adds a new anonymous message to storage
 func (ms *MemoryStorage) Create(username, content string) (*models.Message, error) {
+	return ms.CreateWithAuthor(username, content, 0)
+}
+
+// CreateWithAuthor adds a new message owned by authorID
+func (ms *MemoryStorage) CreateWithAuthor(username, content string, authorID int) (*models.Message, error) {
+	return ms.Insert(&models.Message{Username: username, Content: content, AuthorID: authorID})
+}
+
+// Insert adds a new message with the username, content, author and attachments of draft
+func (ms *MemoryStorage) Insert(draft *models.Message) (*models.Message, error) {
 	// TODO: Implement Create method
 	// Use write lock for thread safety
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
+	return ms.insert(draft)
+}
+
+// insert adds draft as a new message. Caller must hold the write lock.
+func (ms *MemoryStorage) insert(draft *models.Message) (*models.Message, error) {
 	// Get next available ID
 	// Create new message using models.NewMessage
-	msg := models.NewMessage(ms.nextID, username, content)
+	msg := models.NewMessage(ms.nextID, draft.Username, draft.Content)
+	msg.AuthorID = draft.AuthorID
+	if draft.Format != "" {
+		msg.Format = draft.Format
+	}
+	for _, ref := range draft.Attachments {
+		att, exists := ms.attachments[ref.ID]
+		if !exists {
+			return nil, ErrAttachmentNotFound
+		}
+		msg.Attachments = append(msg.Attachments, *att)
+	}
 	// Add message to map
 	ms.messages[ms.nextID] = msg
+	ms.addRevision(msg)
 	// Increment nextID
 	ms.nextID += 1
 	// Return created message
-	return msg, nil
+	return snapshot(msg), nil
 }
 
 // Update modifies an existing message
 func (ms *MemoryStorage) Update(id int, content string) (*models.Message, error) {
-	// TODO: Implement Update method
-	// Use write lock for thread safety
+	return ms.update(id, content, 0)
+}
+
+// UpdateIfVersion modifies a message only if it is still at version.
+// Returns ErrVersionConflict if it was changed in the meantime.
+func (ms *MemoryStorage) UpdateIfVersion(id int, content string, version int) (*models.Message, error) {
+	return ms.update(id, content, version)
+}
+
+// update replaces the stored message with an updated copy, so messages
+// handed out earlier never change. A zero version skips the version check.
+func (ms *MemoryStorage) update(id int, content string, version int) (*models.Message, error) {
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
-	// Check if message exists
+	return ms.updateLocked(id, content, version)
+}
+
+// updateLocked is update for callers that hold the write lock
+func (ms *MemoryStorage) updateLocked(id int, content string, version int) (*models.Message, error) {
 	msg, exists := ms.messages[id]
 	if !exists {
 		return nil, ErrInvalidID
 	}
-	// Update the content field
-	msg.Content = content
-	// Return updated message or error if not found
-	return msg, nil
+	if version != 0 && msg.Version != version {
+		return nil, ErrVersionConflict
+	}
+	updated := snapshot(msg)
+	updated.Content = content
+	updated.Version++
+	// Previews describe the old content
+	updated.Previews = nil
+	ms.messages[id] = updated
+	ms.addRevision(updated)
+	return snapshot(updated), nil
+}
+
+// addRevision records the current content of msg. Caller must hold the write lock.
+func (ms *MemoryStorage) addRevision(msg *models.Message) {
+	revs := append(ms.revisions[msg.ID], models.MessageRevision{
+		Version:   msg.Version,
+		Content:   msg.Content,
+		Timestamp: time.Now(),
+	})
+	if len(revs) > MaxRevisions {
+		revs = slices.Clone(revs[len(revs)-MaxRevisions:])
+	}
+	ms.revisions[msg.ID] = revs
+}
+
+// Revisions returns the stored revisions of a message, oldest first
+func (ms *MemoryStorage) Revisions(id int) ([]models.MessageRevision, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	if _, exists := ms.messages[id]; !exists {
+		return nil, ErrMessageNotFound
+	}
+	return slices.Clone(ms.revisions[id]), nil
+}
+
+// SetPreviews replaces the link previews of a message if it is still at version
+func (ms *MemoryStorage) SetPreviews(id, version int, previews []content.LinkPreview) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	msg, exists := ms.messages[id]
+	if !exists {
+		return ErrMessageNotFound
+	}
+	if msg.Version != version {
+		return ErrVersionConflict
+	}
+	updated := snapshot(msg)
+	updated.Previews = slices.Clone(previews)
+	ms.messages[id] = updated
+	return nil
 }
 
 // Delete removes a message from storage
@@ -97,29 +234,100 @@ func (ms *MemoryStorage) Delete(id int) error {
 	// Use write lock for thread safety
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
+	return ms.delete(id, 0)
+}
+
+// delete removes message id if it is at version; a zero version skips the
+// check. Caller must hold the write lock.
+func (ms *MemoryStorage) delete(id, version int) error {
 	// Check if message exists
 	msg, exists := ms.messages[id]
 	if !exists {
 		return ErrInvalidID
 	}
+	if version != 0 && msg.Version != version {
+		return ErrVersionConflict
+	}
 	// Delete from map
 	delete(ms.messages, msg.ID)
+	delete(ms.revisions, msg.ID)
 	// Return error if message not found
 	return nil
 }
 
+// Batch applies ops under a single lock. Atomic batches restore the
+// messages, revisions and next ID saved beforehand when an op fails.
+func (ms *MemoryStorage) Batch(ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	var rollback func()
+	if atomic {
+		// Stored messages are replaced, never modified, so shallow copies suffice
+		messages, revisions, nextID := maps.Clone(ms.messages), maps.Clone(ms.revisions), ms.nextID
+		rollback = func() { ms.messages, ms.revisions, ms.nextID = messages, revisions, nextID }
+	}
+
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		var res BatchResult
+		switch op.Type {
+		case BatchCreate:
+			res.Message, res.Err = ms.insert(op.Draft)
+		case BatchUpdate:
+			res.Message, res.Err = ms.updateLocked(op.ID, op.Content, op.Version)
+		case BatchDelete:
+			res.Err = ms.delete(op.ID, op.Version)
+		default:
+			res.Err = errUnknownBatchOp
+		}
+		results[i] = res
+		if atomic && res.Err != nil {
+			rollback()
+			return abortBatch(results, i), nil
+		}
+	}
+	return results, nil
+}
+
 // Count returns the total number of messages
-func (ms *MemoryStorage) Count() int {
+func (ms *MemoryStorage) Count() (int, error) {
 	// TODO: Implement Count method
 	// Use read lock for thread safety
 	ms.mutex.Lock()
 	defer ms.mutex.Unlock()
 	// Return length of messages map
-	return len(ms.messages)
+	return len(ms.messages), nil
+}
+
+// CreateAttachment stores the metadata of an uploaded attachment
+func (ms *MemoryStorage) CreateAttachment(att *models.Attachment) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if _, exists := ms.attachments[att.ID]; exists {
+		return ErrDuplicateAttachment
+	}
+	cp := *att
+	ms.attachments[att.ID] = &cp
+	return nil
+}
+
+// GetAttachment returns the metadata of an attachment
+func (ms *MemoryStorage) GetAttachment(id string) (*models.Attachment, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	att, exists := ms.attachments[id]
+	if !exists {
+		return nil, ErrAttachmentNotFound
+	}
+	cp := *att
+	return &cp, nil
 }
 
 // Common errors
 var (
-	ErrMessageNotFound = errors.New("message not found")
-	ErrInvalidID       = errors.New("invalid message ID")
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrInvalidID           = errors.New("invalid message ID")
+	ErrVersionConflict     = errors.New("message was changed by someone else")
+	ErrAttachmentNotFound  = errors.New("attachment not found")
+	ErrDuplicateAttachment = errors.New("attachment already exists")
 )
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create messages table for the chat API
+CREATE TABLE messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for listing messages by user
+CREATE INDEX idx_messages_username ON messages(username);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_messages_username;
+DROP TABLE messages;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Record the owning user so only the author or an admin can edit a message
+ALTER TABLE messages ADD COLUMN author_id INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages DROP COLUMN author_id;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Track a version per message for optimistic concurrency
+ALTER TABLE messages ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- Keep recent revisions of each message
+CREATE TABLE message_revisions (
+    message_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, version),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+
+-- Existing messages start with their current content as version 1
+INSERT INTO message_revisions (message_id, version, content, timestamp)
+SELECT id, version, content, timestamp FROM messages;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE message_revisions;
+ALTER TABLE messages DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Metadata of uploaded files; the contents live in the blob store
+CREATE TABLE attachments (
+    id TEXT PRIMARY KEY,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    thumbnail BOOLEAN NOT NULL DEFAULT FALSE,
+    uploader_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Attachments referenced by each message, in order
+CREATE TABLE message_attachments (
+    message_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    attachment_id TEXT NOT NULL,
+    PRIMARY KEY (message_id, position),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (attachment_id) REFERENCES attachments(id)
+);
+
+CREATE INDEX idx_message_attachments_attachment_id ON message_attachments(attachment_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_message_attachments_attachment_id;
+DROP TABLE message_attachments;
+DROP TABLE attachments;
+-- +goose StatementEnd
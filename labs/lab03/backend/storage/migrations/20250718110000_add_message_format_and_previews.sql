@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- How content is rendered: plain, markdown or html
+ALTER TABLE messages ADD COLUMN format TEXT NOT NULL DEFAULT 'plain';
+
+-- Title and description of the links in each message, in order
+CREATE TABLE link_previews (
+    message_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (message_id, position),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE link_previews;
+ALTER TABLE messages DROP COLUMN format;
+-- +goose StatementEnd
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"lab03-backend/models"
+	"time"
+)
+
+// SortOrder selects how List orders messages
+type SortOrder string
+
+const (
+	SortIDAsc         SortOrder = "id"
+	SortIDDesc        SortOrder = "-id"
+	SortTimestampAsc  SortOrder = "timestamp"
+	SortTimestampDesc SortOrder = "-timestamp"
+)
+
+// ErrInvalidSort is returned for an unknown SortOrder
+var ErrInvalidSort = errors.New("invalid sort order")
+
+// Valid reports whether o is a known sort order
+func (o SortOrder) Valid() bool {
+	switch o {
+	case SortIDAsc, SortIDDesc, SortTimestampAsc, SortTimestampDesc:
+		return true
+	}
+	return false
+}
+
+// Desc reports whether o sorts newest first
+func (o SortOrder) Desc() bool {
+	return o == SortIDDesc || o == SortTimestampDesc
+}
+
+// ByTimestamp reports whether o sorts by timestamp (ties broken by ID)
+func (o SortOrder) ByTimestamp() bool {
+	return o == SortTimestampAsc || o == SortTimestampDesc
+}
+
+// MessageKey is the position of a message in a sort order.
+// List returns messages strictly after the key.
+type MessageKey struct {
+	ID        int
+	Timestamp time.Time
+}
+
+// KeyOf returns the position of msg
+func KeyOf(msg *models.Message) MessageKey {
+	return MessageKey{ID: msg.ID, Timestamp: msg.Timestamp}
+}
+
+// MessageQuery filters and orders messages for List.
+// Zero values mean no filter; Since is inclusive, Until is exclusive.
+// A zero Limit returns all remaining messages.
+type MessageQuery struct {
+	Username string
+	Since    time.Time
+	Until    time.Time
+	Sort     SortOrder
+	After    *MessageKey
+	Limit    int
+}
+
+// Matches reports whether msg passes the filters, ignoring After and Limit
+func (q MessageQuery) Matches(msg *models.Message) bool {
+	if q.Username != "" && msg.Username != q.Username {
+		return false
+	}
+	if !q.Since.IsZero() && msg.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !msg.Timestamp.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Compare returns -1, 0 or +1 depending on whether a sorts before, with or after b
+func (o SortOrder) Compare(a, b MessageKey) int {
+	c := cmp.Compare(a.ID, b.ID)
+	if o.ByTimestamp() {
+		if tc := a.Timestamp.Compare(b.Timestamp); tc != 0 {
+			c = tc
+		}
+	}
+	if o.Desc() {
+		return -c
+	}
+	return c
+}
+
+// MessagePage is one page of List results
+type MessagePage struct {
+	Messages []*models.Message
+	Total    int  // Messages matching the filters, across all pages
+	HasMore  bool // More messages follow the last one on this page
+}
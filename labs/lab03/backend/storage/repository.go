@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"slices"
+)
+
+// MessageRepository is the message store used by the API handlers.
+// MemoryStorage and SQLiteStorage both implement it.
+type MessageRepository interface {
+	GetAll() ([]*models.Message, error)
+	List(q MessageQuery) (*MessagePage, error)
+	GetByID(id int) (*models.Message, error)
+	Create(username, content string) (*models.Message, error)
+	CreateWithAuthor(username, content string, authorID int) (*models.Message, error)
+	// Insert stores a new message built by the caller. Storage assigns the ID,
+	// timestamp and version, and resolves Attachments from their IDs.
+	Insert(msg *models.Message) (*models.Message, error)
+	Update(id int, content string) (*models.Message, error)
+	UpdateIfVersion(id int, content string, version int) (*models.Message, error)
+	Revisions(id int) ([]models.MessageRevision, error)
+	// SetPreviews replaces the link previews of a message. It returns
+	// ErrVersionConflict if the message changed since version.
+	SetPreviews(id, version int, previews []content.LinkPreview) error
+	Delete(id int) error
+	// Batch applies ops in order and returns one result per op. With atomic
+	// set either every op is applied or none is; the failed op keeps its error
+	// and the others get ErrBatchAborted. The error is only for failures that
+	// concern the whole batch.
+	Batch(ops []BatchOp, atomic bool) ([]BatchResult, error)
+	Count() (int, error)
+	CreateAttachment(att *models.Attachment) error
+	GetAttachment(id string) (*models.Attachment, error)
+}
+
+var (
+	_ MessageRepository = (*MemoryStorage)(nil)
+	_ MessageRepository = (*SQLiteStorage)(nil)
+)
+
+// MaxRevisions is how many revisions are kept per message
+const MaxRevisions = 20
+
+// snapshot copies msg so callers never share the stored message
+func snapshot(msg *models.Message) *models.Message {
+	cp := *msg
+	cp.Attachments = slices.Clone(msg.Attachments)
+	cp.Previews = slices.Clone(msg.Previews)
+	return &cp
+}
@@ -0,0 +1,615 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"lab03-backend/content"
+	"lab03-backend/models"
+	"strings"
+	"time"
+
+	"lab04-backend/database"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// SQLiteStorage implements MessageRepository on top of a SQLite database
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStorage opens the database at path and applies migrations
+func OpenSQLiteStorage(path string) (*SQLiteStorage, error) {
+	config := database.DefaultConfig()
+	config.DatabasePath = path
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	s, err := NewSQLiteStorage(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSQLiteStorage applies migrations to db and returns a storage using it
+func NewSQLiteStorage(db *sql.DB) (*SQLiteStorage, error) {
+	if err := database.RunMigrationsFS(db, migrationsFS, "migrations"); err != nil {
+		return nil, err
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStorage) Close() error {
+	return database.CloseDB(s.db)
+}
+
+// GetAll returns all messages ordered by ID
+func (s *SQLiteStorage) GetAll() ([]*models.Message, error) {
+	rows, err := s.db.Query(`SELECT ` + messageColumns + ` FROM messages ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	msgs := make([]*models.Message, 0)
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return msgs, loadRelated(s.db, msgs)
+}
+
+// List returns one page of messages matching q.
+// Filtering, ordering and the keyset cursor are all done in SQL.
+func (s *SQLiteStorage) List(q MessageQuery) (*MessagePage, error) {
+	if q.Sort == "" {
+		q.Sort = SortIDAsc
+	}
+	if !q.Sort.Valid() {
+		return nil, ErrInvalidSort
+	}
+
+	var where []string
+	var args []interface{}
+	if q.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, q.Username)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, q.Until.UTC())
+	}
+
+	page := &MessagePage{}
+	countQuery := "SELECT COUNT(*) FROM messages" + whereClause(where)
+	if err := s.db.QueryRow(countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	op, dir := ">", "ASC"
+	if q.Sort.Desc() {
+		op, dir = "<", "DESC"
+	}
+	if q.After != nil {
+		if q.Sort.ByTimestamp() {
+			ts := q.After.Timestamp.UTC()
+			where = append(where, fmt.Sprintf("(timestamp %s ? OR (timestamp = ? AND id %s ?))", op, op))
+			args = append(args, ts, ts, q.After.ID)
+		} else {
+			where = append(where, "id "+op+" ?")
+			args = append(args, q.After.ID)
+		}
+	}
+	query := "SELECT " + messageColumns + " FROM messages" + whereClause(where)
+	if q.Sort.ByTimestamp() {
+		query += fmt.Sprintf(" ORDER BY timestamp %s, id %s", dir, dir)
+	} else {
+		query += " ORDER BY id " + dir
+	}
+	if q.Limit > 0 {
+		// Fetch one extra row to learn whether another page follows
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	defer rows.Close()
+	page.Messages = make([]*models.Message, 0)
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		page.Messages = append(page.Messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(page.Messages) > q.Limit {
+		page.Messages = page.Messages[:q.Limit]
+		page.HasMore = true
+	}
+	return page, loadRelated(s.db, page.Messages)
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// GetByID returns a message by its ID
+func (s *SQLiteStorage) GetByID(id int) (*models.Message, error) {
+	row := s.db.QueryRow(`SELECT `+messageColumns+` FROM messages WHERE id = ?`, id)
+	msg, err := scanMessage(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	return msg, loadRelated(s.db, []*models.Message{msg})
+}
+
+const messageColumns = `id, username, content, timestamp, author_id, version, format`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier runs queries on the database or inside a transaction
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func scanMessage(row scanner) (*models.Message, error) {
+	msg := new(models.Message)
+	err := row.Scan(&msg.ID, &msg.Username, &msg.Content, &msg.Timestamp, &msg.AuthorID, &msg.Version, &msg.Format)
+	return msg, err
+}
+
+// loadRelated fills in the attachments and link previews of msgs
+func loadRelated(q querier, msgs []*models.Message) error {
+	if err := loadAttachments(q, msgs); err != nil {
+		return err
+	}
+	return loadPreviews(q, msgs)
+}
+
+// messageIDs returns the IDs of msgs as query arguments, the matching
+// placeholders and an index by ID
+func messageIDs(msgs []*models.Message) ([]interface{}, string, map[int]*models.Message) {
+	byID := make(map[int]*models.Message, len(msgs))
+	args := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		byID[msg.ID] = msg
+		args[i] = msg.ID
+	}
+	return args, strings.TrimSuffix(strings.Repeat("?,", len(msgs)), ","), byID
+}
+
+// loadPreviews fills in the link previews of msgs with a single query
+func loadPreviews(q querier, msgs []*models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	args, placeholders, byID := messageIDs(msgs)
+	rows, err := q.Query(`SELECT message_id, url, title, description FROM link_previews
+		WHERE message_id IN (`+placeholders+`) ORDER BY message_id, position`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get link previews: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID int
+		var p content.LinkPreview
+		if err := rows.Scan(&messageID, &p.URL, &p.Title, &p.Description); err != nil {
+			return fmt.Errorf("failed to scan link preview: %w", err)
+		}
+		msg := byID[messageID]
+		msg.Previews = append(msg.Previews, p)
+	}
+	return rows.Err()
+}
+
+const attachmentColumns = `a.id, a.filename, a.content_type, a.size, a.width, a.height, a.thumbnail, a.uploader_id, a.created_at`
+
+func scanAttachment(row scanner, att *models.Attachment) error {
+	return row.Scan(&att.ID, &att.Filename, &att.ContentType, &att.Size, &att.Width, &att.Height,
+		&att.Thumbnail, &att.UploaderID, &att.CreatedAt)
+}
+
+// loadAttachments fills in the attachments of msgs with a single query
+func loadAttachments(q querier, msgs []*models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	args, placeholders, byID := messageIDs(msgs)
+	rows, err := q.Query(`SELECT ma.message_id, `+attachmentColumns+`
+		FROM message_attachments ma JOIN attachments a ON a.id = ma.attachment_id
+		WHERE ma.message_id IN (`+placeholders+`) ORDER BY ma.message_id, ma.position`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID int
+		var att models.Attachment
+		err := rows.Scan(&messageID, &att.ID, &att.Filename, &att.ContentType, &att.Size, &att.Width, &att.Height,
+			&att.Thumbnail, &att.UploaderID, &att.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		msg := byID[messageID]
+		msg.Attachments = append(msg.Attachments, att)
+	}
+	return rows.Err()
+}
+
+// Create adds a new anonymous message to storage
+func (s *SQLiteStorage) Create(username, content string) (*models.Message, error) {
+	return s.CreateWithAuthor(username, content, 0)
+}
+
+// CreateWithAuthor adds a new message owned by authorID
+func (s *SQLiteStorage) CreateWithAuthor(username, content string, authorID int) (*models.Message, error) {
+	return s.Insert(&models.Message{Username: username, Content: content, AuthorID: authorID})
+}
+
+// Insert adds a new message with the username, content, author and attachments of draft
+func (s *SQLiteStorage) Insert(draft *models.Message) (*models.Message, error) {
+	var msg *models.Message
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		msg, err = insertMessage(tx, draft)
+		return err
+	})
+	if err == ErrAttachmentNotFound {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+	return msg, nil
+}
+
+// insertMessage stores draft as a new message and returns it
+func insertMessage(tx *sql.Tx, draft *models.Message) (*models.Message, error) {
+	now := time.Now().UTC()
+	format := draft.Format
+	if format == "" {
+		format = content.FormatPlain
+	}
+	res, err := tx.Exec(`INSERT INTO messages (username, content, timestamp, author_id, version, format) VALUES (?, ?, ?, ?, 1, ?)`,
+		draft.Username, draft.Content, now, draft.AuthorID, format)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	msg := models.NewMessage(int(id), draft.Username, draft.Content)
+	msg.Timestamp = now
+	msg.AuthorID = draft.AuthorID
+	msg.Format = format
+	for i, ref := range draft.Attachments {
+		var att models.Attachment
+		row := tx.QueryRow(`SELECT `+attachmentColumns+` FROM attachments a WHERE a.id = ?`, ref.ID)
+		if err := scanAttachment(row, &att); err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`INSERT INTO message_attachments (message_id, position, attachment_id) VALUES (?, ?, ?)`,
+			msg.ID, i, att.ID); err != nil {
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, att)
+	}
+	return msg, addRevision(tx, msg.ID, msg.Version, draft.Content, now)
+}
+
+// Update modifies the content of an existing message
+func (s *SQLiteStorage) Update(id int, content string) (*models.Message, error) {
+	return s.update(id, content, 0)
+}
+
+// UpdateIfVersion modifies a message only if it is still at version.
+// Returns ErrVersionConflict if it was changed in the meantime.
+func (s *SQLiteStorage) UpdateIfVersion(id int, content string, version int) (*models.Message, error) {
+	return s.update(id, content, version)
+}
+
+// update bumps the version and records a revision. A zero version skips the check.
+func (s *SQLiteStorage) update(id int, content string, version int) (*models.Message, error) {
+	var msg *models.Message
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		msg, err = updateMessage(tx, id, content, version)
+		return err
+	})
+	if err == ErrVersionConflict || err == ErrInvalidID {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+	return msg, nil
+}
+
+// updateMessage changes the content of message id if it is at version and
+// returns the message as tx left it, so later writers cannot show through
+func updateMessage(tx *sql.Tx, id int, content string, version int) (*models.Message, error) {
+	query := `UPDATE messages SET content = ?, version = version + 1 WHERE id = ?`
+	args := []interface{}{content, id}
+	if version != 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	msg, err := scanMessage(tx.QueryRow(query+` RETURNING `+messageColumns, args...))
+	if err == sql.ErrNoRows {
+		return nil, missingOrConflict(tx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Previews describe the old content
+	if _, err := tx.Exec(`DELETE FROM link_previews WHERE message_id = ?`, id); err != nil {
+		return nil, err
+	}
+	if err := addRevision(tx, id, msg.Version, content, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return msg, loadAttachments(tx, []*models.Message{msg})
+}
+
+// missingOrConflict explains why a statement on message id changed no rows
+func missingOrConflict(tx *sql.Tx, id int) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM messages WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrInvalidID
+}
+
+// addRevision stores a revision and drops the ones beyond MaxRevisions
+func addRevision(tx *sql.Tx, id, version int, content string, at time.Time) error {
+	if _, err := tx.Exec(`INSERT INTO message_revisions (message_id, version, content, timestamp) VALUES (?, ?, ?, ?)`,
+		id, version, content, at); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM message_revisions WHERE message_id = ? AND version <= ?`,
+		id, version-MaxRevisions)
+	return err
+}
+
+// Revisions returns the stored revisions of a message, oldest first
+func (s *SQLiteStorage) Revisions(id int) ([]models.MessageRevision, error) {
+	if _, err := s.GetByID(id); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT version, content, timestamp FROM message_revisions
+		WHERE message_id = ? ORDER BY version`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+	defer rows.Close()
+	revs := make([]models.MessageRevision, 0)
+	for rows.Next() {
+		var rev models.MessageRevision
+		if err := rows.Scan(&rev.Version, &rev.Content, &rev.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func (s *SQLiteStorage) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a message from storage
+func (s *SQLiteStorage) Delete(id int) error {
+	err := s.inTx(func(tx *sql.Tx) error {
+		return deleteMessage(tx, id, 0)
+	})
+	if err == ErrInvalidID {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
+
+// deleteMessage removes message id and everything attached to it if it is
+// at version. A zero version skips the check.
+func deleteMessage(tx *sql.Tx, id, version int) error {
+	query := `DELETE FROM messages WHERE id = ?`
+	args := []interface{}{id}
+	if version != 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return missingOrConflict(tx, id)
+	}
+	// Foreign keys are off by default in SQLite, so don't rely on the cascade
+	if _, err := tx.Exec(`DELETE FROM message_revisions WHERE message_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM message_attachments WHERE message_id = ?`, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM link_previews WHERE message_id = ?`, id)
+	return err
+}
+
+// Batch applies ops in one transaction if atomic, each in its own otherwise
+func (s *SQLiteStorage) Batch(ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			err := s.inTx(func(tx *sql.Tx) error {
+				var err error
+				results[i].Message, err = applyBatchOp(tx, op)
+				return err
+			})
+			results[i].Err = batchOpError(op.Type, err)
+		}
+	} else {
+		failed := -1
+		err := s.inTx(func(tx *sql.Tx) error {
+			for i, op := range ops {
+				var err error
+				if results[i].Message, err = applyBatchOp(tx, op); err != nil {
+					failed = i
+					results[i].Err = batchOpError(op.Type, err)
+					return err
+				}
+			}
+			return nil
+		})
+		if failed >= 0 {
+			return abortBatch(results, failed), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply batch: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// applyBatchOp runs op in tx and returns the created or updated message,
+// as it was right after op
+func applyBatchOp(tx *sql.Tx, op BatchOp) (*models.Message, error) {
+	switch op.Type {
+	case BatchCreate:
+		return insertMessage(tx, op.Draft)
+	case BatchUpdate:
+		return updateMessage(tx, op.ID, op.Content, op.Version)
+	case BatchDelete:
+		return nil, deleteMessage(tx, op.ID, op.Version)
+	}
+	return nil, errUnknownBatchOp
+}
+
+// batchOpError wraps unexpected errors the way the single message methods do
+func batchOpError(typ BatchOpType, err error) error {
+	switch err {
+	case nil, ErrInvalidID, ErrVersionConflict, ErrAttachmentNotFound, errUnknownBatchOp:
+		return err
+	}
+	return fmt.Errorf("failed to %s message: %w", typ, err)
+}
+
+// Count returns the total number of messages
+func (s *SQLiteStorage) Count() (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return n, nil
+}
+
+// CreateAttachment stores the metadata of an uploaded attachment
+func (s *SQLiteStorage) CreateAttachment(att *models.Attachment) error {
+	if _, err := s.GetAttachment(att.ID); err == nil {
+		return ErrDuplicateAttachment
+	}
+	_, err := s.db.Exec(`INSERT INTO attachments (id, filename, content_type, size, width, height, thumbnail, uploader_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		att.ID, att.Filename, att.ContentType, att.Size, att.Width, att.Height, att.Thumbnail, att.UploaderID, att.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return nil
+}
+
+// GetAttachment returns the metadata of an attachment
+func (s *SQLiteStorage) GetAttachment(id string) (*models.Attachment, error) {
+	att := new(models.Attachment)
+	row := s.db.QueryRow(`SELECT `+attachmentColumns+` FROM attachments a WHERE a.id = ?`, id)
+	if err := scanAttachment(row, att); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return att, nil
+}
+
+// SetPreviews replaces the link previews of a message if it is still at version
+func (s *SQLiteStorage) SetPreviews(id, version int, previews []content.LinkPreview) error {
+	err := s.inTx(func(tx *sql.Tx) error {
+		var current int
+		err := tx.QueryRow(`SELECT version FROM messages WHERE id = ?`, id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if current != version {
+			return ErrVersionConflict
+		}
+		if _, err := tx.Exec(`DELETE FROM link_previews WHERE message_id = ?`, id); err != nil {
+			return err
+		}
+		for i, p := range previews {
+			if _, err := tx.Exec(`INSERT INTO link_previews (message_id, position, url, title, description) VALUES (?, ?, ?, ?, ?)`,
+				id, i, p.URL, p.Title, p.Description); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrMessageNotFound || err == ErrVersionConflict {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set link previews: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLiteStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := OpenSQLiteStorage(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStorage failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStorageConformance(t *testing.T) {
+	runRepositoryConformance(t, func(t *testing.T) MessageRepository {
+		return newTestSQLiteStorage(t)
+	})
+}
+
+func TestSQLiteStoragePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.db")
+	s, err := OpenSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStorage failed: %v", err)
+	}
+	if _, err := s.Create("alice", "hello"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	s.Close()
+
+	// Reopening runs migrations again, which must be a no-op
+	s, err = OpenSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer s.Close()
+	msg, err := s.GetByID(1)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if msg.Username != "alice" || msg.Content != "hello" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+}
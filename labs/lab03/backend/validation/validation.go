@@ -0,0 +1,172 @@
+// Package validation checks structs against their `validate:"..."` tags.
+//
+// Supported rules, separated by commas:
+//
+//	required   value must not be the zero value
+//	omitempty  skip the remaining rules when the value is the zero value
+//	min=N      strings and slices: length >= N, numbers: value >= N
+//	max=N      strings and slices: length <= N, numbers: value <= N
+//	oneof=a b  value must be one of the space separated options
+//
+// Fields are reported by their JSON name.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error codes reported in FieldError.Code
+const (
+	CodeRequired     = "required"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeTooSmall     = "too_small"
+	CodeTooLarge     = "too_large"
+	CodeNotAllowed   = "not_allowed"
+	CodeUnknownField = "unknown_field"
+	CodeInvalidType  = "invalid_type"
+	CodeInvalidValue = "invalid_value"
+)
+
+// FieldError describes why one field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors is a list of field errors; it is returned as a single error
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct validates v, which must be a struct or a pointer to one.
+// It returns Errors listing every failed rule, or nil.
+func Struct(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("validation: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: expected struct, got %s", rv.Kind())
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		name := JSONName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if strings.TrimSpace(rule) == "omitempty" {
+				if rv.Field(i).IsZero() {
+					break
+				}
+				continue
+			}
+			if fe := check(name, rv.Field(i), rule); fe != nil {
+				errs = append(errs, *fe)
+				// Report one problem per field
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// JSONName returns the name a field has in JSON
+func JSONName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func check(name string, v reflect.Value, rule string) *FieldError {
+	rule = strings.TrimSpace(rule)
+	key, arg, _ := strings.Cut(rule, "=")
+	switch key {
+	case "":
+		return nil
+	case "required":
+		if v.IsZero() {
+			return &FieldError{Field: name, Code: CodeRequired, Message: "is required"}
+		}
+	case "min", "max":
+		return checkBound(name, v, key, arg)
+	case "oneof":
+		options := strings.Fields(arg)
+		s := fmt.Sprint(v.Interface())
+		for _, o := range options {
+			if s == o {
+				return nil
+			}
+		}
+		return &FieldError{Field: name, Code: CodeNotAllowed,
+			Message: "must be one of: " + strings.Join(options, ", ")}
+	default:
+		panic("validation: unknown rule " + strconv.Quote(rule))
+	}
+	return nil
+}
+
+func checkBound(name string, v reflect.Value, key, arg string) *FieldError {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic("validation: bad " + key + " argument " + strconv.Quote(arg))
+	}
+	isMin := key == "min"
+
+	var n float64
+	sized := false
+	switch v.Kind() {
+	case reflect.String:
+		n, sized = float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n, sized = float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		panic("validation: " + key + " not supported for " + v.Kind().String())
+	}
+
+	switch {
+	case isMin && n < limit && sized:
+		return &FieldError{Field: name, Code: CodeTooShort, Message: "must be at least " + arg + " long"}
+	case isMin && n < limit:
+		return &FieldError{Field: name, Code: CodeTooSmall, Message: "must be at least " + arg}
+	case !isMin && n > limit && sized:
+		return &FieldError{Field: name, Code: CodeTooLong, Message: "must be at most " + arg + " long"}
+	case !isMin && n > limit:
+		return &FieldError{Field: name, Code: CodeTooLarge, Message: "must be at most " + arg}
+	}
+	return nil
+}
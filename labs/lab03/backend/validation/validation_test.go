@@ -0,0 +1,69 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+type sample struct {
+	Name   string   `json:"name" validate:"required,min=2,max=5"`
+	Age    int      `json:"age" validate:"min=0,max=150"`
+	Format string   `json:"format" validate:"oneof=plain markdown"`
+	Tags   []string `json:"tags,omitempty" validate:"max=2"`
+	Sort   string   `json:"sort,omitempty" validate:"omitempty,oneof=asc desc"`
+	Note   string   // not validated
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name  string
+		value sample
+		codes map[string]string // field -> code
+	}{
+		{"valid", sample{Name: "Ann", Age: 30, Format: "plain"}, nil},
+		{"missing name", sample{Format: "plain"}, map[string]string{"name": CodeRequired}},
+		{"short name", sample{Name: "A", Format: "plain"}, map[string]string{"name": CodeTooShort}},
+		{"long name counts runes", sample{Name: "Ивановa", Format: "plain"}, map[string]string{"name": CodeTooLong}},
+		{"negative age", sample{Name: "Ann", Age: -1, Format: "plain"}, map[string]string{"age": CodeTooSmall}},
+		{"old age", sample{Name: "Ann", Age: 200, Format: "plain"}, map[string]string{"age": CodeTooLarge}},
+		{"bad format", sample{Name: "Ann", Format: "html"}, map[string]string{"format": CodeNotAllowed}},
+		{"many tags", sample{Name: "Ann", Format: "plain", Tags: []string{"a", "b", "c"}}, map[string]string{"tags": CodeTooLong}},
+		{"omitted sort", sample{Name: "Ann", Format: "plain", Sort: ""}, nil},
+		{"bad sort", sample{Name: "Ann", Format: "plain", Sort: "up"}, map[string]string{"sort": CodeNotAllowed}},
+		{"several fields", sample{Age: -1}, map[string]string{"name": CodeRequired, "age": CodeTooSmall, "format": CodeNotAllowed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(&tt.value)
+			if tt.codes == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected Errors, got %v", err)
+			}
+			if len(errs) != len(tt.codes) {
+				t.Errorf("Expected %d field errors, got %v", len(tt.codes), errs)
+			}
+			for _, fe := range errs {
+				if tt.codes[fe.Field] != fe.Code {
+					t.Errorf("Field %s: expected code %q, got %q", fe.Field, tt.codes[fe.Field], fe.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestStructRejectsNonStruct(t *testing.T) {
+	if err := Struct(42); err == nil {
+		t.Error("Expected error for non-struct value")
+	}
+	var s *sample
+	if err := Struct(s); err == nil {
+		t.Error("Expected error for nil pointer")
+	}
+}
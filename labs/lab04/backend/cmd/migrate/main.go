@@ -0,0 +1,255 @@
+// Command migrate manages the lab04 schema with the migrations embedded in
+// the binary, so it can run from any directory.
+//
+//	go run ./cmd/migrate [flags] COMMAND [ARG]
+//
+// Commands that change the schema hold a migration lock, so concurrent
+// deploys run one after the other. The exit code tells scripts what happened:
+//
+//	0  success, including nothing to do
+//	1  migration or database error
+//	2  bad command line
+//	3  another migration held the lock for longer than -lock-timeout
+//	4  validate found problems in the migrations
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"lab04-backend/database"
+)
+
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitLocked  = 3
+	exitInvalid = 4
+)
+
+const usage = `Usage: migrate [flags] COMMAND [ARG]
+
+Commands:
+  up                apply all pending migrations
+  up-to VERSION     apply pending migrations up to and including VERSION
+  down              roll back the latest migration
+  down-to VERSION   roll back the migrations after VERSION (0 for all)
+  redo              roll back the latest migration and apply it again
+  status            list migrations and whether they are applied
+  version           print the latest applied version
+  create NAME       add a SQL migration for every dialect to -dir
+  validate          check the migrations, applying them to a scratch SQLite database
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line in args and returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dsn := flags.String("database", defaultDSN(), "SQLite path or postgres:// URL, defaults to $DATABASE_URL")
+	dir := flags.String("dir", "migrations", "migrations directory that create writes to")
+	lockTimeout := flags.Duration("lock-timeout", 30*time.Second, "how long to wait for another migration to finish")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	command, rest := flags.Arg(0), flags.Args()
+	if len(rest) > 0 {
+		rest = rest[1:]
+	}
+	wantArgs := map[string]int{
+		"up": 0, "up-to": 1, "down": 0, "down-to": 1, "redo": 0,
+		"status": 0, "version": 0, "create": 1, "validate": 0,
+	}
+	n, ok := wantArgs[command]
+	if !ok || len(rest) != n {
+		flags.Usage()
+		return exitUsage
+	}
+	var version int64
+	if command == "up-to" || command == "down-to" {
+		v, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil || v < 0 {
+			fmt.Fprintf(stderr, "migrate: invalid version %q\n", rest[0])
+			return exitUsage
+		}
+		version = v
+	}
+
+	// These commands do not touch the target database
+	switch command {
+	case "create":
+		file, err := database.CreateMigration(*dir, rest[0])
+		if err != nil {
+			return fail(stderr, err)
+		}
+		fmt.Fprintf(stdout, "created %s for every dialect in %s\n", file, *dir)
+		return exitOK
+	case "validate":
+		return validate(ctx, stdout, stderr)
+	}
+
+	config := database.DefaultConfig()
+	config.DatabaseURL = *dsn
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer database.CloseDB(db)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	migrator.LockTimeout = *lockTimeout
+
+	var results []*goose.MigrationResult
+	switch command {
+	case "up":
+		results, err = migrator.Up(ctx)
+	case "up-to":
+		results, err = migrator.UpTo(ctx, version)
+	case "down":
+		var result *goose.MigrationResult
+		if result, err = migrator.Down(ctx); result != nil {
+			results = append(results, result)
+		}
+	case "down-to":
+		results, err = migrator.DownTo(ctx, version)
+	case "redo":
+		results, err = migrator.Redo(ctx)
+	case "status":
+		return status(ctx, migrator, stdout, stderr)
+	case "version":
+		current, err := migrator.Version(ctx)
+		if err != nil {
+			return fail(stderr, err)
+		}
+		fmt.Fprintln(stdout, current)
+		return exitOK
+	}
+
+	printResults(stdout, results)
+	var partial *goose.PartialError
+	if errors.As(err, &partial) {
+		printResults(stdout, partial.Applied)
+	}
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		fmt.Fprintln(stdout, "no migrations to roll back")
+		return exitOK
+	}
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(stdout, "no migrations to run")
+	}
+	return exitOK
+}
+
+// defaultDSN is $DATABASE_URL, or the SQLite file of database.DefaultConfig
+func defaultDSN() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	return database.DefaultConfig().DatabasePath
+}
+
+// fail reports err and returns its exit code
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "migrate: %v\n", err)
+	if errors.Is(err, database.ErrMigrationLocked) {
+		return exitLocked
+	}
+	return exitFailed
+}
+
+func printResults(w io.Writer, results []*goose.MigrationResult) {
+	for _, result := range results {
+		fmt.Fprintf(w, "%-4s %s (%s)\n", result.Direction, filepath.Base(result.Source.Path), result.Duration.Round(time.Microsecond))
+	}
+}
+
+func status(ctx context.Context, migrator *database.Migrator, stdout, stderr io.Writer) int {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(stdout, "%-8s %-20s %s\n", s.State, appliedAt, filepath.Base(s.Source.Path))
+	}
+	return exitOK
+}
+
+// validate checks the embedded migrations, then applies the SQLite ones
+// up, down and up again on a scratch database
+func validate(ctx context.Context, stdout, stderr io.Writer) int {
+	if err := database.ValidateMigrations(); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(stderr, "invalid: %s\n", line)
+		}
+		return exitInvalid
+	}
+
+	scratch, err := os.MkdirTemp("", "lab04-migrate-")
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer os.RemoveAll(scratch)
+	db, err := database.InitDBWithConfig(&database.Config{DatabasePath: filepath.Join(scratch, "validate.db"), MaxOpenConns: 2, MaxIdleConns: 1})
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer database.CloseDB(db)
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"up", func() error { _, err := migrator.Up(ctx); return err }},
+		{"down-to 0", func() error { _, err := migrator.DownTo(ctx, 0); return err }},
+		{"up again", func() error { _, err := migrator.Up(ctx); return err }},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			fmt.Fprintf(stderr, "invalid: %s on SQLite: %v\n", step.name, err)
+			return exitInvalid
+		}
+	}
+
+	fmt.Fprintln(stdout, "migrations are valid")
+	return exitOK
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+)
+
+// latestMigration is the version of the newest embedded migration
+const latestMigration = "20250725090000"
+
+// runMigrate runs the command line and returns its exit code and output
+func runMigrate(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var out bytes.Buffer
+	code := run(context.Background(), args, &out, &out)
+	return code, out.String()
+}
+
+func TestMigrateCommands(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+
+	code, out := runMigrate(t, "-database", dsn, "version")
+	if code != exitOK || strings.TrimSpace(out) != "0" {
+		t.Errorf("Expected version 0, got %d %q", code, out)
+	}
+
+	code, out = runMigrate(t, "-database", dsn, "up-to", "20250708090034")
+	if code != exitOK || strings.Count(out, "up ") != 2 {
+		t.Errorf("Expected 2 migrations applied, got %d %q", code, out)
+	}
+
+	code, out = runMigrate(t, "-database", dsn, "up")
+	if code != exitOK || !strings.Contains(out, "up   20250712100000_add_keyset_indexes.sql") {
+		t.Errorf("Expected the remaining migrations applied, got %d %q", code, out)
+	}
+	if code, out = runMigrate(t, "-database", dsn, "up"); code != exitOK || !strings.Contains(out, "no migrations to run") {
+		t.Errorf("Expected nothing to run, got %d %q", code, out)
+	}
+
+	code, out = runMigrate(t, "-database", dsn, "status")
+	if code != exitOK || !strings.Contains(out, "applied") || strings.Contains(out, "pending") {
+		t.Errorf("Expected every migration applied, got %d %q", code, out)
+	}
+
+	code, out = runMigrate(t, "-database", dsn, "redo")
+	if code != exitOK || !strings.Contains(out, "down "+latestMigration) || !strings.Contains(out, "up   "+latestMigration) {
+		t.Errorf("Expected the latest migration redone, got %d %q", code, out)
+	}
+
+	if code, out = runMigrate(t, "-database", dsn, "down"); code != exitOK || !strings.Contains(out, "down "+latestMigration) {
+		t.Errorf("Expected the latest migration rolled back, got %d %q", code, out)
+	}
+	if code, out = runMigrate(t, "-database", dsn, "down-to", "0"); code != exitOK {
+		t.Errorf("Expected everything rolled back, got %d %q", code, out)
+	}
+	if code, out = runMigrate(t, "-database", dsn, "down"); code != exitOK || !strings.Contains(out, "no migrations to roll back") {
+		t.Errorf("Expected nothing to roll back, got %d %q", code, out)
+	}
+}
+
+func TestMigrateExitCodes(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"sideways"}, exitUsage},
+		{[]string{"up", "extra"}, exitUsage},
+		{[]string{"up-to"}, exitUsage},
+		{[]string{"down-to", "-1"}, exitUsage},
+		{[]string{"-database", dsn, "up-to", "abc"}, exitUsage},
+		{[]string{"-no-such-flag", "up"}, exitUsage},
+		{[]string{"-h"}, exitOK},
+		{[]string{"-database", "mysql://localhost/db", "up"}, exitFailed},
+	}
+	for _, tt := range tests {
+		if code, out := runMigrate(t, tt.args...); code != tt.code {
+			t.Errorf("Expected exit code %d for %v, got %d %q", tt.code, tt.args, code, out)
+		}
+	}
+}
+
+func TestMigrateLocked(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	db, err := database.InitDBWithConfig(&database.Config{DatabasePath: dsn, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer database.CloseDB(db)
+	// Stands in for another process that is migrating
+	_, err = db.Exec(`CREATE TABLE migration_lock (id INTEGER PRIMARY KEY, owner TEXT NOT NULL, locked_at DATETIME NOT NULL);
+		INSERT INTO migration_lock VALUES (1, 'other', ?)`, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to take the lock: %v", err)
+	}
+
+	if code, out := runMigrate(t, "-database", dsn, "-lock-timeout", "0", "up"); code != exitLocked {
+		t.Errorf("Expected exit code %d while locked, got %d %q", exitLocked, code, out)
+	}
+	if code, out := runMigrate(t, "-database", dsn, "version"); code != exitOK || strings.TrimSpace(out) != "0" {
+		t.Errorf("Expected nothing applied, got %d %q", code, out)
+	}
+}
+
+func TestMigrateCreate(t *testing.T) {
+	dir := t.TempDir()
+	for _, folder := range []string{"sqlite", "postgres"} {
+		if err := os.Mkdir(filepath.Join(dir, folder), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out := runMigrate(t, "-dir", dir, "create", "add_tags")
+	if code != exitOK {
+		t.Fatalf("Expected create to succeed, got %d %q", code, out)
+	}
+	sqliteFiles, _ := filepath.Glob(filepath.Join(dir, "sqlite", "*_add_tags.sql"))
+	postgresFiles, _ := filepath.Glob(filepath.Join(dir, "postgres", "*_add_tags.sql"))
+	if len(sqliteFiles) != 1 || len(postgresFiles) != 1 || filepath.Base(sqliteFiles[0]) != filepath.Base(postgresFiles[0]) {
+		t.Errorf("Expected one file with the same name per dialect, got %v and %v", sqliteFiles, postgresFiles)
+	}
+}
+
+func TestMigrateValidate(t *testing.T) {
+	if code, out := runMigrate(t, "validate"); code != exitOK || !strings.Contains(out, "migrations are valid") {
+		t.Errorf("Expected embedded migrations to be valid, got %d %q", code, out)
+	}
+}
@@ -0,0 +1,101 @@
+// Command scheduler publishes scheduled posts of the lab04 database once
+// their publish_at has passed.
+//
+//	go run ./cmd/scheduler [flags]
+//
+// It checks for due posts right away and then every -interval until it is
+// interrupted or terminated. Several schedulers may run against one
+// database: each due post is published once. Pending migrations are applied
+// first unless -migrate=false.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/repository"
+)
+
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+)
+
+const usage = `Usage: scheduler [flags]
+
+Publishes scheduled posts when they fall due, until interrupted.
+
+Flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line in args until ctx is done and returns the
+// exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("scheduler", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dsn := flags.String("database", defaultDSN(), "SQLite path or postgres:// URL, defaults to $DATABASE_URL")
+	interval := flags.Duration("interval", time.Minute, "how often to check for due posts")
+	migrate := flags.Bool("migrate", true, "apply pending migrations before starting")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() > 0 || *interval <= 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	config := database.DefaultConfig()
+	config.DatabaseURL = *dsn
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	defer database.CloseDB(db)
+	if *migrate {
+		if err := database.RunMigrations(db); err != nil {
+			return fail(stderr, err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "publishing due posts every %s\n", *interval)
+	repository.NewPostScheduler(repository.NewPostRepository(db), *interval).Run(ctx)
+	fmt.Fprintln(stdout, "scheduler stopped")
+	return exitOK
+}
+
+// defaultDSN is $DATABASE_URL, or the SQLite file of database.DefaultConfig
+func defaultDSN() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	return database.DefaultConfig().DatabasePath
+}
+
+// fail reports err and returns its exit code
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "scheduler: %v\n", err)
+	return exitFailed
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/fixtures"
+	"lab04-backend/models"
+	"lab04-backend/repository"
+)
+
+const dueFixture = `
+users:
+  - ref: alice
+    name: Alice Smith
+    email: alice@example.com
+posts:
+  - ref: due
+    user: alice
+    title: Due post
+    content: Scheduled for the past
+    status: scheduled
+    publish_at: 2025-01-01T09:00:00Z
+`
+
+func TestSchedulerPublishesUntilCancelled(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "scheduler.db")
+	config := database.DefaultConfig()
+	config.DatabasePath = dsn
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.CloseDB(db)
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	file, err := fixtures.Parse("due.yaml", []byte(dueFixture))
+	if err != nil {
+		t.Fatalf("Failed to parse fixtures: %v", err)
+	}
+	refs, err := fixtures.Load(context.Background(), db, file)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	var out bytes.Buffer
+	if code := run(ctx, []string{"-database", dsn, "-interval", "10ms"}, &out, &out); code != exitOK {
+		t.Fatalf("Expected the scheduler to stop cleanly, got %d %q", code, out.String())
+	}
+	if !strings.Contains(out.String(), "scheduler stopped") {
+		t.Errorf("Expected the scheduler to report stopping, got %q", out.String())
+	}
+
+	post, err := repository.NewPostRepository(db).GetByID(context.Background(), refs.Posts["due"])
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if post.Status != models.PostPublished {
+		t.Errorf("Expected the due post published, got %s", post.Status)
+	}
+}
+
+func TestSchedulerUsage(t *testing.T) {
+	for _, args := range [][]string{{"-interval", "0s"}, {"-interval", "soon"}, {"extra"}, {"-bogus"}} {
+		var out bytes.Buffer
+		if code := run(context.Background(), args, &out, &out); code != exitUsage {
+			t.Errorf("run(%q): expected a usage error, got %d %q", args, code, out.String())
+		}
+	}
+}
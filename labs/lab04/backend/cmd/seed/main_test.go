@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSeed runs the command line and returns its exit code and output
+func runSeed(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	var out bytes.Buffer
+	code := run(context.Background(), args, &out, &out)
+	return code, out.String()
+}
+
+func TestSeedDemo(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "seed.db")
+
+	for i := 0; i < 2; i++ {
+		code, out := runSeed(t, "-database", dsn)
+		if code != exitOK || !strings.Contains(out, "seeded 3 users, 4 categories and 5 posts") {
+			t.Errorf("Expected the demo data seeded, got %d %q", code, out)
+		}
+	}
+}
+
+func TestSeedFiles(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "seed.db")
+	file := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(file, []byte(`{"users": [{"name": "Dave Brown", "email": "dave@example.com"}]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write fixtures: %v", err)
+	}
+
+	if code, out := runSeed(t, "-database", dsn, "-migrate=false", file); code != exitFailed || !strings.Contains(out, "seed: ") {
+		t.Errorf("Expected seeding an unmigrated database to fail, got %d %q", code, out)
+	}
+	if code, out := runSeed(t, "-database", dsn, file); code != exitOK || !strings.Contains(out, "seeded 1 users") {
+		t.Errorf("Expected the file seeded, got %d %q", code, out)
+	}
+	if code, out := runSeed(t, "-database", dsn, filepath.Join(dir, "missing.yaml")); code != exitFailed {
+		t.Errorf("Expected a missing file to fail, got %d %q", code, out)
+	}
+	if code, out := runSeed(t, "-bogus"); code != exitUsage {
+		t.Errorf("Expected a usage error, got %d %q", code, out)
+	}
+}
@@ -3,18 +3,85 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Dialect is the SQL dialect of a database engine
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// driverName is the database/sql driver used for d
+func (d Dialect) driverName() string {
+	if d == Postgres {
+		return "pgx"
+	}
+	return "sqlite3"
+}
+
+// Like is the LIKE operator that ignores case, as SQLite LIKE does for ASCII
+func (d Dialect) Like() string {
+	if d == Postgres {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// ParseDSN picks the dialect for dsn and returns the data source to open.
+// postgres:// and postgresql:// URLs are Postgres; sqlite:// URLs, file: URIs
+// and plain paths are SQLite.
+func ParseDSN(dsn string) (Dialect, string, error) {
+	switch {
+	case dsn == "":
+		return "", "", errors.New("database DSN cannot be empty")
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return Postgres, dsn, nil
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return SQLite, strings.TrimPrefix(dsn, "sqlite://"), nil
+	case strings.Contains(dsn, "://"):
+		return "", "", fmt.Errorf("unsupported database DSN scheme: %s", dsn[:strings.Index(dsn, "://")])
+	}
+	return SQLite, dsn, nil
+}
+
+// DialectOf returns the dialect of the engine db is connected to
+func DialectOf(db *sql.DB) Dialect {
+	drv := db.Driver()
+	if slow, ok := drv.(*slowQueryDriver); ok {
+		drv = slow.Driver
+	}
+	if _, ok := drv.(*stdlib.Driver); ok {
+		return Postgres
+	}
+	return SQLite
+}
+
 // Config holds database configuration
 type Config struct {
+	// DatabaseURL selects the engine, see ParseDSN; DatabasePath is used
+	// as a SQLite file when it is empty
+	DatabaseURL     string
 	DatabasePath    string
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+	// ConnectRetries is how often a failed first ping to Postgres is tried
+	// again, with ConnectRetryDelay before the first retry, doubling after each
+	ConnectRetries    int
+	ConnectRetryDelay time.Duration
+	// SlowQueryThreshold logs queries that take at least this long;
+	// 0 turns slow query logging off
+	SlowQueryThreshold time.Duration
 }
 
 // DefaultConfig returns a default database configuration
@@ -25,6 +92,10 @@ func DefaultConfig() *Config {
 		MaxIdleConns:    5,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 2 * time.Minute,
+		// A Postgres container may still be starting
+		ConnectRetries:     5,
+		ConnectRetryDelay:  200 * time.Millisecond,
+		SlowQueryThreshold: 200 * time.Millisecond,
 	}
 }
 
@@ -42,23 +113,67 @@ func InitDB() (*sql.DB, error) {
 func InitDBWithConfig(config *Config) (*sql.DB, error) {
 	// Initialize database connection with custom configuration
 	// - Open database connection using the provided config
-	db, err := sql.Open("sqlite3", config.DatabasePath)
+	dsn := config.DatabaseURL
+	if dsn == "" {
+		dsn = config.DatabasePath
+	}
+	dialect, source, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	var db *sql.DB
+	if config.SlowQueryThreshold > 0 {
+		db, err = openWithSlowQueryLog(dialect.driverName(), source, config.SlowQueryThreshold)
+	} else {
+		db, err = sql.Open(dialect.driverName(), source)
+	}
 	if err != nil {
 		return nil, err
 	}
 	// - Apply all connection pool settings
 	db.SetMaxOpenConns(config.MaxOpenConns)
-	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	// database/sql keeps no idle connections for 0, which would lose a
+	// SQLite :memory: database between queries, so 0 keeps its default
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
 	db.SetConnMaxLifetime(config.ConnMaxLifetime)
 	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
-	// - Test connection with Ping()
-	if err := db.Ping(); err != nil {
+	// - Test connection with Ping(), retrying while a server comes up.
+	// SQLite opens a local file, which waiting does not fix.
+	retries := config.ConnectRetries
+	if dialect == SQLite {
+		retries = 0
+	}
+	if err := pingWithRetry(db, retries, config.ConnectRetryDelay); err != nil {
+		db.Close()
 		return nil, err
 	}
 	// - Return the database connection or error
 	return db, nil
 }
 
+// pingWithRetry pings db up to retries+1 times, waiting delay before the
+// first retry and doubling the wait after each
+func pingWithRetry(db *sql.DB, retries int, delay time.Duration) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = db.Ping(); err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			break
+		}
+		log.Printf("database not ready (attempt %d of %d): %v", attempt+1, retries+1, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	if retries > 0 {
+		return fmt.Errorf("failed to connect after %d attempts: %w", retries+1, err)
+	}
+	return err
+}
+
 // Implement CloseDB function
 func CloseDB(db *sql.DB) error {
 	// Properly close database connection
@@ -67,9 +182,9 @@ func CloseDB(db *sql.DB) error {
 		return errors.New("error: db is nil")
 	}
 	// - Close the database connection
-	if err := db.Close(); err == nil {
+	if err := db.Close(); err != nil {
+		// - Return any error that occurs
 		return err
 	}
-	// - Return any error that occurs
 	return nil
 }
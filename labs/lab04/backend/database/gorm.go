@@ -0,0 +1,22 @@
+package database
+
+import (
+	"database/sql"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// OpenGORM opens GORM on db with the dialector for its engine.
+// A nil config uses the GORM defaults.
+func OpenGORM(db *sql.DB, config *gorm.Config) (*gorm.DB, error) {
+	if config == nil {
+		config = &gorm.Config{}
+	}
+	var dialector gorm.Dialector = sqlite.Dialector{Conn: db}
+	if DialectOf(db) == Postgres {
+		dialector = postgres.New(postgres.Config{Conn: db})
+	}
+	return gorm.Open(dialector, config)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Health reports whether the database answers and how its pool is used
+type Health struct {
+	Status  string        `json:"status"`
+	Dialect Dialect       `json:"dialect"`
+	Latency time.Duration `json:"latency_ns"`
+	Error   string        `json:"error,omitempty"`
+	// Pool is db.Stats() at the time of the check
+	Pool PoolStats `json:"pool"`
+	// SlowQueries is the count of slow queries logged so far
+	SlowQueries uint64 `json:"slow_queries"`
+}
+
+// PoolStats is the JSON form of sql.DBStats
+type PoolStats struct {
+	MaxOpenConnections int           `json:"max_open_connections"`
+	OpenConnections    int           `json:"open_connections"`
+	InUse              int           `json:"in_use"`
+	Idle               int           `json:"idle"`
+	WaitCount          int64         `json:"wait_count"`
+	WaitDuration       time.Duration `json:"wait_duration_ns"`
+	MaxIdleClosed      int64         `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64         `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64         `json:"max_lifetime_closed"`
+}
+
+// HealthCheck pings db within ctx and reports the result with the pool
+// stats. The Health is filled in even when the ping fails, so it can be
+// served as the body of an unhealthy response.
+func HealthCheck(ctx context.Context, db *sql.DB) (*Health, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+
+	health := &Health{Status: "ok", Dialect: DialectOf(db), SlowQueries: SlowQueries()}
+	start := time.Now()
+	err := db.PingContext(ctx)
+	health.Latency = time.Since(start)
+	if err != nil {
+		health.Status = "unavailable"
+		health.Error = err.Error()
+		err = fmt.Errorf("database health check failed: %w", err)
+	}
+
+	stats := db.Stats()
+	health.Pool = PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+	return health, err
+}
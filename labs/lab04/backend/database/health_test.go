@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestHealthCheck(t *testing.T) {
+	if _, err := HealthCheck(context.Background(), nil); err == nil {
+		t.Error("HealthCheck(nil) should return an error")
+	}
+
+	db, err := InitDBWithConfig(&Config{DatabaseURL: "sqlite://:memory:", MaxOpenConns: 4, MaxIdleConns: 2})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+
+	health, err := HealthCheck(context.Background(), db)
+	if err != nil {
+		t.Fatalf("HealthCheck() failed: %v", err)
+	}
+	if health.Status != "ok" {
+		t.Errorf("Expected status ok, got %q", health.Status)
+	}
+	if health.Dialect != SQLite {
+		t.Errorf("Expected dialect %s, got %s", SQLite, health.Dialect)
+	}
+	if health.Latency <= 0 {
+		t.Errorf("Expected a positive latency, got %s", health.Latency)
+	}
+	if health.Pool.MaxOpenConnections != 4 {
+		t.Errorf("Expected MaxOpenConnections 4, got %d", health.Pool.MaxOpenConnections)
+	}
+	if health.Pool.OpenConnections != 1 || health.Pool.Idle != 1 {
+		t.Errorf("Expected 1 open idle connection, got %d open and %d idle", health.Pool.OpenConnections, health.Pool.Idle)
+	}
+	body, err := json.Marshal(health)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(body, &decoded)
+	if _, ok := decoded["pool"]; !ok {
+		t.Errorf("Expected pool in %s", body)
+	}
+	if _, ok := decoded["error"]; ok {
+		t.Errorf("Expected no error in %s", body)
+	}
+
+	// A closed database is reported unavailable
+	CloseDB(db)
+	health, err = HealthCheck(context.Background(), db)
+	if err == nil {
+		t.Error("HealthCheck() should fail on a closed database")
+	}
+	if health == nil || health.Status != "unavailable" || health.Error == "" {
+		t.Errorf("Expected an unavailable status with an error, got %+v", health)
+	}
+}
@@ -0,0 +1,42 @@
+// Package metrics exports the lab04 database stats as Prometheus metrics.
+// It is kept out of package database so users of that package do not
+// depend on the Prometheus client.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"lab04-backend/database"
+)
+
+// Register adds the pool stats of db, labelled db_name=name, and the slow
+// query count to reg. The go_sql_* pool metrics are the ones of
+// collectors.NewDBStatsCollector. The slow query count is shared by every
+// database, so registering it again for a second database is not an error.
+func Register(reg prometheus.Registerer, db *sql.DB, name string) error {
+	if db == nil {
+		return fmt.Errorf("database connection cannot be nil")
+	}
+	if err := reg.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		return fmt.Errorf("failed to register database stats: %w", err)
+	}
+
+	slowQueries := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "lab04_database_slow_queries_total",
+		Help: "Queries that took at least the slow query threshold.",
+	}, func() float64 {
+		return float64(database.SlowQueries())
+	})
+	if err := reg.Register(slowQueries); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			return fmt.Errorf("failed to register slow query count: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"lab04-backend/database"
+)
+
+func TestRegister(t *testing.T) {
+	db, err := database.InitDBWithConfig(&database.Config{DatabaseURL: "sqlite://:memory:", MaxOpenConns: 3, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer database.CloseDB(db)
+
+	reg := prometheus.NewRegistry()
+	if err := Register(nil, nil, "main"); err == nil {
+		t.Error("Register() with a nil database should fail")
+	}
+	if err := Register(reg, db, "main"); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	// A second database shares the slow query count
+	if err := Register(reg, db, "replica"); err != nil {
+		t.Errorf("Register() of a second database failed: %v", err)
+	}
+	if err := Register(reg, db, "main"); err == nil {
+		t.Error("Register() of the same name twice should fail")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() failed: %v", err)
+	}
+	found := make(map[string]int)
+	for _, family := range families {
+		found[family.GetName()] = len(family.GetMetric())
+	}
+	if found["go_sql_max_open_connections"] != 2 {
+		t.Errorf("Expected go_sql_max_open_connections for 2 databases, got %d", found["go_sql_max_open_connections"])
+	}
+	if found["go_sql_idle_connections"] != 2 {
+		t.Errorf("Expected go_sql_idle_connections for 2 databases, got %d", found["go_sql_idle_connections"])
+	}
+	if found["lab04_database_slow_queries_total"] != 1 {
+		t.Errorf("Expected lab04_database_slow_queries_total once, got %d", found["lab04_database_slow_queries_total"])
+	}
+}
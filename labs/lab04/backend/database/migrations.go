@@ -1,29 +1,68 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
 
 	"github.com/pressly/goose/v3"
 )
 
-// RunMigrations runs database migrations using goose
+// RunMigrations runs database migrations using goose.
+// The embedded migrations matching the dialect of db are applied.
 func RunMigrations(db *sql.DB) error {
 	if db == nil {
 		return fmt.Errorf("database connection cannot be nil")
 	}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
 	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return nil
+}
+
+// migrationDialects lists the dialects with a migrations folder
+var migrationDialects = []Dialect{SQLite, Postgres}
+
+// migrationsFolder is the folder of the migrations for dialect,
+// in migrations.FS and in the migrations directory on disk
+func migrationsFolder(dialect Dialect) string {
+	if dialect == Postgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// fts5Suffix ends the names of migrations that need SQLite FTS5
+const fts5Suffix = "_fts5.sql"
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
+// HasFTS5 reports whether the SQLite library was built with FTS5.
+// go-sqlite3 only includes it with the sqlite_fts5 build tag.
+func HasFTS5(db *sql.DB) bool {
+	var enabled bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	return err == nil && enabled
+}
 
-	// Run migrations from the migrations directory
-	if err := goose.Up(db, migrationsDir); err != nil {
-		return fmt.Errorf("failed to run migrations: %v", err)
+// RunMigrationsFS applies the goose migrations stored in dir of fsys, under
+// the same lock as RunMigrations.
+// Other modules use it to apply their own schema through this package.
+func RunMigrationsFS(db *sql.DB, fsys fs.FS, dir string) error {
+	migrator, err := NewMigratorFS(db, fsys, dir)
+	if err != nil {
+		return err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	return nil
@@ -36,63 +75,95 @@ func RollbackMigration(db *sql.DB) error {
 		return fmt.Errorf("database connection cannot be nil")
 	}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
 	}
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
-
-	// Rollback migrations from the migrations directory
-	if err := goose.Down(db, migrationsDir); err != nil {
-		return fmt.Errorf("failed to rollback migrations: %v", err)
+	// Rollback the most recently applied migration
+	if _, err := migrator.Down(context.Background()); err != nil {
+		return fmt.Errorf("failed to rollback migrations: %w", err)
 	}
 
 	return nil
 }
 
 // Implement this function
-// GetMigrationStatus checks migration status using goose
+// GetMigrationStatus checks migration status using goose and logs it
 func GetMigrationStatus(db *sql.DB) error {
 	if db == nil {
 		return fmt.Errorf("database connection cannot be nil")
 	}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
 	}
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
-
-	// Check collectMigrations
-	if err := goose.Status(db, migrationsDir); err != nil {
+	statuses, err := migrator.Status(context.Background())
+	if err != nil {
 		return fmt.Errorf("failed to get migrations status: %w", err)
 	}
+	for _, status := range statuses {
+		log.Printf("%-8s %s", status.State, filepath.Base(status.Source.Path))
+	}
 
 	return nil
 }
 
 // Implement this function
-// CreateMigration creates a new migration file
-func CreateMigration(name string) error {
+// CreateMigration creates a new migration file for every dialect in dir,
+// the migrations directory on disk. The files share a version so the
+// schemas stay in step. It returns the name of the new files.
+func CreateMigration(dir, name string) (string, error) {
 	if len(name) == 0 {
-		return fmt.Errorf("migration name cannot be empty")
+		return "", fmt.Errorf("migration name cannot be empty")
 	}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+	// Let goose name the SQLite file, then copy it for the other dialects
+	sqliteDir := filepath.Join(dir, migrationsFolder(SQLite))
+	before, err := os.ReadDir(sqliteDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migrations: %w", err)
+	}
+	if err := goose.Create(nil, sqliteDir, name, "sql"); err != nil {
+		return "", fmt.Errorf("failed to create migration: %w", err)
+	}
+	file, err := newMigrationFile(sqliteDir, before)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(sqliteDir, file))
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration: %w", err)
+	}
+	for _, dialect := range migrationDialects {
+		if dialect == SQLite {
+			continue
+		}
+		path := filepath.Join(dir, migrationsFolder(dialect), file)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return "", fmt.Errorf("failed to create migration: %w", err)
+		}
 	}
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
+	return file, nil
+}
 
-	if err := goose.Create(nil, migrationsDir, name, "sql"); err != nil {
-		return fmt.Errorf("failed to create migration: %w", err)
+// newMigrationFile finds the file in dir that is not in before
+func newMigrationFile(dir string, before []fs.DirEntry) (string, error) {
+	existing := make(map[string]bool, len(before))
+	for _, entry := range before {
+		existing[entry.Name()] = true
 	}
-
-	return nil
+	after, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for _, entry := range after {
+		if !existing[entry.Name()] {
+			return entry.Name(), nil
+		}
+	}
+	return "", fmt.Errorf("created migration not found in %s", dir)
 }
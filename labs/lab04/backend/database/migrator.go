@@ -0,0 +1,366 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pressly/goose/v3"
+
+	"lab04-backend/migrations"
+)
+
+// ErrMigrationLocked is returned when another process kept the migration
+// lock for longer than Migrator.LockTimeout
+var ErrMigrationLocked = errors.New("another migration is running")
+
+// fts5Warning logs once per process that the FTS5 migrations are skipped
+var fts5Warning sync.Once
+
+const (
+	// migrationLockID is the Postgres advisory lock key for migrations
+	migrationLockID int64 = 4_040_400_450
+	// migrationLockStaleAfter is when a SQLite lock row left behind by a
+	// crashed process may be taken over
+	migrationLockStaleAfter = time.Hour
+	lockPollInterval        = 100 * time.Millisecond
+)
+
+// Migrator applies the embedded migrations to a database with goose.
+// Commands that change the schema hold a lock for their whole run, so two
+// processes migrating the same database wait for each other: a Postgres
+// advisory lock, or a row in the migration_lock table on SQLite.
+// On Postgres the lock needs a connection of its own next to the one
+// migrations run on.
+type Migrator struct {
+	db       *sql.DB
+	dialect  Dialect
+	provider *goose.Provider
+	// LockTimeout is how long to wait for the lock before failing with
+	// ErrMigrationLocked
+	LockTimeout time.Duration
+}
+
+// NewMigrator creates a Migrator for the migrations of the dialect of db.
+// On SQLite without FTS5 the FTS5 migrations are left out; as they can be
+// applied later, migrations may run out of order.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+	dialect := DialectOf(db)
+	fsys, err := fs.Sub(migrations.FS, migrationsFolder(dialect))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+
+	var exclude []string
+	if dialect == SQLite && !HasFTS5(db) {
+		exclude, err = fs.Glob(fsys, "*"+fts5Suffix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list migrations: %w", err)
+		}
+		fts5Warning.Do(func() {
+			log.Printf("SQLite lacks FTS5, skipping %s; build with -tags sqlite_fts5 for ranked search", strings.Join(exclude, ", "))
+		})
+	}
+	return newMigrator(db, fsys,
+		goose.WithAllowOutofOrder(true),
+		goose.WithExcludeNames(exclude),
+	)
+}
+
+// NewMigratorFS creates a Migrator for the migrations in dir of fsys.
+// Other modules use it to apply their own schema through this package.
+func NewMigratorFS(db *sql.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+	return newMigrator(db, sub)
+}
+
+// newMigrator creates a Migrator for the migrations at the root of fsys.
+// The provider keeps its own state, so migrators of several databases can
+// run at once.
+func newMigrator(db *sql.DB, fsys fs.FS, opts ...goose.ProviderOption) (*Migrator, error) {
+	dialect := DialectOf(db)
+	opts = append(opts, goose.WithDisableGlobalRegistry(true))
+	provider, err := goose.NewProvider(goose.Dialect(dialect), db, fsys, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return &Migrator{db: db, dialect: dialect, provider: provider, LockTimeout: 30 * time.Second}, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		results, err = m.provider.Up(ctx)
+		return err
+	})
+	return results, err
+}
+
+// UpTo applies the pending migrations up to and including version
+func (m *Migrator) UpTo(ctx context.Context, version int64) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		results, err = m.provider.UpTo(ctx, version)
+		return err
+	})
+	return results, err
+}
+
+// Down rolls back the most recently applied migration.
+// It returns goose.ErrNoNextVersion if no migration is applied.
+func (m *Migrator) Down(ctx context.Context) (result *goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		result, err = m.provider.Down(ctx)
+		return err
+	})
+	return result, err
+}
+
+// DownTo rolls back the migrations after version; 0 rolls back all of them
+func (m *Migrator) DownTo(ctx context.Context, version int64) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		results, err = m.provider.DownTo(ctx, version)
+		return err
+	})
+	return results, err
+}
+
+// Redo rolls back the most recently applied migration and applies it again
+func (m *Migrator) Redo(ctx context.Context) (results []*goose.MigrationResult, err error) {
+	err = m.withLock(ctx, func() error {
+		down, err := m.provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		results = append(results, down)
+		up, err := m.provider.ApplyVersion(ctx, down.Source.Version, true)
+		if err != nil {
+			return err
+		}
+		results = append(results, up)
+		return nil
+	})
+	return results, err
+}
+
+// Status reports every migration and whether it is applied
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Version returns the latest applied migration version, 0 for none
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	return m.provider.GetDBVersion(ctx)
+}
+
+// withLock runs fn while holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if unlockErr := unlock(); unlockErr != nil && err == nil {
+		err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+	}
+	return err
+}
+
+// lock takes the migration lock and returns the function releasing it
+func (m *Migrator) lock(ctx context.Context) (func() error, error) {
+	if m.dialect == Postgres {
+		return m.lockPostgres(ctx)
+	}
+	return m.lockSQLite(ctx)
+}
+
+// lockPostgres takes a session advisory lock. The session ends with the
+// connection, so Postgres releases the lock if the process dies.
+func (m *Migrator) lockPostgres(ctx context.Context) (func() error, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	err = m.waitForLock(ctx, func() (bool, error) {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockID).Scan(&locked)
+		return locked, err
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		return err
+	}, nil
+}
+
+// lockSQLite inserts the single row of migration_lock; whoever inserted it
+// holds the lock
+func (m *Migrator) lockSQLite(ctx context.Context) (func() error, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS migration_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		owner TEXT NOT NULL,
+		locked_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+
+	owner := fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	err = m.waitForLock(ctx, func() (bool, error) {
+		stale := time.Now().Add(-migrationLockStaleAfter)
+		if _, err := m.db.ExecContext(ctx, `DELETE FROM migration_lock WHERE locked_at < ?`, stale); err != nil {
+			return false, err
+		}
+		result, err := m.db.ExecContext(ctx,
+			`INSERT OR IGNORE INTO migration_lock (id, owner, locked_at) VALUES (1, ?, ?)`, owner, time.Now())
+		if err != nil {
+			return false, err
+		}
+		inserted, err := result.RowsAffected()
+		return inserted == 1, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := m.db.ExecContext(context.Background(), `DELETE FROM migration_lock WHERE owner = ?`, owner)
+		return err
+	}, nil
+}
+
+// waitForLock calls try until it takes the lock or LockTimeout passes
+func (m *Migrator) waitForLock(ctx context.Context, try func() (bool, error)) error {
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		locked, err := try()
+		if err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ErrMigrationLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// ValidateMigrations checks the embedded migrations without a database:
+// every file has a version and goose Up and Down sections with balanced
+// statement blocks, and every dialect has the same versions apart from the
+// SQLite FTS5 ones. All problems found are returned joined together.
+func ValidateMigrations() error {
+	return validateMigrationsFS(migrations.FS)
+}
+
+func validateMigrationsFS(fsys fs.FS) error {
+	var problems []error
+	names := make(map[Dialect][]string)
+	for _, dialect := range migrationDialects {
+		folder := migrationsFolder(dialect)
+		files, err := fs.Glob(fsys, folder+"/*.sql")
+		if err != nil {
+			return fmt.Errorf("failed to list migrations: %w", err)
+		}
+		if len(files) == 0 {
+			problems = append(problems, fmt.Errorf("%s: no migrations", folder))
+		}
+
+		versions := make(map[int64]string)
+		for _, path := range files {
+			name := filepath.Base(path)
+			version, err := goose.NumericComponent(name)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			if other, ok := versions[version]; ok {
+				problems = append(problems, fmt.Errorf("%s: version %d is also used by %s", path, version, other))
+			}
+			versions[version] = name
+
+			content, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			for _, problem := range checkMigrationSQL(content) {
+				problems = append(problems, fmt.Errorf("%s: %s", path, problem))
+			}
+			if !strings.HasSuffix(name, fts5Suffix) {
+				names[dialect] = append(names[dialect], name)
+			}
+		}
+		sort.Strings(names[dialect])
+	}
+
+	for _, dialect := range migrationDialects[1:] {
+		if !reflect.DeepEqual(names[dialect], names[SQLite]) {
+			problems = append(problems, fmt.Errorf("%s migrations differ from %s: %v and %v",
+				dialect, SQLite, names[dialect], names[SQLite]))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// checkMigrationSQL checks the goose annotations of a SQL migration
+func checkMigrationSQL(content []byte) []string {
+	var problems []string
+	var up, down, open int
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		switch strings.TrimSpace(string(line)) {
+		case "-- +goose Up":
+			up++
+		case "-- +goose Down":
+			down++
+		case "-- +goose StatementBegin":
+			if open > 0 {
+				problems = append(problems, "StatementBegin inside a statement block")
+			}
+			open++
+		case "-- +goose StatementEnd":
+			if open == 0 {
+				problems = append(problems, "StatementEnd without StatementBegin")
+				continue
+			}
+			open--
+		}
+	}
+	if up != 1 {
+		problems = append(problems, fmt.Sprintf("expected one +goose Up section, found %d", up))
+	}
+	if down != 1 {
+		problems = append(problems, fmt.Sprintf("expected one +goose Down section, found %d", down))
+	}
+	if open > 0 {
+		problems = append(problems, "StatementBegin without StatementEnd")
+	}
+	return problems
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+func setupMigrator(t *testing.T, path string) (*Migrator, func()) {
+	os.Remove(path)
+	db, err := InitDBWithConfig(&Config{DatabasePath: path, MaxOpenConns: 2, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator() failed: %v", err)
+	}
+	return migrator, func() {
+		CloseDB(db)
+		os.Remove(path)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	migrator, cleanup := setupMigrator(t, "./test_migrator.db")
+	defer cleanup()
+	ctx := context.Background()
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if len(statuses) < 4 || statuses[0].State != goose.StatePending {
+		t.Fatalf("Expected pending migrations, got %d", len(statuses))
+	}
+	first, second := statuses[0].Source.Version, statuses[1].Source.Version
+
+	results, err := migrator.UpTo(ctx, second)
+	if err != nil {
+		t.Fatalf("UpTo() failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 migrations applied, got %d", len(results))
+	}
+	if version, _ := migrator.Version(ctx); version != second {
+		t.Errorf("Expected version %d, got %d", second, version)
+	}
+
+	results, err = migrator.Redo(ctx)
+	if err != nil {
+		t.Fatalf("Redo() failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Direction != "down" || results[1].Source.Version != second {
+		t.Errorf("Expected %d to be rolled back and applied again, got %v", second, results)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+	if _, err := migrator.db.Exec("SELECT COUNT(*) FROM post_categories"); err != nil {
+		t.Errorf("Expected all tables after Up(): %v", err)
+	}
+
+	result, err := migrator.Down(ctx)
+	if err != nil || result.Direction != "down" {
+		t.Fatalf("Down() failed: %v", err)
+	}
+	if _, err := migrator.DownTo(ctx, first); err != nil {
+		t.Fatalf("DownTo() failed: %v", err)
+	}
+	if version, _ := migrator.Version(ctx); version != first {
+		t.Errorf("Expected version %d, got %d", first, version)
+	}
+	if _, err := migrator.DownTo(ctx, 0); err != nil {
+		t.Fatalf("DownTo(0) failed: %v", err)
+	}
+	if _, err := migrator.Down(ctx); !errors.Is(err, goose.ErrNoNextVersion) {
+		t.Errorf("Expected goose.ErrNoNextVersion, got %v", err)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	migrator, cleanup := setupMigrator(t, "./test_migrator_lock.db")
+	defer cleanup()
+	ctx := context.Background()
+	migrator.LockTimeout = 0
+
+	unlock, err := migrator.lock(ctx)
+	if err != nil {
+		t.Fatalf("lock() failed: %v", err)
+	}
+	if _, err := migrator.Up(ctx); !errors.Is(err, ErrMigrationLocked) {
+		t.Errorf("Expected ErrMigrationLocked while locked, got %v", err)
+	}
+	if version, _ := migrator.Version(ctx); version != 0 {
+		t.Errorf("Expected nothing to be applied while locked, got version %d", version)
+	}
+
+	// A waiting migration runs once the lock is released
+	migrator.LockTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(2 * lockPollInterval)
+		unlock()
+	}()
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() failed after waiting: %v", err)
+	}
+
+	// Locks left by crashed processes expire
+	stale := time.Now().Add(-2 * migrationLockStaleAfter)
+	if _, err := migrator.db.Exec(`INSERT INTO migration_lock (id, owner, locked_at) VALUES (1, 'crashed', ?)`, stale); err != nil {
+		t.Fatalf("Failed to insert stale lock: %v", err)
+	}
+	migrator.LockTimeout = 0
+	if _, err := migrator.Down(ctx); err != nil {
+		t.Errorf("Expected the stale lock to be taken over, got %v", err)
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	if err := ValidateMigrations(); err != nil {
+		t.Errorf("Expected embedded migrations to be valid, got %v", err)
+	}
+
+	valid := "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n-- +goose StatementEnd\n\n-- +goose Down\nSELECT 1;\n"
+	fsys := fstest.MapFS{
+		"sqlite/00001_first.sql":       {Data: []byte(valid)},
+		"sqlite/00002_second.sql":      {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n")},
+		"sqlite/00003_search_fts5.sql": {Data: []byte(valid)},
+		"sqlite/first.sql":             {Data: []byte(valid)},
+		"postgres/00001_first.sql":     {Data: []byte(valid)},
+		"postgres/00001_again.sql":     {Data: []byte(valid)},
+	}
+	err := validateMigrationsFS(fsys)
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{
+		"00002_second.sql: expected one +goose Down",
+		"00002_second.sql: StatementBegin without StatementEnd",
+		"first.sql: no filename separator",
+		"version 1 is also used by",
+		"postgres migrations differ from sqlite3",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "fts5") {
+		t.Errorf("Expected FTS5 migrations to be SQLite only, got %v", err)
+	}
+}
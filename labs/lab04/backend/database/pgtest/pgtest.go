@@ -0,0 +1,50 @@
+// Package pgtest gives tests a throwaway schema in a shared Postgres database
+package pgtest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Schema creates a schema with a random lab04_test_ name in the Postgres
+// database at dsn and returns dsn with search_path set to it, so the tables
+// the test creates land there and nothing else in the database is touched.
+// The schema is dropped with its contents when the test ends.
+func Schema(t testing.TB, dsn string) string {
+	t.Helper()
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("Invalid Postgres URL: %v", err)
+	}
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("Failed to name test schema: %v", err)
+	}
+	schema := "lab04_test_" + hex.EncodeToString(suffix)
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open Postgres: %v", err)
+	}
+	// The name is generated above, so it needs no quoting
+	if _, err := db.Exec("CREATE SCHEMA " + schema); err != nil {
+		db.Close()
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		defer db.Close()
+		if _, err := db.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("Failed to drop test schema %s: %v", schema, err)
+		}
+	})
+
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// slowQueries counts the queries logged as slow by every database
+var slowQueries atomic.Uint64
+
+// SlowQueries returns how many queries were logged as slow since start
+func SlowQueries() uint64 {
+	return slowQueries.Load()
+}
+
+// openWithSlowQueryLog opens a database whose driver logs the queries that
+// take at least threshold
+func openWithSlowQueryLog(driverName, dsn string, threshold time.Duration) (*sql.DB, error) {
+	// sql.Open only looks the driver up, so this finds the registered one
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	inner := db.Driver()
+	db.Close()
+
+	slow := &slowQueryDriver{Driver: inner, threshold: threshold}
+	if dc, ok := inner.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(slowQueryConnector{connector: connector, driver: slow}), nil
+	}
+	return sql.OpenDB(slowQueryConnector{dsn: dsn, driver: slow}), nil
+}
+
+// slowQueryDriver wraps the connections of Driver to time their queries
+type slowQueryDriver struct {
+	driver.Driver
+	threshold time.Duration
+}
+
+func (d *slowQueryDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &slowQueryConn{Conn: conn, driver: d}, nil
+}
+
+// observe logs query if it ran for at least the threshold since start
+func (d *slowQueryDriver) observe(start time.Time, query string) {
+	elapsed := time.Since(start)
+	if elapsed < d.threshold {
+		return
+	}
+	slowQueries.Add(1)
+	log.Printf("slow query (%s): %s", elapsed.Round(time.Millisecond), strings.Join(strings.Fields(query), " "))
+}
+
+// slowQueryConnector opens wrapped connections, through connector when the
+// driver has one and with dsn otherwise
+type slowQueryConnector struct {
+	connector driver.Connector
+	dsn       string
+	driver    *slowQueryDriver
+}
+
+func (c slowQueryConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.connector == nil {
+		return c.driver.Open(c.dsn)
+	}
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowQueryConn{Conn: conn, driver: c.driver}, nil
+}
+
+func (c slowQueryConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// slowQueryConn times the queries run on Conn. Methods of optional driver
+// interfaces that Conn lacks return driver.ErrSkip, so database/sql falls
+// back as it would without the wrapper.
+type slowQueryConn struct {
+	driver.Conn
+	driver *slowQueryDriver
+}
+
+func (c *slowQueryConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *slowQueryConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &slowQueryStmt{Stmt: stmt, query: query, driver: c.driver}, nil
+}
+
+func (c *slowQueryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.driver.observe(time.Now(), query)
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *slowQueryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer c.driver.observe(time.Now(), query)
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *slowQueryConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *slowQueryConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *slowQueryConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *slowQueryConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *slowQueryConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// slowQueryStmt times the runs of a prepared statement
+type slowQueryStmt struct {
+	driver.Stmt
+	query  string
+	driver *slowQueryDriver
+}
+
+func (s *slowQueryStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	defer s.driver.observe(time.Now(), s.query)
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *slowQueryStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	defer s.driver.observe(time.Now(), s.query)
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+func (s *slowQueryStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
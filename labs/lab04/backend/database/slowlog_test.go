@@ -0,0 +1,85 @@
+package database
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	// Every query is slow with a threshold of 1ns
+	db, err := InitDBWithConfig(&Config{DatabaseURL: "sqlite://:memory:", MaxOpenConns: 1, SlowQueryThreshold: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer CloseDB(db)
+	if got := DialectOf(db); got != SQLite {
+		t.Errorf("Expected %s, got %s", SQLite, got)
+	}
+
+	before := SlowQueries()
+	if _, err := db.Exec("CREATE TABLE slow (id INTEGER PRIMARY KEY,\n\tname TEXT)"); err != nil {
+		t.Fatalf("Exec() failed: %v", err)
+	}
+	stmt, err := db.Prepare("INSERT INTO slow (name) VALUES (?)")
+	if err != nil {
+		t.Fatalf("Prepare() failed: %v", err)
+	}
+	if _, err := stmt.Exec("a"); err != nil {
+		t.Fatalf("Stmt.Exec() failed: %v", err)
+	}
+	stmt.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM slow WHERE name = ?", "a").Scan(&count); err != nil {
+		t.Fatalf("QueryRow() failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 row, got %d", count)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Rollback() failed: %v", err)
+	}
+
+	if got := SlowQueries() - before; got != 3 {
+		t.Errorf("Expected 3 slow queries, got %d", got)
+	}
+	output := logged.String()
+	for _, query := range []string{
+		"CREATE TABLE slow (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO slow (name) VALUES (?)",
+		"SELECT COUNT(*) FROM slow WHERE name = ?",
+	} {
+		if !strings.Contains(output, "slow query (") || !strings.Contains(output, query) {
+			t.Errorf("Expected %q in the log, got %q", query, output)
+		}
+	}
+}
+
+func TestSlowQueryLogOff(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabaseURL: "sqlite://:memory:", MaxOpenConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() failed: %v", err)
+	}
+	defer CloseDB(db)
+	if _, ok := db.Driver().(*slowQueryDriver); ok {
+		t.Error("Expected the plain driver without SlowQueryThreshold")
+	}
+
+	before := SlowQueries()
+	if _, err := db.Exec("SELECT 1"); err != nil {
+		t.Fatalf("Exec() failed: %v", err)
+	}
+	if got := SlowQueries() - before; got != 0 {
+		t.Errorf("Expected no slow queries, got %d", got)
+	}
+}
@@ -0,0 +1,463 @@
+// Package fixtures loads users, categories and posts from YAML or JSON
+// files into the lab04 schema.
+//
+// A file lists records in the order they are loaded. A record may have a
+// ref, a symbolic name other records use to point at it, so files do not
+// depend on the IDs the database hands out:
+//
+//	users:
+//	  - ref: alice
+//	    name: Alice Smith
+//	    email: alice@example.com
+//	categories:
+//	  - ref: go
+//	    name: Go
+//	posts:
+//	  - ref: hello
+//	    user: alice
+//	    title: Hello, world
+//	    categories: [go]
+//
+// Loading is an upsert by natural key: users by email, categories by name
+// and posts by author and title. Loading the same files again updates the
+// rows in place, and restores them if they were soft deleted.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"lab04-backend/models"
+	"lab05/security"
+)
+
+// File is the content of one or more fixture files
+type File struct {
+	Users      []User     `yaml:"users" json:"users"`
+	Categories []Category `yaml:"categories" json:"categories"`
+	Posts      []Post     `yaml:"posts" json:"posts"`
+}
+
+// User is a user fixture, keyed by email
+type User struct {
+	Ref   string `yaml:"ref" json:"ref"`
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email" json:"email"`
+	// Password is stored hashed; without one a seeded user keeps theirs
+	Password string `yaml:"password" json:"password"`
+	// CreatedAt defaults to the time of loading
+	CreatedAt *time.Time `yaml:"created_at" json:"created_at"`
+
+	source string
+}
+
+// Category is a category fixture, keyed by name
+type Category struct {
+	Ref         string `yaml:"ref" json:"ref"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Color       string `yaml:"color" json:"color"`
+	// Active defaults to true
+	Active    *bool      `yaml:"active" json:"active"`
+	CreatedAt *time.Time `yaml:"created_at" json:"created_at"`
+
+	source string
+}
+
+// Post is a post fixture, keyed by author and title. User and Categories
+// hold refs of user and category fixtures.
+type Post struct {
+	Ref        string     `yaml:"ref" json:"ref"`
+	User       string     `yaml:"user" json:"user"`
+	Title      string     `yaml:"title" json:"title"`
+	Content    string     `yaml:"content" json:"content"`
+	Published  bool       `yaml:"published" json:"published"`
+	Categories []string   `yaml:"categories" json:"categories"`
+	CreatedAt  *time.Time `yaml:"created_at" json:"created_at"`
+	// Status defaults to published or draft by Published. Fixtures may
+	// set any status, whatever the workflow allows from the current one.
+	Status models.PostStatus `yaml:"status" json:"status"`
+	// PublishAt is required for scheduled posts and defaults to the
+	// creation time for published ones
+	PublishAt *time.Time `yaml:"publish_at" json:"publish_at"`
+
+	source string
+}
+
+// Refs maps the refs of loaded fixtures to the IDs of their rows
+type Refs struct {
+	Users      map[string]int
+	Categories map[string]uint
+	Posts      map[string]int
+}
+
+// Parse decodes a fixture file, as JSON if name ends in .json and as YAML
+// otherwise. Unknown fields are an error, so typos do not go unnoticed.
+func Parse(name string, data []byte) (*File, error) {
+	var file File
+	var err error
+	if strings.EqualFold(path.Ext(name), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&file); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	for i := range file.Users {
+		file.Users[i].source = name
+	}
+	for i := range file.Categories {
+		file.Categories[i].source = name
+	}
+	for i := range file.Posts {
+		file.Posts[i].source = name
+	}
+	return &file, nil
+}
+
+// ReadFS reads the files of fsys matching patterns into one File.
+// Matches are read in name order, and a pattern matching nothing is an error.
+func ReadFS(fsys fs.FS, patterns ...string) (*File, error) {
+	all := &File{}
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fixture pattern %q: %w", pattern, err)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no fixture files match %q", pattern)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read fixtures: %w", err)
+			}
+			file, err := Parse(name, data)
+			if err != nil {
+				return nil, err
+			}
+			all.merge(file)
+		}
+	}
+	return all, nil
+}
+
+// ReadFiles reads the fixture files at paths into one File, in the order given
+func ReadFiles(paths ...string) (*File, error) {
+	all := &File{}
+	for _, name := range paths {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		file, err := Parse(name, data)
+		if err != nil {
+			return nil, err
+		}
+		all.merge(file)
+	}
+	return all, nil
+}
+
+// merge appends the records of other to f
+func (f *File) merge(other *File) {
+	f.Users = append(f.Users, other.Users...)
+	f.Categories = append(f.Categories, other.Categories...)
+	f.Posts = append(f.Posts, other.Posts...)
+}
+
+// LoadFS reads the files of fsys matching patterns and loads them into db
+func LoadFS(ctx context.Context, db *sql.DB, fsys fs.FS, patterns ...string) (*Refs, error) {
+	file, err := ReadFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return Load(ctx, db, file)
+}
+
+// Load upserts the fixtures of file into db in one transaction: users,
+// then categories, then posts with their categories. It checks every ref
+// before writing anything.
+func Load(ctx context.Context, db *sql.DB, file *File) (*Refs, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection cannot be nil")
+	}
+	if err := file.check(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	refs := &Refs{Users: map[string]int{}, Categories: map[string]uint{}, Posts: map[string]int{}}
+	for _, user := range file.Users {
+		id, err := upsertUser(ctx, tx, user)
+		if err != nil {
+			return nil, fmt.Errorf("%s: user %s: %w", user.source, user.label(), err)
+		}
+		if user.Ref != "" {
+			refs.Users[user.Ref] = id
+		}
+	}
+	for _, category := range file.Categories {
+		id, err := upsertCategory(ctx, tx, category)
+		if err != nil {
+			return nil, fmt.Errorf("%s: category %s: %w", category.source, category.label(), err)
+		}
+		if category.Ref != "" {
+			refs.Categories[category.Ref] = id
+		}
+	}
+	for _, post := range file.Posts {
+		id, err := upsertPost(ctx, tx, post, refs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: post %s: %w", post.source, post.label(), err)
+		}
+		if post.Ref != "" {
+			refs.Posts[post.Ref] = id
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit fixtures: %w", err)
+	}
+	return refs, nil
+}
+
+// check looks for duplicate refs and refs to fixtures that do not exist
+func (f *File) check() error {
+	var problems []error
+	seen := func(kind string, refs map[string]string, ref, source string) {
+		if ref == "" {
+			return
+		}
+		if other, ok := refs[ref]; ok {
+			problems = append(problems, fmt.Errorf("%s: %s ref %q is also used in %s", source, kind, ref, other))
+			return
+		}
+		refs[ref] = source
+	}
+
+	users := map[string]string{}
+	for _, user := range f.Users {
+		seen("user", users, user.Ref, user.source)
+	}
+	categories := map[string]string{}
+	for _, category := range f.Categories {
+		seen("category", categories, category.Ref, category.source)
+	}
+	posts := map[string]string{}
+	for _, post := range f.Posts {
+		seen("post", posts, post.Ref, post.source)
+		if _, ok := users[post.User]; !ok {
+			problems = append(problems, fmt.Errorf("%s: post %s: unknown user %q", post.source, post.label(), post.User))
+		}
+		for _, category := range post.Categories {
+			if _, ok := categories[category]; !ok {
+				problems = append(problems, fmt.Errorf("%s: post %s: unknown category %q", post.source, post.label(), category))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+func (u User) label() string     { return label(u.Ref, u.Email) }
+func (c Category) label() string { return label(c.Ref, c.Name) }
+func (p Post) label() string     { return label(p.Ref, p.Title) }
+
+// label names a fixture in errors by its ref, or its natural key without one
+func label(ref, key string) string {
+	if ref != "" {
+		return ref
+	}
+	return fmt.Sprintf("%q", key)
+}
+
+func upsertUser(ctx context.Context, tx *sql.Tx, user User) (int, error) {
+	req := &models.CreateUserRequest{Name: user.Name, Email: user.Email, Password: user.Password}
+	if err := req.Validate(); err != nil {
+		return 0, err
+	}
+	var passwordHash sql.NullString
+	if req.Password != "" {
+		hash, err := security.NewPasswordService().HashPassword(req.Password)
+		if err != nil {
+			return 0, err
+		}
+		passwordHash = sql.NullString{String: hash, Valid: true}
+	}
+
+	now := time.Now()
+	var id int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO users (name, email, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (email) DO UPDATE SET
+			name = excluded.name,
+			password_hash = COALESCE(excluded.password_hash, users.password_hash),
+			created_at = COALESCE($6, users.created_at),
+			updated_at = excluded.updated_at,
+			deleted_at = NULL
+		RETURNING id
+	`, req.Name, req.Email, passwordHash, createdAt(user.CreatedAt, now), now, user.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func upsertCategory(ctx context.Context, tx *sql.Tx, category Category) (uint, error) {
+	req := &models.CreateCategoryRequest{Name: category.Name, Description: category.Description, Color: category.Color}
+	if err := req.Validate(); err != nil {
+		return 0, err
+	}
+	// Store the name and color the way the GORM hooks of models.Category do
+	name := strings.Join(strings.Fields(req.Name), " ")
+	color := models.DefaultCategoryColor
+	if req.Color != "" {
+		color, _ = models.NormalizeColor(req.Color)
+	}
+	active := category.Active == nil || *category.Active
+
+	now := time.Now()
+	var id uint
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO categories (name, description, color, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			description = excluded.description,
+			color = excluded.color,
+			active = excluded.active,
+			created_at = COALESCE($7, categories.created_at),
+			updated_at = excluded.updated_at,
+			deleted_at = NULL
+		RETURNING id
+	`, name, req.Description, color, active, createdAt(category.CreatedAt, now), now, category.CreatedAt).Scan(&id)
+	return id, err
+}
+
+// upsertPost finds the post by author and title, as posts have no unique
+// key to upsert on, and makes its categories the ones of the fixture
+func upsertPost(ctx context.Context, tx *sql.Tx, post Post, refs *Refs) (int, error) {
+	now := time.Now()
+	status, publishAt, err := post.workflow(now)
+	if err != nil {
+		return 0, err
+	}
+	req := &models.CreatePostRequest{UserID: refs.Users[post.User], Title: post.Title, Content: post.Content, Published: status == models.PostPublished}
+	if err := req.Validate(); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		`SELECT id FROM posts WHERE user_id = $1 AND title = $2 ORDER BY id LIMIT 1`,
+		req.UserID, req.Title).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO posts (user_id, title, content, published, status, publish_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, req.UserID, req.Title, req.Content, req.Published, status, publishAt, createdAt(post.CreatedAt, now), now).Scan(&id)
+	case err == nil:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts SET
+				content = $1,
+				published = $2,
+				status = $3,
+				publish_at = $4,
+				created_at = COALESCE($5, created_at),
+				updated_at = $6,
+				deleted_at = NULL
+			WHERE id = $7
+		`, req.Content, req.Published, status, publishAt, post.CreatedAt, now, id)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_categories WHERE post_id = $1`, id); err != nil {
+		return 0, err
+	}
+	for _, category := range post.Categories {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO post_categories (post_id, category_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, id, refs.Categories[category])
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// workflow returns the status and publish_at of the post. publish_at is in
+// UTC, as PostRepository stores it.
+func (p Post) workflow(now time.Time) (models.PostStatus, *time.Time, error) {
+	status := p.Status
+	if status == "" {
+		status = models.PostDraft
+		if p.Published {
+			status = models.PostPublished
+		}
+	}
+	if !status.Valid() {
+		return "", nil, fmt.Errorf("unknown status %q", status)
+	}
+	if p.Published && status != models.PostPublished {
+		return "", nil, fmt.Errorf("published conflicts with status %s", status)
+	}
+
+	publishAt := p.PublishAt
+	switch status {
+	case models.PostScheduled:
+		if publishAt == nil {
+			return "", nil, errors.New("publish_at is required for scheduled posts")
+		}
+	case models.PostPublished:
+		if publishAt == nil {
+			at := createdAt(p.CreatedAt, now)
+			publishAt = &at
+		}
+	case models.PostDraft, models.PostInReview:
+		if publishAt != nil {
+			return "", nil, fmt.Errorf("publish_at is not allowed for %s posts", status)
+		}
+	}
+	if publishAt != nil {
+		utc := publishAt.UTC()
+		publishAt = &utc
+	}
+	return status, publishAt, nil
+}
+
+// createdAt is the time a fixture was created at, now unless it says
+func createdAt(at *time.Time, now time.Time) time.Time {
+	if at != nil {
+		return *at
+	}
+	return now
+}
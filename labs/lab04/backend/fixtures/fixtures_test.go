@@ -0,0 +1,224 @@
+package fixtures_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lab04-backend/fixtures"
+	"lab04-backend/fixtures/fixturetest"
+	"lab04-backend/models"
+	"lab04-backend/repository"
+)
+
+func TestLoad(t *testing.T) {
+	db, refs := fixturetest.Open(t, "testdata/users.yaml", "testdata/*.json")
+	ctx := context.Background()
+
+	if len(refs.Users) != 2 || len(refs.Categories) != 2 || len(refs.Posts) != 3 {
+		t.Fatalf("Expected refs of every fixture, got %+v", refs)
+	}
+
+	var userID int
+	var title string
+	var published bool
+	err := db.QueryRow(`SELECT user_id, title, published FROM posts WHERE id = $1`, refs.Posts["hello"]).Scan(&userID, &title, &published)
+	if err != nil {
+		t.Fatalf("Failed to read post: %v", err)
+	}
+	if userID != refs.Users["alice"] || title != "Hello, world" || !published {
+		t.Errorf("Expected alice's published post, got user %d %q %v", userID, title, published)
+	}
+
+	var createdAt time.Time
+	if err := db.QueryRow(`SELECT created_at FROM users WHERE id = $1`, refs.Users["alice"]).Scan(&createdAt); err != nil {
+		t.Fatalf("Failed to read user: %v", err)
+	}
+	if !createdAt.Equal(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected created_at from the fixture, got %s", createdAt)
+	}
+
+	var name, color string
+	var active bool
+	err = db.QueryRow(`SELECT name, color, active FROM categories WHERE id = $1`, refs.Categories["go"]).Scan(&name, &color, &active)
+	if err != nil {
+		t.Fatalf("Failed to read category: %v", err)
+	}
+	if name != "Go lang" || color != "#aabbcc" || !active {
+		t.Errorf("Expected normalized active category, got %q %q %v", name, color, active)
+	}
+	if err := db.QueryRow(`SELECT active FROM categories WHERE id = $1`, refs.Categories["old"]).Scan(&active); err != nil || active {
+		t.Errorf("Expected inactive category, got %v %v", active, err)
+	}
+
+	posts := repository.NewPostRepository(db)
+	scheduled, err := posts.GetByID(ctx, refs.Posts["scheduled"])
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if scheduled.Status != models.PostScheduled || scheduled.Published || scheduled.PublishAt == nil || scheduled.PublishAt.Year() != 2030 {
+		t.Errorf("Expected a post scheduled for 2030, got %+v", scheduled)
+	}
+	hello, err := posts.GetByID(ctx, refs.Posts["hello"])
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if hello.Status != models.PostPublished || hello.PublishAt == nil {
+		t.Errorf("Expected a published post with publish_at, got %+v", hello)
+	}
+
+	var links int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM post_categories WHERE post_id = $1`, refs.Posts["hello"]).Scan(&links); err != nil || links != 2 {
+		t.Errorf("Expected 2 categories on the post, got %d %v", links, err)
+	}
+
+	users := repository.NewUserRepository(db)
+	if user, err := users.Authenticate(ctx, "alice@example.com", "secret42"); err != nil || user.ID != refs.Users["alice"] {
+		t.Errorf("Expected alice to log in with the fixture password, got %+v %v", user, err)
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE posts SET deleted_at = CURRENT_TIMESTAMP, content = 'changed' WHERE id = $1`, refs.Posts["hello"]); err != nil {
+			t.Fatalf("Failed to change post: %v", err)
+		}
+		again := fixturetest.Load(t, db, "testdata/users.yaml", "testdata/*.json")
+		if !reflect.DeepEqual(again, refs) {
+			t.Errorf("Expected the same IDs on reload, got %+v and %+v", again, refs)
+		}
+
+		counts := map[string]int{"users": 2, "categories": 2, "posts": 3, "post_categories": 3}
+		for table, want := range counts {
+			var got int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&got); err != nil || got != want {
+				t.Errorf("Expected %d rows in %s, got %d %v", want, table, got, err)
+			}
+		}
+		var content string
+		var deleted *time.Time
+		if err := db.QueryRow(`SELECT content, deleted_at FROM posts WHERE id = $1`, refs.Posts["hello"]).Scan(&content, &deleted); err != nil {
+			t.Fatalf("Failed to read post: %v", err)
+		}
+		if content != "First post" || deleted != nil {
+			t.Errorf("Expected the post restored from the fixture, got %q deleted at %v", content, deleted)
+		}
+		// bob has no password in the fixture, so the one he set is kept
+		if _, err := db.Exec(`UPDATE users SET password_hash = (SELECT password_hash FROM users WHERE email = 'alice@example.com') WHERE email = 'bob@example.com'`); err != nil {
+			t.Fatalf("Failed to set password: %v", err)
+		}
+		fixturetest.Load(t, db, "testdata/users.yaml")
+		if _, err := users.Authenticate(ctx, "bob@example.com", "secret42"); err != nil {
+			t.Errorf("Expected bob's password kept on reload, got %v", err)
+		}
+	})
+
+	t.Run("errors roll back", func(t *testing.T) {
+		file, err := fixtures.Parse("bad.yaml", []byte(`
+users:
+  - ref: dave
+    name: Dave
+    email: dave@example.com
+  - ref: eve
+    name: E
+    email: eve@example.com
+`))
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		_, err = fixtures.Load(ctx, db, file)
+		if err == nil || !strings.Contains(err.Error(), "bad.yaml: user eve") {
+			t.Errorf("Expected the invalid user named in the error, got %v", err)
+		}
+		var count int
+		db.QueryRow(`SELECT COUNT(*) FROM users WHERE email = 'dave@example.com'`).Scan(&count)
+		if count != 0 {
+			t.Errorf("Expected no users loaded after an error, got %d", count)
+		}
+	})
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty.yaml", "", ""},
+		{"typo.yaml", "users:\n  - ref: a\n    mail: a@example.com\n", "field mail not found"},
+		{"typo.json", `{"posts": [{"titel": "x"}]}`, `unknown field "titel"`},
+		{"bad.json", `{"users": [`, "failed to parse bad.json"},
+	}
+	for _, tt := range tests {
+		_, err := fixtures.Parse(tt.name, []byte(tt.content))
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("Parse(%s) failed: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Parse(%s): expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestLoadRefs(t *testing.T) {
+	db, _ := fixturetest.Open(t)
+	file, err := fixtures.Parse("refs.yaml", []byte(`
+users:
+  - {ref: alice, name: Alice, email: alice@example.com}
+  - {ref: alice, name: Alice Again, email: alice2@example.com}
+posts:
+  - {ref: p, user: bob, title: A long title, categories: [go]}
+`))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	_, err = fixtures.Load(context.Background(), db, file)
+	if err == nil {
+		t.Fatal("Load() should fail on bad refs")
+	}
+	for _, want := range []string{`user ref "alice" is also used`, `unknown user "bob"`, `unknown category "go"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	db, _ := fixturetest.Open(t)
+	file, err := fixtures.ReadFS(fixtures.Seed, "seed/*.yaml")
+	if err != nil {
+		t.Fatalf("ReadFS() failed: %v", err)
+	}
+	refs, err := fixtures.Load(context.Background(), db, file)
+	if err != nil {
+		t.Fatalf("Load() of the seed data failed: %v", err)
+	}
+	if len(refs.Users) != len(file.Users) || len(refs.Posts) != len(file.Posts) {
+		t.Errorf("Expected every seed record to have a ref, got %+v", refs)
+	}
+	if _, err := fixtures.ReadFS(fixtures.Seed, "seed/*.json"); err == nil {
+		t.Error("ReadFS() should fail when a pattern matches nothing")
+	}
+}
+
+func TestLoadStatus(t *testing.T) {
+	db, _ := fixturetest.Open(t, "testdata/users.yaml")
+	tests := []struct {
+		post    string
+		wantErr string
+	}{
+		{`{user: alice, title: Soon enough, status: scheduled}`, "publish_at is required"},
+		{`{user: alice, title: Soon enough, status: later}`, `unknown status "later"`},
+		{`{user: alice, title: Soon enough, content: x, published: true, status: archived}`, "conflicts with status archived"},
+		{`{user: alice, title: Soon enough, status: draft, publish_at: 2030-01-01T00:00:00Z}`, "not allowed for draft posts"},
+	}
+	for _, tt := range tests {
+		file, err := fixtures.Parse("status.yaml", []byte("users:\n  - {ref: alice, name: Alice Smith, email: alice@example.com}\nposts:\n  - "+tt.post+"\n"))
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if _, err := fixtures.Load(context.Background(), db, file); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Load(%s): expected error containing %q, got %v", tt.post, tt.wantErr, err)
+		}
+	}
+}
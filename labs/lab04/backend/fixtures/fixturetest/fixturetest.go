@@ -0,0 +1,44 @@
+// Package fixturetest sets up test databases from fixture files
+package fixturetest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/fixtures"
+)
+
+// Open creates a migrated SQLite database in a temporary directory and
+// loads the fixture files matching patterns into it. Patterns are relative
+// to the working directory of the test, usually its package directory.
+// The database is closed when the test ends.
+func Open(t testing.TB, patterns ...string) (*sql.DB, *fixtures.Refs) {
+	t.Helper()
+	db, err := database.InitDBWithConfig(&database.Config{
+		DatabasePath: filepath.Join(t.TempDir(), "fixtures.db"),
+		MaxOpenConns: 5,
+		MaxIdleConns: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to initialize test database: %v", err)
+	}
+	t.Cleanup(func() { database.CloseDB(db) })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	return db, Load(t, db, patterns...)
+}
+
+// Load loads the fixture files matching patterns into db
+func Load(t testing.TB, db *sql.DB, patterns ...string) *fixtures.Refs {
+	t.Helper()
+	refs, err := fixtures.LoadFS(context.Background(), db, os.DirFS("."), patterns...)
+	if err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	return refs
+}
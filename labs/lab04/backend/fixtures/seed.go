@@ -0,0 +1,8 @@
+package fixtures
+
+import "embed"
+
+// Seed holds the demo data loaded by the seed command, in seed/*.yaml
+//
+//go:embed seed/*.yaml
+var Seed embed.FS
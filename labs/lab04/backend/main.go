@@ -1,37 +1,65 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"lab04-backend/database"
+	"lab04-backend/fixtures"
 	"lab04-backend/repository"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
-	// TODO: Initialize database connection
-	db, err := database.InitDB()
+	seed := flag.Bool("seed", false, "load the demo data from fixtures/seed, overwriting rows with the same keys")
+	flag.Parse()
+
+	// Connect to the database; DATABASE_URL selects Postgres or another
+	// SQLite file
+	config := database.DefaultConfig()
+	config.DatabaseURL = os.Getenv("DATABASE_URL")
+	db, err := database.InitDBWithConfig(config)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer db.Close()
 
-	// TODO: Run migrations (using goose-based approach)
+	// Apply pending goose migrations
 	if err := database.RunMigrations(db); err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
-	// TODO: Create repository instances
+	// Create the repositories
 	userRepo := repository.NewUserRepository(db)
 	postRepo := repository.NewPostRepository(db)
 
 	// Demo operations
 	fmt.Println("Database initialized successfully!")
+	health, err := database.HealthCheck(context.Background(), db)
+	if err != nil {
+		log.Fatal("Database health check failed:", err)
+	}
+	fmt.Printf("Database health: %s (%s, %d open connections)\n", health.Status, health.Latency, health.Pool.OpenConnections)
 	fmt.Printf("User repository: %T\n", userRepo)
 	fmt.Printf("Post repository: %T\n", postRepo)
 
-	// TODO: Add some demo data operations here
-	// You can test your CRUD operations
+	// Demo data from fixtures/seed, only when asked: loading upserts by
+	// email and title, so it would reset changes to the seeded rows
+	ctx := context.Background()
+	if *seed {
+		if _, err := fixtures.LoadFS(ctx, db, fixtures.Seed, "seed/*.yaml"); err != nil {
+			log.Fatal("Failed to load demo data:", err)
+		}
+	}
+	users, err := userRepo.Count(ctx)
+	if err != nil {
+		log.Fatal("Failed to count users:", err)
+	}
+	posts, err := postRepo.Count(ctx)
+	if err != nil {
+		log.Fatal("Failed to count posts:", err)
+	}
+	fmt.Printf("Data: %d users, %d posts\n", users, posts)
 }
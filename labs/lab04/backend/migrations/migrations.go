@@ -0,0 +1,10 @@
+// Package migrations embeds the goose migrations of the lab04 schema,
+// one folder per SQL dialect, so they ship inside the binaries.
+package migrations
+
+import "embed"
+
+// FS holds the sqlite and postgres migration folders
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
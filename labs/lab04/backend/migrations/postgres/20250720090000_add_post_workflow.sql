@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Publishing workflow: status replaces the published flag, which is kept in
+-- step for existing queries. publish_at is when a scheduled post goes live,
+-- or when a published post went live.
+ALTER TABLE posts ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'in_review', 'scheduled', 'published', 'archived'));
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMPTZ NULL;
+
+UPDATE posts SET status = 'published', publish_at = created_at WHERE published = TRUE;
+
+-- Index for the scheduler looking for due posts
+CREATE INDEX idx_posts_status_publish_at ON posts(status, publish_at);
+
+-- Every version of a post's title and content, numbered from 1 per post
+CREATE TABLE post_revisions (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    content TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (post_id, revision),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- The current content of existing posts is their first revision
+INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+SELECT id, 1, title, content, updated_at FROM posts;
+-- +goose StatementEnd
+
+-- Record a revision whenever a post is created or its title or content changes
+-- +goose StatementBegin
+CREATE FUNCTION posts_record_revision() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.title IS NOT DISTINCT FROM NEW.title
+        AND OLD.content IS NOT DISTINCT FROM NEW.content THEN
+        RETURN NEW;
+    END IF;
+    INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+    SELECT NEW.id, COALESCE(MAX(revision), 0) + 1, NEW.title, NEW.content, NEW.updated_at
+    FROM post_revisions WHERE post_id = NEW.id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_revision AFTER INSERT OR UPDATE OF title, content ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_record_revision();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS posts_revision ON posts;
+DROP FUNCTION IF EXISTS posts_record_revision();
+DROP TABLE post_revisions;
+DROP INDEX IF EXISTS idx_posts_status_publish_at;
+ALTER TABLE posts DROP COLUMN publish_at;
+ALTER TABLE posts DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,107 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comments and likes are counted onto posts by the triggers below, so
+-- listing posts with their engagement needs no joins
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+
+-- Comments form threads: a reply has the comment it answers as parent
+CREATE TABLE comments (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+-- Index for reading the comments of a post in order
+CREATE INDEX idx_comments_post_created ON comments(post_id, created_at);
+
+-- Index for finding the replies of a comment
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+
+-- A user likes a post at most once
+CREATE TABLE post_likes (
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_likes_user_id ON post_likes(user_id);
+-- +goose StatementEnd
+
+-- Keep posts.comment_count equal to the comments that are not deleted
+-- +goose StatementBegin
+CREATE FUNCTION comments_count() RETURNS trigger AS $$
+DECLARE
+    delta INTEGER := 0;
+BEGIN
+    -- NEW is not set on DELETE, so each operation reads only its own rows
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.deleted_at IS NULL THEN
+            delta := 1;
+        END IF;
+    ELSIF TG_OP = 'DELETE' THEN
+        IF OLD.deleted_at IS NULL THEN
+            UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+        END IF;
+        RETURN NULL;
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        delta := -1;
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        delta := 1;
+    END IF;
+    IF delta <> 0 THEN
+        UPDATE posts SET comment_count = comment_count + delta WHERE id = NEW.post_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER comments_count AFTER INSERT OR DELETE OR UPDATE OF deleted_at ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_count();
+-- +goose StatementEnd
+
+-- Keep posts.like_count equal to the likes
+-- +goose StatementBegin
+CREATE FUNCTION post_likes_count() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE posts SET like_count = like_count + 1 WHERE id = NEW.post_id;
+    ELSE
+        UPDATE posts SET like_count = like_count - 1 WHERE id = OLD.post_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER post_likes_count AFTER INSERT OR DELETE ON post_likes
+    FOR EACH ROW EXECUTE FUNCTION post_likes_count();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS post_likes_count ON post_likes;
+DROP FUNCTION IF EXISTS post_likes_count();
+DROP TRIGGER IF EXISTS comments_count ON comments;
+DROP FUNCTION IF EXISTS comments_count();
+DROP TABLE post_likes;
+DROP TABLE comments;
+ALTER TABLE posts DROP COLUMN like_count;
+ALTER TABLE posts DROP COLUMN comment_count;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Full-text index over post titles and content.
+-- Needs SQLite with FTS5; RunMigrations skips this file when it is missing
+-- (build with -tags sqlite_fts5).
+CREATE VIRTUAL TABLE posts_fts USING fts5(
+    title,
+    content,
+    content = 'posts',
+    content_rowid = 'id'
+);
+-- +goose StatementEnd
+
+-- Keep the index in sync with posts
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO posts_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+-- +goose StatementEnd
+
+-- Index the posts that already exist
+-- +goose StatementBegin
+INSERT INTO posts_fts (posts_fts) VALUES ('rebuild');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS posts_fts_update;
+DROP TRIGGER IF EXISTS posts_fts_delete;
+DROP TRIGGER IF EXISTS posts_fts_insert;
+DROP TABLE IF EXISTS posts_fts;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Indexes for keyset pagination by (created_at, id)
+CREATE INDEX idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX idx_posts_created_at_id ON posts(created_at, id);
+CREATE INDEX idx_categories_created_at_id ON categories(created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_categories_created_at_id;
+DROP INDEX IF EXISTS idx_posts_created_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Publishing workflow: status replaces the published flag, which is kept in
+-- step for existing queries. publish_at is when a scheduled post goes live,
+-- or when a published post went live.
+ALTER TABLE posts ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'in_review', 'scheduled', 'published', 'archived'));
+ALTER TABLE posts ADD COLUMN publish_at DATETIME NULL;
+
+UPDATE posts SET status = 'published', publish_at = created_at WHERE published = TRUE;
+
+-- Index for the scheduler looking for due posts
+CREATE INDEX idx_posts_status_publish_at ON posts(status, publish_at);
+
+-- Every version of a post's title and content, numbered from 1 per post
+CREATE TABLE post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    content TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (post_id, revision),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- The current content of existing posts is their first revision
+INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+SELECT id, 1, title, content, updated_at FROM posts;
+-- +goose StatementEnd
+
+-- Record a revision whenever a post is created or its title or content changes
+-- +goose StatementBegin
+CREATE TRIGGER posts_revision_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+    VALUES (new.id, 1, new.title, new.content, new.updated_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_revision_update AFTER UPDATE OF title, content ON posts
+WHEN old.title IS NOT new.title OR old.content IS NOT new.content BEGIN
+    INSERT INTO post_revisions (post_id, revision, title, content, created_at)
+    SELECT new.id, COALESCE(MAX(revision), 0) + 1, new.title, new.content, new.updated_at
+    FROM post_revisions WHERE post_id = new.id;
+END;
+-- +goose StatementEnd
+
+-- SQLite enforces the foreign key only with PRAGMA foreign_keys, so delete
+-- the revisions of a removed post here
+-- +goose StatementBegin
+CREATE TRIGGER posts_revision_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM post_revisions WHERE post_id = old.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS posts_revision_delete;
+DROP TRIGGER IF EXISTS posts_revision_update;
+DROP TRIGGER IF EXISTS posts_revision_insert;
+DROP TABLE post_revisions;
+DROP INDEX IF EXISTS idx_posts_status_publish_at;
+ALTER TABLE posts DROP COLUMN publish_at;
+ALTER TABLE posts DROP COLUMN status;
+-- +goose StatementEnd
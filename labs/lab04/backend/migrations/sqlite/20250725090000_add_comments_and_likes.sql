@@ -0,0 +1,101 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Comments and likes are counted onto posts by the triggers below, so
+-- listing posts with their engagement needs no joins
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+
+-- Comments form threads: a reply has the comment it answers as parent
+CREATE TABLE comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    deleted_at DATETIME NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+-- Index for reading the comments of a post in order
+CREATE INDEX idx_comments_post_created ON comments(post_id, created_at);
+
+-- Index for finding the replies of a comment
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+
+CREATE INDEX idx_comments_user_id ON comments(user_id);
+
+-- A user likes a post at most once
+CREATE TABLE post_likes (
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_likes_user_id ON post_likes(user_id);
+-- +goose StatementEnd
+
+-- Keep posts.comment_count equal to the comments that are not deleted
+-- +goose StatementBegin
+CREATE TRIGGER comments_count_insert AFTER INSERT ON comments
+WHEN new.deleted_at IS NULL BEGIN
+    UPDATE posts SET comment_count = comment_count + 1 WHERE id = new.post_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER comments_count_delete AFTER DELETE ON comments
+WHEN old.deleted_at IS NULL BEGIN
+    UPDATE posts SET comment_count = comment_count - 1 WHERE id = old.post_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER comments_count_update AFTER UPDATE OF deleted_at ON comments
+WHEN (old.deleted_at IS NULL) <> (new.deleted_at IS NULL) BEGIN
+    UPDATE posts SET comment_count = comment_count + CASE WHEN new.deleted_at IS NULL THEN 1 ELSE -1 END
+    WHERE id = new.post_id;
+END;
+-- +goose StatementEnd
+
+-- Keep posts.like_count equal to the likes
+-- +goose StatementBegin
+CREATE TRIGGER post_likes_count_insert AFTER INSERT ON post_likes BEGIN
+    UPDATE posts SET like_count = like_count + 1 WHERE id = new.post_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER post_likes_count_delete AFTER DELETE ON post_likes BEGIN
+    UPDATE posts SET like_count = like_count - 1 WHERE id = old.post_id;
+END;
+-- +goose StatementEnd
+
+-- SQLite enforces the foreign keys only with PRAGMA foreign_keys, so delete
+-- the comments and likes of a removed post here
+-- +goose StatementBegin
+CREATE TRIGGER posts_engagement_delete AFTER DELETE ON posts BEGIN
+    DELETE FROM comments WHERE post_id = old.id;
+    DELETE FROM post_likes WHERE post_id = old.id;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS posts_engagement_delete;
+DROP TRIGGER IF EXISTS post_likes_count_delete;
+DROP TRIGGER IF EXISTS post_likes_count_insert;
+DROP TRIGGER IF EXISTS comments_count_update;
+DROP TRIGGER IF EXISTS comments_count_delete;
+DROP TRIGGER IF EXISTS comments_count_insert;
+DROP TABLE post_likes;
+DROP TABLE comments;
+ALTER TABLE posts DROP COLUMN like_count;
+ALTER TABLE posts DROP COLUMN comment_count;
+-- +goose StatementEnd
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -37,91 +41,120 @@ type UpdateCategoryRequest struct {
 	Active      *bool   `json:"active,omitempty"`
 }
 
-// TODO: Implement GORM model methods and hooks
+// DefaultCategoryColor is used for categories created without a color
+const DefaultCategoryColor = "#007bff"
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-f]{3}|[0-9a-f]{6})$`)
 
 // TableName specifies the table name for GORM (optional - GORM auto-infers)
 func (Category) TableName() string {
 	return "categories"
 }
 
-// TODO: Implement BeforeCreate hook
+// BeforeCreate normalizes the name and color before the category is inserted
 func (c *Category) BeforeCreate(tx *gorm.DB) error {
-	// TODO: GORM BeforeCreate hook
-	// - Validate data before creation
-	// - Set default values
-	// - Perform any pre-creation logic
-	// Example: if c.Color == "" { c.Color = "#007bff" }
-	return nil
+	if c.Color == "" {
+		c.Color = DefaultCategoryColor
+	}
+	return c.normalize()
 }
 
-// TODO: Implement AfterCreate hook
+// AfterCreate is called by GORM after the category is inserted
 func (c *Category) AfterCreate(tx *gorm.DB) error {
-	// TODO: GORM AfterCreate hook
-	// - Log creation
-	// - Send notifications
-	// - Update cache
-	// Example: log.Printf("Category created: %s", c.Name)
 	return nil
 }
 
-// TODO: Implement BeforeUpdate hook
+// BeforeUpdate applies the BeforeCreate normalization to saved changes
 func (c *Category) BeforeUpdate(tx *gorm.DB) error {
-	// TODO: GORM BeforeUpdate hook
-	// - Validate changes
-	// - Prevent certain updates
-	// - Clean up related data
+	return c.normalize()
+}
+
+// normalize collapses whitespace in the name and turns the color into
+// lowercase #rrggbb form
+func (c *Category) normalize() error {
+	c.Name = strings.Join(strings.Fields(c.Name), " ")
+	if len(c.Name) < 2 || len(c.Name) > 100 {
+		return errors.New("category name should be 2 to 100 characters")
+	}
+	if c.Color == "" {
+		return nil
+	}
+	color, err := NormalizeColor(c.Color)
+	if err != nil {
+		return err
+	}
+	c.Color = color
 	return nil
 }
 
-// TODO: Implement Validate method for CreateCategoryRequest
+// NormalizeColor turns a hex color like "ABC", "#abc" or "#AABBCC" into "#aabbcc"
+func NormalizeColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if !strings.HasPrefix(color, "#") {
+		color = "#" + color
+	}
+	if !hexColorPattern.MatchString(color) {
+		return "", fmt.Errorf("invalid hex color %q", color)
+	}
+	if len(color) == 4 {
+		color = string([]byte{'#', color[1], color[1], color[2], color[2], color[3], color[3]})
+	}
+	return color, nil
+}
+
+// Validate checks the request before it is turned into a category
 func (req *CreateCategoryRequest) Validate() error {
-	// TODO: Add validation logic for GORM model
-	// - Name should be unique (checked at database level via GORM)
-	// - Color should be valid hex color
-	// - Description should not exceed limits
-	// Example using validator package:
-	// return validator.New().Struct(req)
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 2 || len(name) > 100 {
+		return errors.New("category name should be 2 to 100 characters")
+	}
+	if len(req.Description) > 500 {
+		return errors.New("category description should be at most 500 characters")
+	}
+	if req.Color != "" {
+		if _, err := NormalizeColor(req.Color); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// TODO: Implement ToCategory method
+// ToCategory converts the request to an active category
 func (req *CreateCategoryRequest) ToCategory() *Category {
-	// TODO: Convert request to GORM model
-	// - Map fields from request to model
-	// - Set default values
-	// Example:
-	// return &Category{
-	//     Name:        req.Name,
-	//     Description: req.Description,
-	//     Color:       req.Color,
-	//     Active:      true,
-	// }
-	return nil
+	return &Category{
+		Name:        req.Name,
+		Description: req.Description,
+		Color:       req.Color,
+		Active:      true,
+	}
 }
 
-// TODO: Implement GORM scopes (reusable query logic)
+// ActiveCategories is a GORM scope for active categories
 func ActiveCategories(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for active categories
-	// return db.Where("active = ?", true)
-	return db
+	return db.Where("categories.active = ?", true)
 }
 
+// CategoriesWithPosts is a GORM scope for categories with at least one post
+// that is not deleted
 func CategoriesWithPosts(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for categories with posts
-	// return db.Joins("Posts").Where("posts.id IS NOT NULL")
-	return db
+	return db.Where(`EXISTS (
+		SELECT 1 FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		WHERE pc.category_id = categories.id AND p.deleted_at IS NULL
+	)`)
 }
 
-// TODO: Implement model validation methods
+// IsActive reports whether the category is active
 func (c *Category) IsActive() bool {
-	// TODO: Check if category is active
 	return c.Active
 }
 
+// PostCount counts the posts of this category that are not deleted
 func (c *Category) PostCount(db *gorm.DB) (int64, error) {
-	// TODO: Get post count for this category using GORM association
-	// var count int64
-	// err := db.Model(c).Association("Posts").Count(&count)
-	// return count, err
-	return 0, nil
+	association := db.Model(c).Where("posts.deleted_at IS NULL").Association("Posts")
+	if association.Error != nil {
+		return 0, association.Error
+	}
+	count := association.Count()
+	return count, association.Error
 }
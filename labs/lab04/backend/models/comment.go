@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// MaxCommentLength is the longest comment content, in characters
+const MaxCommentLength = 2000
+
+// Comment is a comment on a post. A reply has the comment it answers as
+// ParentID; top level comments have none.
+type Comment struct {
+	ID        int       `json:"id" db:"id"`
+	PostID    int       `json:"post_id" db:"post_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	ParentID  *int      `json:"parent_id,omitempty" db:"parent_id"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// DeletedAt is set while the comment is soft deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Replies is only filled in by CommentRepository.GetThread
+	Replies []*Comment `json:"replies,omitempty" db:"-"`
+}
+
+// PostLike records that a user likes a post
+type PostLike struct {
+	PostID    int       `json:"post_id" db:"post_id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateCommentRequest represents the payload for commenting on a post
+type CreateCommentRequest struct {
+	PostID int `json:"post_id"`
+	UserID int `json:"user_id"`
+	// ParentID is the comment this one replies to, on the same post
+	ParentID *int   `json:"parent_id,omitempty"`
+	Content  string `json:"content"`
+}
+
+// UpdateCommentRequest represents the payload for editing a comment
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// Validate checks the IDs and the content
+func (req *CreateCommentRequest) Validate() error {
+	if req.PostID <= 0 {
+		return errors.New("comment's PostID should be greater than 0")
+	}
+	if req.UserID <= 0 {
+		return errors.New("comment's UserID should be greater than 0")
+	}
+	if req.ParentID != nil && *req.ParentID <= 0 {
+		return errors.New("comment's ParentID should be greater than 0")
+	}
+	return validateCommentContent(req.Content)
+}
+
+// Validate checks the content
+func (req *UpdateCommentRequest) Validate() error {
+	return validateCommentContent(req.Content)
+}
+
+// validateCommentContent requires content that is not blank and at most
+// MaxCommentLength characters
+func validateCommentContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("comment should not be empty")
+	}
+	if len([]rune(content)) > MaxCommentLength {
+		return errors.New("comment should be at most 2000 characters")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateCommentRequest_Validate(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name    string
+		req     CreateCommentRequest
+		wantErr bool
+	}{
+		{"valid comment", CreateCommentRequest{PostID: 1, UserID: 2, Content: "Nice post"}, false},
+		{"missing post", CreateCommentRequest{UserID: 2, Content: "Nice post"}, true},
+		{"missing user", CreateCommentRequest{PostID: 1, Content: "Nice post"}, true},
+		{"invalid parent", CreateCommentRequest{PostID: 1, UserID: 2, ParentID: &zero, Content: "Nice post"}, true},
+		{"blank content", CreateCommentRequest{PostID: 1, UserID: 2, Content: " \n\t"}, true},
+		{"longest content", CreateCommentRequest{PostID: 1, UserID: 2, Content: strings.Repeat("é", MaxCommentLength)}, false},
+		{"too long", CreateCommentRequest{PostID: 1, UserID: 2, Content: strings.Repeat("a", MaxCommentLength+1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -15,6 +15,18 @@ type Post struct {
 	Published bool      `json:"published" db:"published"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// DeletedAt is set while the post is soft deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Status is the workflow state; Published is true only when it is
+	// PostPublished
+	Status PostStatus `json:"status" db:"status"`
+	// PublishAt is when a scheduled post goes live, or when a published
+	// post went live
+	PublishAt *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	// CommentCount and LikeCount are kept up to date by database triggers.
+	// Deleted comments are not counted.
+	CommentCount int `json:"comment_count" db:"comment_count"`
+	LikeCount    int `json:"like_count" db:"like_count"`
 }
 
 // CreatePostRequest represents the payload for creating a post
@@ -70,24 +82,41 @@ func (req *CreatePostRequest) Validate() error {
 	return nil
 }
 
+// Validate checks the fields that are being changed
+func (req *UpdatePostRequest) Validate() error {
+	if req.Title != nil && len(*req.Title) < 5 {
+		return errors.New("title should be at least 5 characters")
+	}
+	return nil
+}
+
 // Implement ToPost method for CreatePostRequest
 func (req *CreatePostRequest) ToPost() *Post {
 	// Convert CreatePostRequest to Post
 	// Set timestamps to current time
-	return &Post{
+	now := time.Now()
+	post := &Post{
 		UserID:    req.UserID,
 		Title:     req.Title,
 		Content:   req.Content,
 		Published: req.Published,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Status:    PostDraft,
 	}
+	if req.Published {
+		post.Status = PostPublished
+		post.PublishAt = &now
+	}
+	return post
 }
 
 // Implement ScanRow method for Post
 func (p *Post) ScanRow(row *sql.Row) error {
 	// Scan database row into Post struct
 	// Handle the case where row might be nil
+	// Columns are expected in table order, then deleted_at, status, publish_at,
+	// comment_count and like_count
 	if row == nil {
 		return errors.New("sql row is nil")
 	}
@@ -99,11 +128,13 @@ func (p *Post) ScanRow(row *sql.Row) error {
 		&p.Published,
 		&p.CreatedAt,
 		&p.UpdatedAt,
+		&p.DeletedAt,
+		&p.Status,
+		&p.PublishAt,
+		&p.CommentCount,
+		&p.LikeCount,
 	)
-	if err == nil {
-		return err
-	}
-	return nil
+	return err
 }
 
 // Implement ScanRows method for Post slice
@@ -122,11 +153,19 @@ func ScanPosts(rows *sql.Rows) ([]Post, error) {
 			&post.Published,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.DeletedAt,
+			&post.Status,
+			&post.PublishAt,
+			&post.CommentCount,
+			&post.LikeCount,
 		)
-		if err == nil {
+		if err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
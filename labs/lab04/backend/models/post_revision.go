@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PostRevision is a saved version of a post's title and content.
+// Revisions are numbered from 1 for each post.
+type PostRevision struct {
+	ID        int       `json:"id" db:"id"`
+	PostID    int       `json:"post_id" db:"post_id"`
+	Revision  int       `json:"revision" db:"revision"`
+	Title     string    `json:"title" db:"title"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// DiffOp says whether a diff line is kept, added or removed
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffInsert DiffOp = "+"
+	DiffDelete DiffOp = "-"
+)
+
+// DiffLine is one line of a content diff
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff describes the changes from one revision of a post to another
+type RevisionDiff struct {
+	PostID    int        `json:"post_id"`
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	TitleFrom string     `json:"title_from"`
+	TitleTo   string     `json:"title_to"`
+	Lines     []DiffLine `json:"lines"`
+}
+
+// DiffRevisions compares the title and content of two revisions
+func DiffRevisions(from, to *PostRevision) *RevisionDiff {
+	return &RevisionDiff{
+		PostID:    to.PostID,
+		From:      from.Revision,
+		To:        to.Revision,
+		TitleFrom: from.Title,
+		TitleTo:   to.Title,
+		Lines:     DiffText(from.Content, to.Content),
+	}
+}
+
+// Changed reports whether the title or any content line differs
+func (d *RevisionDiff) Changed() bool {
+	if d.TitleFrom != d.TitleTo {
+		return true
+	}
+	for _, line := range d.Lines {
+		if line.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the diff like a unified diff without hunk headers
+func (d *RevisionDiff) String() string {
+	var b strings.Builder
+	if d.TitleFrom != d.TitleTo {
+		b.WriteString("-title: " + d.TitleFrom + "\n")
+		b.WriteString("+title: " + d.TitleTo + "\n")
+	}
+	for _, line := range d.Lines {
+		b.WriteString(string(line.Op) + line.Text + "\n")
+	}
+	return b.String()
+}
+
+// DiffText returns the line diff from a to b, using the longest common
+// subsequence of lines. Removed lines come before the lines replacing them.
+func DiffText(a, b string) []DiffLine {
+	from, to := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the common subsequence length of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: from[i]})
+			i++
+			j++
+		case j == len(to) || (i < len(from) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: from[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: to[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// splitLines splits text into lines; empty text has none
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffText(t *testing.T) {
+	got := DiffText("a\nb\nc\n", "a\nx\nc\nd")
+	want := []DiffLine{
+		{DiffEqual, "a"},
+		{DiffDelete, "b"},
+		{DiffInsert, "x"},
+		{DiffEqual, "c"},
+		{DiffInsert, "d"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if lines := DiffText("", ""); len(lines) != 0 {
+		t.Errorf("Expected no lines for empty texts, got %v", lines)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	from := &PostRevision{PostID: 1, Revision: 1, Title: "Draft", Content: "Hello"}
+	to := &PostRevision{PostID: 1, Revision: 2, Title: "Final", Content: "Hello\nWorld"}
+
+	diff := DiffRevisions(from, to)
+	if !diff.Changed() {
+		t.Error("Expected the revisions to differ")
+	}
+	want := "-title: Draft\n+title: Final\n Hello\n+World\n"
+	if got := diff.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if DiffRevisions(from, from).Changed() {
+		t.Error("Expected a revision to equal itself")
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PostStatus is the publishing workflow state of a post
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostInReview  PostStatus = "in_review"
+	PostScheduled PostStatus = "scheduled"
+	PostPublished PostStatus = "published"
+	PostArchived  PostStatus = "archived"
+)
+
+// ErrInvalidTransition is returned when a post cannot move from its
+// status to the requested one
+var ErrInvalidTransition = errors.New("invalid post status transition")
+
+// postTransitions lists the statuses each status may move to.
+// Review is optional: drafts may be scheduled or published directly.
+// A scheduled post may be scheduled again for another time.
+var postTransitions = map[PostStatus][]PostStatus{
+	PostDraft:     {PostInReview, PostScheduled, PostPublished, PostArchived},
+	PostInReview:  {PostDraft, PostScheduled, PostPublished},
+	PostScheduled: {PostDraft, PostScheduled, PostPublished},
+	PostPublished: {PostDraft, PostArchived},
+	PostArchived:  {PostDraft},
+}
+
+// PostStatuses returns every status in workflow order
+func PostStatuses() []PostStatus {
+	return []PostStatus{PostDraft, PostInReview, PostScheduled, PostPublished, PostArchived}
+}
+
+// Valid reports whether s is a known status
+func (s PostStatus) Valid() bool {
+	_, ok := postTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a post may move from s to next
+func (s PostStatus) CanTransition(next PostStatus) bool {
+	for _, allowed := range postTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionSources returns the statuses that may move to s
+func (s PostStatus) TransitionSources() []PostStatus {
+	var sources []PostStatus
+	for _, from := range PostStatuses() {
+		if from.CanTransition(s) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// PostTransitionRequest represents the payload for moving a post to another status
+type PostTransitionRequest struct {
+	Status PostStatus `json:"status"`
+	// PublishAt is required to schedule a post, and not allowed otherwise
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// Validate checks the status and that PublishAt is set for scheduling only.
+// Scheduled times must be in the future.
+func (req *PostTransitionRequest) Validate() error {
+	if !req.Status.Valid() {
+		return fmt.Errorf("unknown post status %q", req.Status)
+	}
+	if req.Status == PostScheduled {
+		if req.PublishAt == nil {
+			return errors.New("publish_at is required to schedule a post")
+		}
+		if !req.PublishAt.After(time.Now()) {
+			return errors.New("publish_at should be in the future")
+		}
+	} else if req.PublishAt != nil {
+		return errors.New("publish_at is only allowed when scheduling a post")
+	}
+	return nil
+}
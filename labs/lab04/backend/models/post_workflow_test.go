@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPostStatus_CanTransition(t *testing.T) {
+	tests := []struct {
+		from, to PostStatus
+		want     bool
+	}{
+		{PostDraft, PostInReview, true},
+		{PostDraft, PostPublished, true},
+		{PostInReview, PostScheduled, true},
+		{PostInReview, PostArchived, false},
+		{PostScheduled, PostScheduled, true},
+		{PostPublished, PostArchived, true},
+		{PostPublished, PostPublished, false},
+		{PostArchived, PostPublished, false},
+		{PostArchived, PostDraft, true},
+		{PostStatus("deleted"), PostDraft, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("Expected %s -> %s allowed = %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+
+	want := []PostStatus{PostDraft, PostInReview, PostScheduled}
+	if got := PostScheduled.TransitionSources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected sources %v, got %v", want, got)
+	}
+	if PostStatus("deleted").Valid() {
+		t.Error("Expected an unknown status to be invalid")
+	}
+}
+
+func TestPostTransitionRequest_Validate(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		req     PostTransitionRequest
+		wantErr bool
+	}{
+		{"publish", PostTransitionRequest{Status: PostPublished}, false},
+		{"schedule", PostTransitionRequest{Status: PostScheduled, PublishAt: &future}, false},
+		{"schedule without time", PostTransitionRequest{Status: PostScheduled}, true},
+		{"schedule in the past", PostTransitionRequest{Status: PostScheduled, PublishAt: &past}, true},
+		{"time without scheduling", PostTransitionRequest{Status: PostDraft, PublishAt: &future}, true},
+		{"unknown status", PostTransitionRequest{Status: "deleted"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("Expected a validation error, got %v", err)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"regexp"
 	"time"
+
+	"lab05/security"
 )
 
 // User represents a user in the system
@@ -20,6 +22,8 @@ type User struct {
 type CreateUserRequest struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Password is optional; the repository stores only its hash
+	Password string `json:"password,omitempty"`
 }
 
 // UpdateUserRequest represents the payload for updating a user
@@ -28,6 +32,12 @@ type UpdateUserRequest struct {
 	Email *string `json:"email,omitempty"`
 }
 
+// ChangePasswordRequest represents the payload for changing a password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
 // Implement Validate method for User
 func (u *User) Validate() error {
 	// Add validation logic
@@ -54,10 +64,30 @@ func (req *CreateUserRequest) Validate() error {
 	if !regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`).MatchString(req.Email) {
 		return errors.New("email should be valid format")
 	}
+	// - Password, when given, should meet the lab05 password rules
+	if req.Password != "" {
+		if err := security.ValidatePassword(req.Password); err != nil {
+			return err
+		}
+	}
 	// Return appropriate errors if validation fails
 	return nil
 }
 
+// Validate checks the new password against the lab05 password rules
+func (req *ChangePasswordRequest) Validate() error {
+	if req.OldPassword == "" {
+		return errors.New("old password is required")
+	}
+	if err := security.ValidatePassword(req.NewPassword); err != nil {
+		return err
+	}
+	if req.NewPassword == req.OldPassword {
+		return errors.New("new password should differ from the old one")
+	}
+	return nil
+}
+
 // Implement ToUser method for CreateUserRequest
 func (req *CreateUserRequest) ToUser() *User {
 	// Convert CreateUserRequest to User
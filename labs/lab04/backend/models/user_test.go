@@ -93,6 +93,24 @@ func TestCreateUserRequest_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid password",
+			req: CreateUserRequest{
+				Name:     "John Doe",
+				Email:    "john@example.com",
+				Password: "secret42",
+			},
+			wantErr: false,
+		},
+		{
+			name: "weak password",
+			req: CreateUserRequest{
+				Name:     "John Doe",
+				Email:    "john@example.com",
+				Password: "secret",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -105,6 +123,29 @@ func TestCreateUserRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestChangePasswordRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ChangePasswordRequest
+		wantErr bool
+	}{
+		{"valid request", ChangePasswordRequest{OldPassword: "secret42", NewPassword: "better43"}, false},
+		{"missing old password", ChangePasswordRequest{NewPassword: "better43"}, true},
+		{"short new password", ChangePasswordRequest{OldPassword: "secret42", NewPassword: "a1"}, true},
+		{"new password without number", ChangePasswordRequest{OldPassword: "secret42", NewPassword: "betterone"}, true},
+		{"same password", ChangePasswordRequest{OldPassword: "secret42", NewPassword: "secret42"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ChangePasswordRequest.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestCreateUserRequest_ToUser(t *testing.T) {
 	req := CreateUserRequest{
 		Name:  "John Doe",
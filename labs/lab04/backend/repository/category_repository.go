@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 
+	"lab04-backend/database"
 	"lab04-backend/models"
 
 	"gorm.io/gorm"
@@ -19,149 +21,211 @@ func NewCategoryRepository(gormDB *gorm.DB) *CategoryRepository {
 	return &CategoryRepository{db: gormDB}
 }
 
-// TODO: Implement Create method using GORM
+// gormDialect is the dialect of the engine GORM is connected to
+func gormDialect(db *gorm.DB) database.Dialect {
+	if db.Name() == "postgres" {
+		return database.Postgres
+	}
+	return database.SQLite
+}
+
+// Create inserts a category; GORM sets the ID and timestamps
 func (r *CategoryRepository) Create(category *models.Category) error {
-	// TODO: Create a new category using GORM
-	// - Use GORM's Create method: r.db.Create(category)
-	// - GORM automatically handles ID generation and timestamps
-	// - No need for manual SQL or RETURNING clauses
-	// Example: result := r.db.Create(category)
-	// return result.Error
-	//
-	// Notice how much simpler this is compared to manual SQL!
-	return fmt.Errorf("TODO: implement Create method with GORM")
-}
-
-// TODO: Implement GetByID method using GORM
+	return r.db.Create(category).Error
+}
+
+// GetByID returns the category or gorm.ErrRecordNotFound
 func (r *CategoryRepository) GetByID(id uint) (*models.Category, error) {
-	// TODO: Get category by ID using GORM
-	// - Use GORM's First method: r.db.First(&category, id)
-	// - GORM automatically generates the WHERE clause
-	// - Returns gorm.ErrRecordNotFound if not found
-	// Example:
-	// var category models.Category
-	// result := r.db.First(&category, id)
-	// return &category, result.Error
-	//
-	// Much cleaner than manual row scanning!
-	return nil, fmt.Errorf("TODO: implement GetByID method with GORM")
-}
-
-// TODO: Implement GetAll method using GORM
+	var category models.Category
+	if err := r.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// GetAll returns every category that is not deleted, ordered by name
 func (r *CategoryRepository) GetAll() ([]models.Category, error) {
-	// TODO: Get all categories using GORM
-	// - Use GORM's Find method: r.db.Find(&categories)
-	// - GORM automatically handles the slice allocation
-	// - Can add Order() for sorting: r.db.Order("name").Find(&categories)
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Order("name").Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement GetAll method with GORM")
-}
-
-// TODO: Implement Update method using GORM
+	var categories []models.Category
+	err := r.db.Order("name").Find(&categories).Error
+	return categories, err
+}
+
+// Update saves every field of category
 func (r *CategoryRepository) Update(category *models.Category) error {
-	// TODO: Update category using GORM
-	// - Use GORM's Save method: r.db.Save(category)
-	// - GORM automatically updates only changed fields
-	// - Handles updated_at timestamp automatically
-	// - Alternative: r.db.Model(category).Updates(updates)
-	//
-	// Example:
-	// result := r.db.Save(category)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Update method with GORM")
-}
-
-// TODO: Implement Delete method using GORM
+	return r.db.Save(category).Error
+}
+
+// Delete soft deletes a category.
+// It returns gorm.ErrRecordNotFound if there is no such category.
 func (r *CategoryRepository) Delete(id uint) error {
-	// TODO: Delete category using GORM
-	// - Use GORM's Delete method: r.db.Delete(&models.Category{}, id)
-	// - GORM can do soft delete if model has DeletedAt field
-	// - For hard delete: r.db.Unscoped().Delete(&models.Category{}, id)
-	//
-	// Example:
-	// result := r.db.Delete(&models.Category{}, id)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Delete method with GORM")
-}
-
-// TODO: Implement FindByName method using GORM
+	result := r.db.Delete(&models.Category{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindByName returns the category with exactly this name or gorm.ErrRecordNotFound
 func (r *CategoryRepository) FindByName(name string) (*models.Category, error) {
-	// TODO: Find category by name using GORM
-	// - Use GORM's Where method: r.db.Where("name = ?", name).First(&category)
-	// - GORM handles SQL injection protection automatically
-	// - Can use struct for conditions: r.db.Where(&models.Category{Name: name}).First(&category)
-	//
-	// Example:
-	// var category models.Category
-	// result := r.db.Where("name = ?", name).First(&category)
-	// return &category, result.Error
-	return nil, fmt.Errorf("TODO: implement FindByName method with GORM")
-}
-
-// TODO: Implement SearchCategories method using GORM
+	var category models.Category
+	if err := r.db.Where("name = ?", name).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// SearchCategories finds categories whose name contains query, ignoring ASCII case
 func (r *CategoryRepository) SearchCategories(query string, limit int) ([]models.Category, error) {
-	// TODO: Search categories using GORM
-	// - Use GORM's Where with LIKE: r.db.Where("name LIKE ?", "%"+query+"%")
-	// - Add Limit: .Limit(limit)
-	// - Add Order: .Order("name")
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Where("name LIKE ?", "%"+query+"%").
-	//               Order("name").
-	//               Limit(limit).
-	//               Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement SearchCategories method with GORM")
-}
-
-// TODO: Implement GetCategoriesWithPosts method using GORM associations
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	var categories []models.Category
+	err := r.db.Where("name "+gormDialect(r.db).Like()+` ? ESCAPE '\'`, likePattern(query)).
+		Order("name").
+		Limit(limit).
+		Find(&categories).Error
+	return categories, err
+}
+
+// GetCategoriesWithPosts returns every category with its posts preloaded.
+// Deleted posts are left out.
 func (r *CategoryRepository) GetCategoriesWithPosts() ([]models.Category, error) {
-	// TODO: Get categories with associated posts using GORM
-	// - Use GORM's Preload to load associations: r.db.Preload("Posts").Find(&categories)
-	// - GORM automatically handles the JOINs and relationships
-	// - Much simpler than manual JOIN queries!
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Preload("Posts").Find(&categories)
-	// return categories, result.Error
-	//
-	// This assumes Category model has Posts relationship defined
-	return nil, fmt.Errorf("TODO: implement GetCategoriesWithPosts method with GORM Preload")
-}
-
-// TODO: Implement Count method using GORM
+	var categories []models.Category
+	err := r.db.Preload("Posts", "posts.deleted_at IS NULL").Order("name").Find(&categories).Error
+	return categories, err
+}
+
+// Count counts the categories that are not deleted
 func (r *CategoryRepository) Count() (int64, error) {
-	// TODO: Count categories using GORM
-	// - Use GORM's Count method: r.db.Model(&models.Category{}).Count(&count)
-	// - GORM returns int64 for count operations
-	//
-	// Example:
-	// var count int64
-	// result := r.db.Model(&models.Category{}).Count(&count)
-	// return count, result.Error
-	return 0, fmt.Errorf("TODO: implement Count method with GORM")
-}
-
-// TODO: Implement Transaction example using GORM
+	var count int64
+	err := r.db.Model(&models.Category{}).Count(&count).Error
+	return count, err
+}
+
+// CreateWithTransaction creates all categories or none.
+// The categories in the slice get their IDs and timestamps.
 func (r *CategoryRepository) CreateWithTransaction(categories []models.Category) error {
-	// TODO: Create multiple categories in a transaction using GORM
-	// - Use GORM's Transaction method: r.db.Transaction(func(tx *gorm.DB) error {...})
-	// - GORM automatically handles rollback on error
-	// - Much simpler than manual transaction management!
-	//
-	// Example:
-	// return r.db.Transaction(func(tx *gorm.DB) error {
-	//     for _, category := range categories {
-	//         if err := tx.Create(&category).Error; err != nil {
-	//             return err // GORM will rollback automatically
-	//         }
-	//     }
-	//     return nil // GORM will commit automatically
-	// })
-	return fmt.Errorf("TODO: implement CreateWithTransaction method with GORM")
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range categories {
+			if err := tx.Create(&categories[i]).Error; err != nil {
+				return err // GORM will rollback automatically
+			}
+		}
+		return nil
+	})
+}
+
+// AttachPosts adds posts to a category. Posts already in it are skipped.
+func (r *CategoryRepository) AttachPosts(ctx context.Context, categoryID uint, postIDs ...int) error {
+	return r.changePosts(ctx, categoryID, postIDs, func(a *gorm.Association, posts []models.Post) error {
+		return a.Append(posts)
+	})
+}
+
+// DetachPosts removes posts from a category; the posts themselves are kept
+func (r *CategoryRepository) DetachPosts(ctx context.Context, categoryID uint, postIDs ...int) error {
+	return r.changePosts(ctx, categoryID, postIDs, func(a *gorm.Association, posts []models.Post) error {
+		if len(posts) == 0 {
+			return nil
+		}
+		return a.Delete(posts)
+	})
+}
+
+// ReplacePosts makes postIDs the only posts of a category
+func (r *CategoryRepository) ReplacePosts(ctx context.Context, categoryID uint, postIDs ...int) error {
+	return r.changePosts(ctx, categoryID, postIDs, func(a *gorm.Association, posts []models.Post) error {
+		if len(posts) == 0 {
+			return a.Clear()
+		}
+		return a.Replace(posts)
+	})
+}
+
+// changePosts loads the category and posts in a transaction and applies
+// change to the category's post association. It returns gorm.ErrRecordNotFound
+// if the category or any post does not exist or is deleted.
+func (r *CategoryRepository) changePosts(ctx context.Context, categoryID uint, postIDs []int, change func(*gorm.Association, []models.Post) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, categoryID).Error; err != nil {
+			return err
+		}
+
+		posts := []models.Post{}
+		if len(postIDs) > 0 {
+			if err := tx.Where("id IN ? AND deleted_at IS NULL", postIDs).Find(&posts).Error; err != nil {
+				return err
+			}
+			if len(posts) != countDistinct(postIDs) {
+				return fmt.Errorf("post not found: %w", gorm.ErrRecordNotFound)
+			}
+		}
+
+		// Only the junction rows change, never the posts
+		association := tx.Model(&category).Omit("Posts.*").Association("Posts")
+		if association.Error != nil {
+			return association.Error
+		}
+		return change(association, posts)
+	})
+}
+
+// ListPosts returns a page of the category's posts that are not deleted, newest first.
+// It returns gorm.ErrRecordNotFound if the category does not exist.
+func (r *CategoryRepository) ListPosts(ctx context.Context, categoryID uint, req PageRequest) (Page[models.Post], error) {
+	k, err := parsePageRequest(req)
+	if err != nil {
+		return Page[models.Post]{}, err
+	}
+	db := r.db.WithContext(ctx)
+	if err := db.Select("id").First(&models.Category{}, categoryID).Error; err != nil {
+		return Page[models.Post]{}, err
+	}
+
+	query := db.Model(&models.Post{}).
+		Joins("JOIN post_categories pc ON pc.post_id = posts.id AND pc.category_id = ?", categoryID).
+		Where("posts.deleted_at IS NULL").
+		Order(k.order("posts.")).
+		Limit(k.fetch())
+	if k.cursor != nil {
+		query = query.Where("(posts.created_at, posts.id) "+k.op()+" (?, ?)", k.args()...)
+	}
+	var posts []models.Post
+	if err := query.Find(&posts).Error; err != nil {
+		return Page[models.Post]{}, fmt.Errorf("failed to list category posts: %w", err)
+	}
+	return buildPage(k, posts, postCursor), nil
+}
+
+// countDistinct counts the different values in ids
+func countDistinct(ids []int) int {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+// List returns a page of categories that are not deleted, newest first
+func (r *CategoryRepository) List(ctx context.Context, req PageRequest) (Page[models.Category], error) {
+	k, err := parsePageRequest(req)
+	if err != nil {
+		return Page[models.Category]{}, err
+	}
+
+	query := r.db.WithContext(ctx).Order(k.order("")).Limit(k.fetch())
+	if k.cursor != nil {
+		query = query.Where("(created_at, id) "+k.op()+" (?, ?)", k.args()...)
+	}
+	var categories []models.Category
+	if err := query.Find(&categories).Error; err != nil {
+		return Page[models.Category]{}, fmt.Errorf("failed to list categories: %w", err)
+	}
+	return buildPage(k, categories, func(c models.Category) Cursor {
+		return Cursor{CreatedAt: c.CreatedAt, ID: int(c.ID)}
+	}), nil
 }
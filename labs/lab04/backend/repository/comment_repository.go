@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	"lab04-backend/models"
+)
+
+// ErrInvalidParent is returned when a reply's parent is not a comment on
+// the same post, or is deleted
+var ErrInvalidParent = errors.New("parent comment not found on this post")
+
+// CommentRepository handles database operations for threaded comments.
+// Comments are soft deleted like posts; posts.comment_count is kept up to
+// date by database triggers, so the repository never writes it.
+type CommentRepository struct {
+	db DBTX
+}
+
+// commentColumns lists the columns scanned into models.Comment
+const commentColumns = `id, post_id, user_id, parent_id, content, created_at, updated_at, deleted_at`
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db DBTX) *CommentRepository {
+	return &CommentRepository{db: db}
+}
+
+// Create adds a comment, or a reply when req.ParentID is set.
+// It returns sql.ErrNoRows if the post does not exist or is deleted, and
+// ErrInvalidParent if the parent cannot be replied to.
+func (r *CommentRepository) Create(ctx context.Context, req *models.CreateCommentRequest) (*models.Comment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// The post and parent are checked by the insert itself, so one deleted
+	// meanwhile gets no comment. Parameters appear in order, as SQLite binds
+	// numbered parameters by position.
+	query := `
+		INSERT INTO comments (post_id, user_id, parent_id, content, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $5 FROM posts p
+		WHERE p.id = $1 AND p.deleted_at IS NULL`
+	if req.ParentID != nil {
+		query += `
+		AND EXISTS (SELECT 1 FROM comments c WHERE c.id = $3 AND c.post_id = $1 AND c.deleted_at IS NULL)`
+	}
+	query += `
+		RETURNING ` + commentColumns
+
+	comment := &models.Comment{}
+	err := sqlscan.Get(ctx, r.db, comment, query, req.PostID, req.UserID, req.ParentID, req.Content, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.missingTarget(ctx, req.PostID, req.ParentID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return comment, nil
+}
+
+// missingTarget explains why Create inserted nothing: the post cannot be
+// commented on, or parentID is not a comment on it
+func (r *CommentRepository) missingTarget(ctx context.Context, postID int, parentID *int) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`, postID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check post: %w", err)
+	}
+	if !exists || parentID == nil {
+		return fmt.Errorf("post not found: %w", sql.ErrNoRows)
+	}
+	return ErrInvalidParent
+}
+
+// GetByID returns a comment that is not deleted, or sql.ErrNoRows
+func (r *CommentRepository) GetByID(ctx context.Context, id int) (*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE id = $1 AND deleted_at IS NULL`
+
+	comment := &models.Comment{}
+	if err := sqlscan.Get(ctx, r.db, comment, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return comment, nil
+}
+
+// GetByPost returns the comments on a post that are not deleted, replies
+// included, oldest first
+func (r *CommentRepository) GetByPost(ctx context.Context, postID int) ([]models.Comment, error) {
+	return r.selectComments(ctx, `WHERE post_id = $1 AND deleted_at IS NULL`, postID)
+}
+
+// GetByUser returns the comments a user wrote that are not deleted, oldest first
+func (r *CommentRepository) GetByUser(ctx context.Context, userID int) ([]models.Comment, error) {
+	return r.selectComments(ctx, `WHERE user_id = $1 AND deleted_at IS NULL`, userID)
+}
+
+// GetThread returns the top level comments on a post, oldest first, with
+// their replies nested in Replies in the same order
+func (r *CommentRepository) GetThread(ctx context.Context, postID int) ([]*models.Comment, error) {
+	comments, err := r.GetByPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*models.Comment, len(comments))
+	for i := range comments {
+		byID[comments[i].ID] = &comments[i]
+	}
+	roots := []*models.Comment{}
+	for i := range comments {
+		comment := &comments[i]
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+			continue
+		}
+		// Delete removes the replies with their parent, so a live reply
+		// always has a live parent
+		if parent, ok := byID[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, comment)
+		}
+	}
+	return roots, nil
+}
+
+// selectComments returns the comments matching where, oldest first
+func (r *CommentRepository) selectComments(ctx context.Context, where string, args ...interface{}) ([]models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments ` + where + ` ORDER BY created_at, id`
+
+	comments := []models.Comment{}
+	if err := sqlscan.Select(ctx, r.db, &comments, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	return comments, nil
+}
+
+// Update changes the content of a comment that is not deleted.
+// It returns sql.ErrNoRows if there is no such comment.
+func (r *CommentRepository) Update(ctx context.Context, id int, req *models.UpdateCommentRequest) (*models.Comment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	query := `
+		UPDATE comments SET content = $1, updated_at = $2
+		WHERE id = $3 AND deleted_at IS NULL
+		RETURNING ` + commentColumns
+
+	comment := &models.Comment{}
+	if err := sqlscan.Get(ctx, r.db, comment, query, req.Content, time.Now(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+	return comment, nil
+}
+
+// Delete soft deletes a comment and every reply below it.
+// It returns sql.ErrNoRows if the comment does not exist or is deleted.
+func (r *CommentRepository) Delete(ctx context.Context, id int) error {
+	query := `
+		WITH RECURSIVE thread (id) AS (
+			SELECT id FROM comments WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id
+			WHERE c.deleted_at IS NULL
+		)
+		UPDATE comments SET deleted_at = $2
+		WHERE id IN (SELECT id FROM thread)
+	`
+	result, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	return requireRowsAffected(result)
+}
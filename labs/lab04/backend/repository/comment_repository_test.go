@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"lab04-backend/fixtures"
+	"lab04-backend/fixtures/fixturetest"
+	"lab04-backend/models"
+)
+
+func setupSocialTestDB(t *testing.T) (*sql.DB, *fixtures.Refs, func()) {
+	db, cleanup := openTestDB(t, "./test_social.db")
+	return db, fixturetest.Load(t, db, "testdata/social.yaml"), cleanup
+}
+
+// commentCount returns posts.comment_count of a post
+func commentCount(t *testing.T, posts *PostRepository, postID int) int {
+	t.Helper()
+	post, err := posts.GetByID(context.Background(), postID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	return post.CommentCount
+}
+
+func TestCommentRepository(t *testing.T) {
+	db, refs, cleanup := setupSocialTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	comments, posts := NewCommentRepository(db), NewPostRepository(db)
+	alice, bob, hello, other := refs.Users["alice"], refs.Users["bob"], refs.Posts["hello"], refs.Posts["other"]
+
+	create := func(userID int, parentID *int, content string) *models.Comment {
+		t.Helper()
+		comment, err := comments.Create(ctx, &models.CreateCommentRequest{PostID: hello, UserID: userID, ParentID: parentID, Content: content})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		return comment
+	}
+	first := create(bob, nil, "First!")
+	reply := create(alice, &first.ID, "Thanks")
+	nested := create(bob, &reply.ID, "You're welcome")
+	second := create(alice, nil, "Another thought")
+
+	if first.ID == 0 || first.ParentID != nil || reply.ParentID == nil || *reply.ParentID != first.ID {
+		t.Errorf("Expected a comment and its reply, got %+v and %+v", first, reply)
+	}
+	if got := commentCount(t, posts, hello); got != 4 {
+		t.Errorf("Expected 4 comments counted on the post, got %d", got)
+	}
+
+	t.Run("invalid targets", func(t *testing.T) {
+		_, err := comments.Create(ctx, &models.CreateCommentRequest{PostID: other, UserID: bob, ParentID: &first.ID, Content: "Wrong post"})
+		if !errors.Is(err, ErrInvalidParent) {
+			t.Errorf("Expected ErrInvalidParent for a parent on another post, got %v", err)
+		}
+		_, err = comments.Create(ctx, &models.CreateCommentRequest{PostID: 9999, UserID: bob, Content: "No post"})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows for a missing post, got %v", err)
+		}
+		if _, err := comments.Create(ctx, &models.CreateCommentRequest{PostID: hello, UserID: bob, Content: "   "}); err == nil {
+			t.Error("Expected an error for a blank comment")
+		}
+	})
+
+	t.Run("GetThread", func(t *testing.T) {
+		thread, err := comments.GetThread(ctx, hello)
+		if err != nil {
+			t.Fatalf("GetThread() failed: %v", err)
+		}
+		if len(thread) != 2 || thread[0].ID != first.ID || thread[1].ID != second.ID {
+			t.Fatalf("Expected the two top level comments, got %+v", thread)
+		}
+		if len(thread[0].Replies) != 1 || thread[0].Replies[0].ID != reply.ID {
+			t.Fatalf("Expected the reply under the first comment, got %+v", thread[0].Replies)
+		}
+		if replies := thread[0].Replies[0].Replies; len(replies) != 1 || replies[0].ID != nested.ID {
+			t.Errorf("Expected the nested reply under the reply, got %+v", replies)
+		}
+		if byUser, err := comments.GetByUser(ctx, alice); err != nil || len(byUser) != 2 {
+			t.Errorf("Expected alice's 2 comments, got %+v %v", byUser, err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updated, err := comments.Update(ctx, second.ID, &models.UpdateCommentRequest{Content: "Edited thought"})
+		if err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+		if updated.Content != "Edited thought" || !updated.UpdatedAt.After(second.UpdatedAt) {
+			t.Errorf("Expected the edited comment, got %+v", updated)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := comments.Delete(ctx, reply.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := comments.GetByID(ctx, nested.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected the nested reply deleted with its parent, got %v", err)
+		}
+		if got := commentCount(t, posts, hello); got != 2 {
+			t.Errorf("Expected 2 comments counted after the delete, got %d", got)
+		}
+		if err := comments.Delete(ctx, reply.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows deleting twice, got %v", err)
+		}
+		if _, err := comments.Update(ctx, reply.ID, &models.UpdateCommentRequest{Content: "Too late"}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows updating a deleted comment, got %v", err)
+		}
+		if _, err := comments.Create(ctx, &models.CreateCommentRequest{PostID: hello, UserID: bob, ParentID: &reply.ID, Content: "Late reply"}); !errors.Is(err, ErrInvalidParent) {
+			t.Errorf("Expected ErrInvalidParent replying to a deleted comment, got %v", err)
+		}
+	})
+
+	t.Run("HardDelete of the post", func(t *testing.T) {
+		if err := posts.HardDelete(ctx, hello); err != nil {
+			t.Fatalf("HardDelete() failed: %v", err)
+		}
+		var left int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM comments WHERE post_id = $1`, hello).Scan(&left); err != nil || left != 0 {
+			t.Errorf("Expected the comments removed with the post, got %d %v", left, err)
+		}
+	})
+}
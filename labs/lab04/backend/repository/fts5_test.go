@@ -0,0 +1,26 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"testing"
+
+	"lab04-backend/database"
+)
+
+// TestSearchServiceUsesFTS5 fails when the sqlite_fts5 tag is set but
+// search still falls back to LIKE, so the FTS5 path cannot go untested
+func TestSearchServiceUsesFTS5(t *testing.T) {
+	searchService, _, _, cleanup := setupSearchService(t)
+	defer cleanup()
+	if database.DialectOf(searchService.db) != database.SQLite {
+		t.Skip("FTS5 is SQLite only")
+	}
+
+	if !database.HasFTS5(searchService.db) {
+		t.Fatal("Expected SQLite built with FTS5 under the sqlite_fts5 tag")
+	}
+	if !searchService.fts {
+		t.Error("Expected the search service to use the posts_fts index")
+	}
+}
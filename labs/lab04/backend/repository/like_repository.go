@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	"lab04-backend/models"
+)
+
+// LikeRepository handles database operations for post likes.
+// posts.like_count is kept up to date by database triggers.
+type LikeRepository struct {
+	db DBTX
+}
+
+// NewLikeRepository creates a new LikeRepository
+func NewLikeRepository(db DBTX) *LikeRepository {
+	return &LikeRepository{db: db}
+}
+
+// Like records that a user likes a post and reports whether it is new;
+// liking a post twice changes nothing. It returns sql.ErrNoRows if the post
+// does not exist or is deleted.
+func (r *LikeRepository) Like(ctx context.Context, postID, userID int) (bool, error) {
+	// The post is checked by the insert itself, so one deleted meanwhile
+	// gets no like. Parameters appear in order, as SQLite binds numbered
+	// parameters by position.
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO post_likes (post_id, user_id, created_at)
+		SELECT $1, $2, $3 FROM posts p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+		ON CONFLICT (post_id, user_id) DO NOTHING
+	`, postID, userID, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to like post: %w", err)
+	}
+	liked, err := rowsChanged(result)
+	if err != nil || liked {
+		return liked, err
+	}
+
+	// Nothing inserted: either the like exists or the post is gone
+	var exists bool
+	err = r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)`, postID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check post: %w", err)
+	}
+	if !exists {
+		return false, fmt.Errorf("post not found: %w", sql.ErrNoRows)
+	}
+	return false, nil
+}
+
+// Unlike removes a user's like from a post and reports whether there was one
+func (r *LikeRepository) Unlike(ctx context.Context, postID, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM post_likes WHERE post_id = $1 AND user_id = $2`, postID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlike post: %w", err)
+	}
+	return rowsChanged(result)
+}
+
+// HasLiked reports whether a user likes a post
+func (r *LikeRepository) HasLiked(ctx context.Context, postID, userID int) (bool, error) {
+	var liked bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM post_likes WHERE post_id = $1 AND user_id = $2)`, postID, userID).Scan(&liked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check like: %w", err)
+	}
+	return liked, nil
+}
+
+// GetByPost returns the likes of a post, newest first
+func (r *LikeRepository) GetByPost(ctx context.Context, postID int) ([]models.PostLike, error) {
+	query := `SELECT post_id, user_id, created_at FROM post_likes WHERE post_id = $1 ORDER BY created_at DESC, user_id`
+
+	likes := []models.PostLike{}
+	if err := sqlscan.Select(ctx, r.db, &likes, query, postID); err != nil {
+		return nil, fmt.Errorf("failed to get likes: %w", err)
+	}
+	return likes, nil
+}
+
+// rowsChanged reports whether result changed any row
+func rowsChanged(result sql.Result) (bool, error) {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestLikeRepository(t *testing.T) {
+	db, refs, cleanup := setupSocialTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	likes, posts := NewLikeRepository(db), NewPostRepository(db)
+	alice, bob, hello := refs.Users["alice"], refs.Users["bob"], refs.Posts["hello"]
+
+	likeCount := func() int {
+		t.Helper()
+		post, err := posts.GetByID(ctx, hello)
+		if err != nil {
+			t.Fatalf("GetByID() failed: %v", err)
+		}
+		return post.LikeCount
+	}
+
+	for _, userID := range []int{alice, bob} {
+		if liked, err := likes.Like(ctx, hello, userID); err != nil || !liked {
+			t.Fatalf("Expected a new like from user %d, got %v %v", userID, liked, err)
+		}
+	}
+	if liked, err := likes.Like(ctx, hello, bob); err != nil || liked {
+		t.Errorf("Expected a second like to change nothing, got %v %v", liked, err)
+	}
+	if got := likeCount(); got != 2 {
+		t.Errorf("Expected 2 likes counted on the post, got %d", got)
+	}
+	if all, err := likes.GetByPost(ctx, hello); err != nil || len(all) != 2 {
+		t.Errorf("Expected 2 likes, got %+v %v", all, err)
+	}
+	if has, err := likes.HasLiked(ctx, hello, bob); err != nil || !has {
+		t.Errorf("Expected bob to like the post, got %v %v", has, err)
+	}
+
+	if removed, err := likes.Unlike(ctx, hello, bob); err != nil || !removed {
+		t.Errorf("Expected bob's like removed, got %v %v", removed, err)
+	}
+	if removed, err := likes.Unlike(ctx, hello, bob); err != nil || removed {
+		t.Errorf("Expected nothing to remove the second time, got %v %v", removed, err)
+	}
+	if has, err := likes.HasLiked(ctx, hello, bob); err != nil || has {
+		t.Errorf("Expected bob not to like the post, got %v %v", has, err)
+	}
+	if got := likeCount(); got != 1 {
+		t.Errorf("Expected 1 like counted on the post, got %d", got)
+	}
+
+	if err := posts.Delete(ctx, hello); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := likes.Like(ctx, hello, bob); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows liking a deleted post, got %v", err)
+	}
+}
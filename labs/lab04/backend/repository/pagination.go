@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Keyset pagination lists rows newest first by (created_at, id) and
+// continues after the last row seen instead of skipping an offset, so a
+// page costs the same however deep it is.
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ErrInvalidCursor is returned for cursors that were not made by this package
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageRequest selects a page of a list. Set at most one of After and Before.
+type PageRequest struct {
+	Limit  int    // Page size, default 20, at most 100
+	After  string // NextCursor of a page, to get the page after it
+	Before string // PrevCursor of a page, to get the page before it
+}
+
+// Page is one page of a list, newest first.
+// A cursor is empty when there is nothing more in that direction.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Cursor is the position of a row in a keyset paginated list
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+type cursorJSON struct {
+	CreatedAt string `json:"t"`
+	ID        int    `json:"id"`
+}
+
+// Encode returns the opaque form of c that is handed to clients
+func (c Cursor) Encode() string {
+	// RFC 3339 keeps the UTC offset, so the time binds back to the exact
+	// text stored in the row
+	data, _ := json.Marshal(cursorJSON{CreatedAt: c.CreatedAt.Format(time.RFC3339Nano), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor made by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var raw cursorJSON
+	if err := json.Unmarshal(data, &raw); err != nil || raw.ID <= 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, raw.CreatedAt)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: createdAt, ID: raw.ID}, nil
+}
+
+// keyset is a parsed PageRequest
+type keyset struct {
+	cursor   *Cursor
+	backward bool // Fetching the page before cursor
+	limit    int
+}
+
+func parsePageRequest(req PageRequest) (keyset, error) {
+	k := keyset{limit: req.Limit}
+	if k.limit <= 0 {
+		k.limit = defaultPageSize
+	}
+	if k.limit > maxPageSize {
+		k.limit = maxPageSize
+	}
+	if req.After != "" && req.Before != "" {
+		return keyset{}, errors.New("set only one of after and before")
+	}
+	encoded := req.After
+	if req.Before != "" {
+		encoded, k.backward = req.Before, true
+	}
+	if encoded != "" {
+		cursor, err := DecodeCursor(encoded)
+		if err != nil {
+			return keyset{}, err
+		}
+		k.cursor = &cursor
+	}
+	return k, nil
+}
+
+// op compares (created_at, id) of a row with the cursor
+func (k keyset) op() string {
+	if k.backward {
+		return ">"
+	}
+	return "<"
+}
+
+// args are the cursor values compared by op
+func (k keyset) args() []interface{} {
+	return []interface{}{k.cursor.CreatedAt, k.cursor.ID}
+}
+
+// order sorts rows away from the cursor; prefix qualifies the columns
+func (k keyset) order(prefix string) string {
+	if k.backward {
+		return prefix + "created_at ASC, " + prefix + "id ASC"
+	}
+	return prefix + "created_at DESC, " + prefix + "id DESC"
+}
+
+// fetch is how many rows to query: one more than the page shows whether
+// there is another page
+func (k keyset) fetch() int {
+	return k.limit + 1
+}
+
+// buildPage turns the rows fetched for k into a page
+func buildPage[T any](k keyset, rows []T, key func(T) Cursor) Page[T] {
+	more := len(rows) > k.limit
+	if more {
+		rows = rows[:k.limit]
+	}
+	if k.backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(rows) == 0 {
+		return page
+	}
+	// Going forward there is a previous page if we came from a cursor, and
+	// going backward there is a next page for the same reason
+	hasNext, hasPrev := more, k.cursor != nil
+	if k.backward {
+		hasNext, hasPrev = k.cursor != nil, more
+	}
+	if hasNext {
+		page.NextCursor = key(rows[len(rows)-1]).Encode()
+	}
+	if hasPrev {
+		page.PrevCursor = key(rows[0]).Encode()
+	}
+	return page
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"lab04-backend/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2025, 7, 12, 10, 30, 0, 123456789, time.FixedZone("", 3*3600))
+	cursor, err := DecodeCursor(Cursor{CreatedAt: at, ID: 42}.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() failed: %v", err)
+	}
+	if cursor.ID != 42 || !cursor.CreatedAt.Equal(at) {
+		t.Errorf("Expected %v/42, got %v/%d", at, cursor.CreatedAt, cursor.ID)
+	}
+	if _, offset := cursor.CreatedAt.Zone(); offset != 3*3600 {
+		t.Errorf("Expected UTC offset to be kept, got %d", offset)
+	}
+
+	for _, bad := range []string{"", "not base64!", "bm90IGpzb24", Cursor{CreatedAt: at}.Encode()} {
+		if _, err := DecodeCursor(bad); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", bad, err)
+		}
+	}
+}
+
+// walkPages follows next cursors from the first page, then prev cursors
+// back, and returns the IDs seen in each direction
+func walkPages[T any](t *testing.T, list func(PageRequest) (Page[T], error), limit int, id func(T) int) (forward, backward []int) {
+	t.Helper()
+	page, err := list(PageRequest{Limit: limit})
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if page.PrevCursor != "" {
+		t.Errorf("Expected no previous cursor on the first page, got %q", page.PrevCursor)
+	}
+	var pages []Page[T]
+	for {
+		if len(page.Items) > limit {
+			t.Fatalf("Expected at most %d items, got %d", limit, len(page.Items))
+		}
+		pages = append(pages, page)
+		for _, item := range page.Items {
+			forward = append(forward, id(item))
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if page, err = list(PageRequest{Limit: limit, After: page.NextCursor}); err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+	}
+
+	for page.PrevCursor != "" {
+		if page, err = list(PageRequest{Limit: limit, Before: page.PrevCursor}); err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		ids := make([]int, 0, len(page.Items))
+		for _, item := range page.Items {
+			ids = append(ids, id(item))
+		}
+		backward = append(ids, backward...)
+	}
+	// The first page reached backwards starts the list again
+	if backward != nil && len(page.Items) > 0 && id(page.Items[0]) != forward[0] {
+		t.Errorf("Expected to get back to the first item %d, got %d", forward[0], id(page.Items[0]))
+	}
+	return forward, backward
+}
+
+func TestUserRepository_List(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var want []int
+	for i := 0; i < 7; i++ {
+		user, err := repo.Create(ctx, &models.CreateUserRequest{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i)})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		want = append([]int{user.ID}, want...)
+	}
+
+	forward, backward := walkPages(t, func(req PageRequest) (Page[models.User], error) { return repo.List(ctx, req) }, 3,
+		func(u models.User) int { return u.ID })
+	if !reflect.DeepEqual(forward, want) {
+		t.Errorf("Expected %v newest first, got %v", want, forward)
+	}
+	// Walking back from the last page covers all but the last page again
+	if !reflect.DeepEqual(backward, want[:6]) {
+		t.Errorf("Expected %v walking back, got %v", want[:6], backward)
+	}
+
+	if _, err := repo.List(ctx, PageRequest{After: "garbage"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	page, _ := repo.List(ctx, PageRequest{Limit: 1000})
+	if len(page.Items) != 7 || page.NextCursor != "" {
+		t.Errorf("Expected a single page of 7, got %d items and cursor %q", len(page.Items), page.NextCursor)
+	}
+}
+
+func TestPostRepository_List(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var want []int
+	for i := 0; i < 5; i++ {
+		post := createTestPost(t, repo, userID, fmt.Sprintf("Post number %d", i), i%2 == 0)
+		if i == 2 {
+			repo.Delete(ctx, post.ID)
+			continue
+		}
+		want = append([]int{post.ID}, want...)
+	}
+
+	forward, _ := walkPages(t, func(req PageRequest) (Page[models.Post], error) { return repo.List(ctx, req) }, 2,
+		func(p models.Post) int { return p.ID })
+	if !reflect.DeepEqual(forward, want) {
+		t.Errorf("Expected %v without the deleted post, got %v", want, forward)
+	}
+}
+
+func TestSearchService_SearchPostsPage(t *testing.T) {
+	searchService, posts, refs, cleanup := setupSearchService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var want []int
+	for i := 0; i < 5; i++ {
+		post, err := posts.Create(ctx, &models.CreatePostRequest{UserID: refs.Users["carol"], Title: fmt.Sprintf("Paged post %d", i), Content: "paged content", Published: i != 1})
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if i != 1 {
+			want = append([]int{post.ID}, want...)
+		}
+	}
+
+	published := true
+	filters := SearchFilters{Query: "paged", Published: &published}
+	forward, _ := walkPages(t, func(req PageRequest) (Page[PostSearchResult], error) {
+		return searchService.SearchPostsPage(ctx, filters, req)
+	}, 3, func(r PostSearchResult) int { return r.ID })
+	if !reflect.DeepEqual(forward, want) {
+		t.Errorf("Expected %v, got %v", want, forward)
+	}
+
+	if _, err := searchService.SearchPostsPage(ctx, SearchFilters{Offset: 10}, PageRequest{}); err == nil {
+		t.Error("Expected error for offset with keyset pages")
+	}
+}
+
+func TestCategoryRepository_List(t *testing.T) {
+	repo, gormDB, _, _, cleanup := setupCategoryTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var want []int
+	for i := 0; i < 5; i++ {
+		category := models.Category{Name: fmt.Sprintf("Category %d", i), Active: true}
+		if err := gormDB.Create(&category).Error; err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+		if i == 3 {
+			gormDB.Delete(&category)
+			continue
+		}
+		want = append([]int{int(category.ID)}, want...)
+	}
+
+	forward, backward := walkPages(t, func(req PageRequest) (Page[models.Category], error) { return repo.List(ctx, req) }, 2,
+		func(c models.Category) int { return int(c.ID) })
+	if !reflect.DeepEqual(forward, want) {
+		t.Errorf("Expected %v without the deleted category, got %v", want, forward)
+	}
+	if !reflect.DeepEqual(backward, want[:2]) {
+		t.Errorf("Expected %v walking back, got %v", want[:2], backward)
+	}
+}
@@ -1,101 +1,285 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
 
 	"lab04-backend/models"
 )
 
 // PostRepository handles database operations for posts
 // This repository demonstrates SCANY MAPPING approach for result scanning
+//
+// Delete is a soft delete: it sets deleted_at, and every read and count
+// skips posts where it is set. Restore undoes it, HardDelete removes the row.
 type PostRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
+// postColumns lists the columns scanned into models.Post.
+// content may be NULL in the table but is a plain string on the model.
+const postColumns = `id, user_id, title, COALESCE(content, '') AS content, published, created_at, updated_at, deleted_at, status, publish_at, comment_count, like_count`
+
 // NewPostRepository creates a new PostRepository
-func NewPostRepository(db *sql.DB) *PostRepository {
+func NewPostRepository(db DBTX) *PostRepository {
 	return &PostRepository{db: db}
 }
 
-// TODO: Implement Create method using scany for result mapping
-func (r *PostRepository) Create(req *models.CreatePostRequest) (*models.Post, error) {
-	// TODO: Create a new post in the database using scany for result mapping
+// Implement Create method using scany for result mapping
+func (r *PostRepository) Create(ctx context.Context, req *models.CreatePostRequest) (*models.Post, error) {
+	// Create a new post in the database using scany for result mapping
 	// - Validate the request using req.Validate()
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
 	// - Insert into posts table with RETURNING clause
+	post := req.ToPost()
+	query := `
+		INSERT INTO posts (user_id, title, content, published, status, publish_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + postColumns
+
 	// - Use sqlscan.Get() to scan the RETURNING result into a Post struct
-	// Example: sqlscan.Get(context.Background(), r.db, &post, query, args...)
-	// This eliminates manual row scanning compared to user repository
-	return nil, fmt.Errorf("TODO: implement Create method with scany mapping")
+	created := &models.Post{}
+	err := sqlscan.Get(ctx, r.db, created, query,
+		post.UserID, post.Title, post.Content, post.Published, post.Status, utcTime(post.PublishAt), post.CreatedAt, post.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", err)
+	}
+	return created, nil
+}
+
+// Implement GetByID method using scany
+func (r *PostRepository) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	// Get post by ID from database using scany
+	// - Soft deleted posts are not found
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = $1 AND deleted_at IS NULL`
+
+	post := &models.Post{}
+	if err := sqlscan.Get(ctx, r.db, post, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	return post, nil
 }
 
-// TODO: Implement GetByID method using scany
-func (r *PostRepository) GetByID(id int) (*models.Post, error) {
-	// TODO: Get post by ID from database using scany
-	// - Use sqlscan.Get() instead of manual row.Scan()
-	// Example: sqlscan.Get(context.Background(), r.db, &post, "SELECT * FROM posts WHERE id = $1", id)
-	// Notice how this eliminates the need for manual field scanning
-	return nil, fmt.Errorf("TODO: implement GetByID method with scany")
+// Implement GetByUserID method using scany
+func (r *PostRepository) GetByUserID(ctx context.Context, userID int) ([]models.Post, error) {
+	// Get all posts by user ID using scany
+	return r.selectPosts(ctx, `WHERE user_id = $1 AND deleted_at IS NULL`, userID)
 }
 
-// TODO: Implement GetByUserID method using scany
-func (r *PostRepository) GetByUserID(userID int) ([]models.Post, error) {
-	// TODO: Get all posts by user ID using scany
-	// - Use sqlscan.Select() for multiple rows instead of manual rows.Next() loop
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, query, userID)
-	// This eliminates manual iteration and scanning
-	return nil, fmt.Errorf("TODO: implement GetByUserID method with scany")
+// Implement GetPublished method using scany
+func (r *PostRepository) GetPublished(ctx context.Context) ([]models.Post, error) {
+	// Get all published posts using scany
+	return r.selectPosts(ctx, `WHERE published = TRUE AND deleted_at IS NULL`)
 }
 
-// TODO: Implement GetPublished method using scany
-func (r *PostRepository) GetPublished() ([]models.Post, error) {
-	// TODO: Get all published posts using scany
-	// - Use sqlscan.Select() for multiple rows
-	// - Query posts where published = true
-	// - Order by created_at DESC
-	return nil, fmt.Errorf("TODO: implement GetPublished method with scany")
+// Implement GetAll method using scany
+func (r *PostRepository) GetAll(ctx context.Context) ([]models.Post, error) {
+	// Get all posts from database using scany
+	return r.selectPosts(ctx, `WHERE deleted_at IS NULL`)
 }
 
-// TODO: Implement GetAll method using scany
-func (r *PostRepository) GetAll() ([]models.Post, error) {
-	// TODO: Get all posts from database using scany
+// List returns a page of posts that are not deleted, newest first
+func (r *PostRepository) List(ctx context.Context, req PageRequest) (Page[models.Post], error) {
+	k, err := parsePageRequest(req)
+	if err != nil {
+		return Page[models.Post]{}, err
+	}
+
+	query := `SELECT ` + postColumns + ` FROM posts WHERE deleted_at IS NULL`
+	var args []interface{}
+	if k.cursor != nil {
+		query += fmt.Sprintf(" AND (created_at, id) %s ($1, $2)", k.op())
+		args = k.args()
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", k.order(""), k.fetch())
+
+	posts := []models.Post{}
+	if err := sqlscan.Select(ctx, r.db, &posts, query, args...); err != nil {
+		return Page[models.Post]{}, fmt.Errorf("failed to list posts: %w", err)
+	}
+	return buildPage(k, posts, postCursor), nil
+}
+
+// postCursor is the keyset position of p
+func postCursor(p models.Post) Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+// selectPosts returns the posts matching where, newest first
+func (r *PostRepository) selectPosts(ctx context.Context, where string, args ...interface{}) ([]models.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts ` + where + ` ORDER BY created_at DESC, id DESC`
+
 	// - Use sqlscan.Select() instead of manual rows iteration
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, "SELECT * FROM posts ORDER BY created_at DESC")
-	// Compare this simplicity with manual scanning in user repository
-	return nil, fmt.Errorf("TODO: implement GetAll method with scany")
+	posts := []models.Post{}
+	if err := sqlscan.Select(ctx, r.db, &posts, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	return posts, nil
 }
 
-// TODO: Implement Update method using scany
-func (r *PostRepository) Update(id int, req *models.UpdatePostRequest) (*models.Post, error) {
-	// TODO: Update post in database using scany
+// Implement Update method using scany
+func (r *PostRepository) Update(ctx context.Context, id int, req *models.UpdatePostRequest) (*models.Post, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Update post in database using scany
 	// - Build dynamic UPDATE query based on non-nil fields in req
+	var updates []string
+	var args []interface{}
+	argNum := 1
+
+	if req.Title != nil {
+		updates = append(updates, fmt.Sprintf("title = $%d", argNum))
+		args = append(args, *req.Title)
+		argNum++
+	}
+
+	if req.Content != nil {
+		updates = append(updates, fmt.Sprintf("content = $%d", argNum))
+		args = append(args, *req.Content)
+		argNum++
+	}
+
+	// - Publishing moves the post to published, unpublishing to draft,
+	// when the workflow allows it from the current status
+	var target models.PostStatus
+	statusCheck := ""
+	if req.Published != nil {
+		target = models.PostDraft
+		var publishAt *time.Time
+		if *req.Published {
+			target = models.PostPublished
+			now := time.Now()
+			publishAt = &now
+		}
+		updates = append(updates,
+			fmt.Sprintf("published = $%d", argNum),
+			fmt.Sprintf("status = $%d", argNum+1),
+			fmt.Sprintf("publish_at = CASE WHEN status = $%d THEN publish_at ELSE $%d END", argNum+1, argNum+2))
+		args = append(args, *req.Published, target, utcTime(publishAt))
+		argNum += 3
+		statusCheck = " AND status IN (" + statusList(append(target.TransitionSources(), target)) + ")"
+	}
+
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
 	// - Update updated_at timestamp
+	updates = append(updates, fmt.Sprintf("updated_at = $%d", argNum))
+	args = append(args, time.Now())
+	argNum++
+
+	args = append(args, id)
+
 	// - Use sqlscan.Get() with RETURNING clause to get updated post
-	// This avoids a separate SELECT query after UPDATE
-	return nil, fmt.Errorf("TODO: implement Update method with scany")
+	query := fmt.Sprintf(`
+		UPDATE posts
+		SET %s
+		WHERE id = $%d AND deleted_at IS NULL%s
+		RETURNING %s
+	`, strings.Join(updates, ", "), argNum, statusCheck, postColumns)
+
+	post := &models.Post{}
+	if err := sqlscan.Get(ctx, r.db, post, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) && statusCheck != "" {
+			return nil, r.transitionError(ctx, id, target)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("post not found: %w", sql.ErrNoRows)
+		}
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+	return post, nil
+}
+
+// Delete soft deletes a post by setting deleted_at.
+// It returns sql.ErrNoRows if the post does not exist or is already deleted.
+func (r *PostRepository) Delete(ctx context.Context, id int) error {
+	query := `
+		UPDATE posts
+		SET deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return requireRowsAffected(result)
+}
+
+// Restore undoes Delete and returns the restored post.
+// It returns sql.ErrNoRows if the post does not exist or is not deleted.
+func (r *PostRepository) Restore(ctx context.Context, id int) (*models.Post, error) {
+	query := `
+		UPDATE posts
+		SET deleted_at = NULL, updated_at = $1
+		WHERE id = $2 AND deleted_at IS NOT NULL
+		RETURNING ` + postColumns
+
+	post := &models.Post{}
+	if err := sqlscan.Get(ctx, r.db, post, query, time.Now(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to restore post: %w", err)
+	}
+	return post, nil
+}
+
+// HardDelete removes a post from the table, whether or not it is soft deleted.
+// It returns sql.ErrNoRows if the post does not exist.
+func (r *PostRepository) HardDelete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return requireRowsAffected(result)
 }
 
-// TODO: Implement Delete method (standard SQL)
-func (r *PostRepository) Delete(id int) error {
-	// TODO: Delete post from database
-	// - Delete from posts table by ID
-	// - Return error if post doesn't exist
-	// Note: Delete operations typically don't need scany since no data is returned
-	return fmt.Errorf("TODO: implement Delete method")
+// requireRowsAffected returns sql.ErrNoRows if result changed no rows
+func requireRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-// TODO: Implement Count method (standard SQL)
-func (r *PostRepository) Count() (int, error) {
-	// TODO: Count total number of posts
-	// - Return count of posts in database
-	// - Can use standard QueryRow.Scan() for single values like count
-	return 0, fmt.Errorf("TODO: implement Count method")
+// Implement Count method (standard SQL)
+func (r *PostRepository) Count(ctx context.Context) (int, error) {
+	// Count posts that are not soft deleted
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+	return count, nil
 }
 
-// TODO: Implement CountByUserID method (standard SQL)
-func (r *PostRepository) CountByUserID(userID int) (int, error) {
-	// TODO: Count posts by user ID
-	// - Return count of posts for specific user
-	// - Use standard QueryRow.Scan() for single integer result
-	return 0, fmt.Errorf("TODO: implement CountByUserID method")
+// Implement CountByUserID method (standard SQL)
+func (r *PostRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
+	// Count posts of a user that are not soft deleted
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE user_id = $1 AND deleted_at IS NULL`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count posts: %w", err)
+	}
+	return count, nil
 }
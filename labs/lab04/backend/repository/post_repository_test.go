@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"lab04-backend/models"
+)
+
+func setupPostTestDB(t *testing.T) (*PostRepository, int, func()) {
+	db, cleanup := openTestDB(t, "./test_post_repo.db")
+
+	user, err := NewUserRepository(db).Create(context.Background(), &models.CreateUserRequest{Name: "Post Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create post author: %v", err)
+	}
+
+	return NewPostRepository(db), user.ID, cleanup
+}
+
+func createTestPost(t *testing.T, repo *PostRepository, userID int, title string, published bool) *models.Post {
+	t.Helper()
+	post, err := repo.Create(context.Background(), &models.CreatePostRequest{UserID: userID, Title: title, Content: "Some content", Published: published})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	return post
+}
+
+func TestPostRepository_CreateAndGet(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	post := createTestPost(t, repo, userID, "First post", true)
+	if post.ID == 0 {
+		t.Error("Create() should set post ID")
+	}
+	if post.CreatedAt.IsZero() || post.UpdatedAt.IsZero() {
+		t.Error("Create() should set timestamps")
+	}
+	if post.DeletedAt != nil {
+		t.Errorf("Expected new post not to be deleted, got %v", post.DeletedAt)
+	}
+
+	got, err := repo.GetByID(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if got.Title != "First post" || got.Content != "Some content" || !got.Published || got.UserID != userID {
+		t.Errorf("Expected the created post, got %+v", got)
+	}
+
+	if _, err := repo.GetByID(ctx, 9999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for missing post, got %v", err)
+	}
+
+	if _, err := repo.Create(ctx, &models.CreatePostRequest{UserID: userID, Title: "Hi"}); err == nil {
+		t.Error("Expected validation error for short title")
+	}
+}
+
+func TestPostRepository_Lists(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	first := createTestPost(t, repo, userID, "Published post", true)
+	second := createTestPost(t, repo, userID, "Draft post", false)
+
+	all, err := repo.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() failed: %v", err)
+	}
+	if len(all) != 2 || all[0].ID != second.ID || all[1].ID != first.ID {
+		t.Errorf("Expected both posts newest first, got %+v", all)
+	}
+
+	published, err := repo.GetPublished(ctx)
+	if err != nil {
+		t.Fatalf("GetPublished() failed: %v", err)
+	}
+	if len(published) != 1 || published[0].ID != first.ID {
+		t.Errorf("Expected only the published post, got %+v", published)
+	}
+
+	byUser, err := repo.GetByUserID(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetByUserID() failed: %v", err)
+	}
+	if len(byUser) != 2 {
+		t.Errorf("Expected 2 posts for user, got %d", len(byUser))
+	}
+
+	none, err := repo.GetByUserID(ctx, userID+1)
+	if err != nil {
+		t.Fatalf("GetByUserID() failed: %v", err)
+	}
+	if none == nil || len(none) != 0 {
+		t.Errorf("Expected empty slice for user without posts, got %v", none)
+	}
+}
+
+func TestPostRepository_Update(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	post := createTestPost(t, repo, userID, "Original title", false)
+
+	title := "Updated title"
+	published := true
+	updated, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Title: &title, Published: &published})
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if updated.Title != title || !updated.Published || updated.Content != post.Content {
+		t.Errorf("Expected title and published to change only, got %+v", updated)
+	}
+	if !updated.UpdatedAt.After(post.UpdatedAt) {
+		t.Errorf("Expected UpdatedAt after %v, got %v", post.UpdatedAt, updated.UpdatedAt)
+	}
+
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{}); err == nil {
+		t.Error("Expected error when no fields are set")
+	}
+	short := "Hi"
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Title: &short}); err == nil {
+		t.Error("Expected validation error for short title")
+	}
+	if _, err := repo.Update(ctx, 9999, &models.UpdatePostRequest{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for missing post, got %v", err)
+	}
+}
+
+func TestPostRepository_SoftDelete(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	kept := createTestPost(t, repo, userID, "Kept post", true)
+	deleted := createTestPost(t, repo, userID, "Deleted post", true)
+
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := repo.Delete(ctx, deleted.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows deleting twice, got %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, deleted.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected deleted post to be hidden, got %v", err)
+	}
+	title := "Changed title"
+	if _, err := repo.Update(ctx, deleted.ID, &models.UpdatePostRequest{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected deleted post not to be updated, got %v", err)
+	}
+	for name, list := range map[string]func(context.Context) ([]models.Post, error){
+		"GetAll":       repo.GetAll,
+		"GetPublished": repo.GetPublished,
+		"GetByUserID":  func(ctx context.Context) ([]models.Post, error) { return repo.GetByUserID(ctx, userID) },
+	} {
+		posts, err := list(ctx)
+		if err != nil {
+			t.Fatalf("%s() failed: %v", name, err)
+		}
+		if len(posts) != 1 || posts[0].ID != kept.ID {
+			t.Errorf("Expected %s() to return only the kept post, got %+v", name, posts)
+		}
+	}
+	if count, _ := repo.Count(ctx); count != 1 {
+		t.Errorf("Expected Count() 1, got %d", count)
+	}
+	if count, _ := repo.CountByUserID(ctx, userID); count != 1 {
+		t.Errorf("Expected CountByUserID() 1, got %d", count)
+	}
+
+	restored, err := repo.Restore(ctx, deleted.ID)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Title != deleted.Title {
+		t.Errorf("Expected restored post, got %+v", restored)
+	}
+	if _, err := repo.Restore(ctx, deleted.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows restoring a live post, got %v", err)
+	}
+	if count, _ := repo.Count(ctx); count != 2 {
+		t.Errorf("Expected Count() 2 after restore, got %d", count)
+	}
+}
+
+func TestPostRepository_HardDelete(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	post := createTestPost(t, repo, userID, "Doomed post", false)
+	if err := repo.Delete(ctx, post.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := repo.HardDelete(ctx, post.ID); err != nil {
+		t.Fatalf("HardDelete() failed: %v", err)
+	}
+	if _, err := repo.Restore(ctx, post.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected hard deleted post to be gone, got %v", err)
+	}
+	if err := repo.HardDelete(ctx, post.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for missing post, got %v", err)
+	}
+}
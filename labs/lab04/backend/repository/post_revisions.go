@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	"lab04-backend/models"
+)
+
+// Revisions of a post are recorded by database triggers, added with the
+// post_revisions table: one when the post is created and one whenever its
+// title or content changes, however the change is made.
+
+// revisionColumns lists the columns scanned into models.PostRevision
+const revisionColumns = `id, post_id, revision, title, COALESCE(content, '') AS content, created_at`
+
+// GetRevisions returns the revisions of a post, newest first.
+// A post without revisions does not exist.
+func (r *PostRepository) GetRevisions(ctx context.Context, postID int) ([]models.PostRevision, error) {
+	query := `SELECT ` + revisionColumns + ` FROM post_revisions WHERE post_id = $1 ORDER BY revision DESC`
+
+	revisions := []models.PostRevision{}
+	if err := sqlscan.Select(ctx, r.db, &revisions, query, postID); err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a post, or sql.ErrNoRows
+func (r *PostRepository) GetRevision(ctx context.Context, postID, revision int) (*models.PostRevision, error) {
+	query := `SELECT ` + revisionColumns + ` FROM post_revisions WHERE post_id = $1 AND revision = $2`
+
+	rev := &models.PostRevision{}
+	if err := sqlscan.Get(ctx, r.db, rev, query, postID, revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+	return rev, nil
+}
+
+// DiffRevisions compares two revisions of a post. It returns sql.ErrNoRows
+// if either does not exist.
+func (r *PostRepository) DiffRevisions(ctx context.Context, postID, from, to int) (*models.RevisionDiff, error) {
+	fromRev, err := r.GetRevision(ctx, postID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := r.GetRevision(ctx, postID, to)
+	if err != nil {
+		return nil, err
+	}
+	return models.DiffRevisions(fromRev, toRev), nil
+}
+
+// Revert sets the title and content of a post back to those of revision.
+// The revert is an edit like any other, so it is recorded as a new
+// revision and can itself be reverted. It returns sql.ErrNoRows if the post
+// is deleted or the revision does not exist.
+func (r *PostRepository) Revert(ctx context.Context, postID, revision int) (*models.Post, error) {
+	query := `
+		UPDATE posts
+		SET title = (SELECT title FROM post_revisions WHERE post_id = $1 AND revision = $2),
+			content = (SELECT content FROM post_revisions WHERE post_id = $1 AND revision = $2),
+			updated_at = $3
+		WHERE id = $1 AND deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM post_revisions WHERE post_id = $1 AND revision = $2)
+		RETURNING ` + postColumns
+
+	post := &models.Post{}
+	if err := sqlscan.Get(ctx, r.db, post, query, postID, revision, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to revert post: %w", err)
+	}
+	return post, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"lab04-backend/models"
+)
+
+func TestPostRepository_Revisions(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	post := createTestPost(t, repo, userID, "Revised post", false)
+	content := "Line one\nLine two"
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Content: &content}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	// Publishing does not touch the content, so it is not a revision
+	yes := true
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Published: &yes}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	bad := "Vandalized title"
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Title: &bad}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	revisions, err := repo.GetRevisions(ctx, post.ID)
+	if err != nil {
+		t.Fatalf("GetRevisions() failed: %v", err)
+	}
+	if len(revisions) != 3 || revisions[0].Revision != 3 || revisions[2].Revision != 1 {
+		t.Fatalf("Expected revisions 3, 2 and 1, got %+v", revisions)
+	}
+	if revisions[2].Title != "Revised post" || revisions[2].Content != "Some content" || revisions[0].Title != bad {
+		t.Errorf("Expected the original and the vandalized versions, got %+v", revisions)
+	}
+
+	diff, err := repo.DiffRevisions(ctx, post.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("DiffRevisions() failed: %v", err)
+	}
+	want := "-Some content\n+Line one\n+Line two\n"
+	if got := diff.String(); got != want {
+		t.Errorf("Expected diff %q, got %q", want, got)
+	}
+	if _, err := repo.DiffRevisions(ctx, post.ID, 1, 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a missing revision, got %v", err)
+	}
+
+	reverted, err := repo.Revert(ctx, post.ID, 2)
+	if err != nil {
+		t.Fatalf("Revert() failed: %v", err)
+	}
+	if reverted.Title != "Revised post" || reverted.Content != content || reverted.Status != models.PostPublished {
+		t.Errorf("Expected revision 2 restored without changing status, got %+v", reverted)
+	}
+	latest, err := repo.GetRevision(ctx, post.ID, 4)
+	if err != nil {
+		t.Fatalf("Expected the revert recorded as revision 4: %v", err)
+	}
+	if diff := models.DiffRevisions(&revisions[1], latest); diff.Changed() {
+		t.Errorf("Expected revision 4 to equal revision 2, got %s", diff)
+	}
+
+	if _, err := repo.Revert(ctx, post.ID, 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows reverting to a missing revision, got %v", err)
+	}
+	if err := repo.HardDelete(ctx, post.ID); err != nil {
+		t.Fatalf("HardDelete() failed: %v", err)
+	}
+	if revisions, err := repo.GetRevisions(ctx, post.ID); err != nil || len(revisions) != 0 {
+		t.Errorf("Expected revisions deleted with the post, got %+v %v", revisions, err)
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PostScheduler publishes scheduled posts when they fall due
+type PostScheduler struct {
+	posts    *PostRepository
+	interval time.Duration
+	// now is time.Now, replaceable in tests
+	now func() time.Time
+}
+
+// NewPostScheduler creates a scheduler checking posts every interval
+func NewPostScheduler(posts *PostRepository, interval time.Duration) *PostScheduler {
+	return &PostScheduler{posts: posts, interval: interval, now: time.Now}
+}
+
+// Run publishes due posts right away and then every interval until ctx is
+// done. Errors are logged and retried on the next tick. Several schedulers
+// may run against one database: each due post is published once.
+func (s *PostScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("post scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes the posts that are due now and returns their IDs
+func (s *PostScheduler) RunOnce(ctx context.Context) ([]int, error) {
+	ids, err := s.posts.PublishDue(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		log.Printf("post scheduler: published posts %v", ids)
+	}
+	return ids, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+
+	"lab04-backend/models"
+)
+
+// Transition moves a post to req.Status if the workflow allows it from the
+// post's current status, and keeps published in step. Publishing stamps
+// publish_at with the current time, scheduling sets it to req.PublishAt,
+// archiving keeps it and the other statuses clear it.
+// It fails with models.ErrInvalidTransition for a transition that is not
+// allowed and sql.ErrNoRows if the post does not exist or is deleted.
+func (r *PostRepository) Transition(ctx context.Context, id int, req *models.PostTransitionRequest) (*models.Post, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	publishAt := "$3"
+	var publishAtArg *time.Time
+	switch req.Status {
+	case models.PostScheduled:
+		publishAtArg = req.PublishAt
+	case models.PostPublished:
+		publishAtArg = &now
+	case models.PostArchived:
+		// $3 is still referenced, as SQLite binds numbered parameters by position
+		publishAt = "COALESCE($3, publish_at)"
+	}
+	// The status check makes the read and the write one statement, so two
+	// editors cannot both move the post from the same status
+	query := fmt.Sprintf(`
+		UPDATE posts
+		SET status = $1, published = $2, publish_at = %s, updated_at = $4
+		WHERE id = $5 AND deleted_at IS NULL AND status IN (%s)
+		RETURNING %s
+	`, publishAt, statusList(req.Status.TransitionSources()), postColumns)
+
+	post := &models.Post{}
+	err := sqlscan.Get(ctx, r.db, post, query,
+		req.Status, req.Status == models.PostPublished, utcTime(publishAtArg), now, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, r.transitionError(ctx, id, req.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to change post status: %w", err)
+	}
+	return post, nil
+}
+
+// Schedule moves a post to scheduled, to be published at publishAt by
+// PublishDue. A scheduled post can be scheduled again for another time.
+func (r *PostRepository) Schedule(ctx context.Context, id int, publishAt time.Time) (*models.Post, error) {
+	return r.Transition(ctx, id, &models.PostTransitionRequest{Status: models.PostScheduled, PublishAt: &publishAt})
+}
+
+// PublishDue publishes the scheduled posts whose publish_at is not after now
+// and returns their IDs. publish_at keeps the scheduled time.
+func (r *PostRepository) PublishDue(ctx context.Context, now time.Time) ([]int, error) {
+	query := `
+		UPDATE posts
+		SET status = $1, published = TRUE, updated_at = $2
+		WHERE status = $3 AND publish_at <= $4 AND deleted_at IS NULL
+		RETURNING id
+	`
+	ids := []int{}
+	err := sqlscan.Select(ctx, r.db, &ids, query, models.PostPublished, now, models.PostScheduled, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish due posts: %w", err)
+	}
+	return ids, nil
+}
+
+// GetByStatus returns the posts with status that are not deleted, newest first
+func (r *PostRepository) GetByStatus(ctx context.Context, status models.PostStatus) ([]models.Post, error) {
+	return r.selectPosts(ctx, `WHERE status = $1 AND deleted_at IS NULL`, status)
+}
+
+// transitionError explains why moving post id to status changed no row:
+// the post does not exist, or its status cannot move to status
+func (r *PostRepository) transitionError(ctx context.Context, id int, to models.PostStatus) error {
+	var from models.PostStatus
+	err := r.db.QueryRowContext(ctx, `SELECT status FROM posts WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("post not found: %w", sql.ErrNoRows)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get post status: %w", err)
+	}
+	return fmt.Errorf("%w from %s to %s", models.ErrInvalidTransition, from, to)
+}
+
+// statusList formats statuses as SQL string literals. The statuses are
+// the models constants, so they need no escaping.
+func statusList(statuses []models.PostStatus) string {
+	quoted := make([]string, len(statuses))
+	for i, status := range statuses {
+		quoted[i] = "'" + string(status) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// utcTime converts t to UTC. SQLite stores times as text with the zone
+// offset, so publish_at is kept in UTC for PublishDue to compare it.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"lab04-backend/models"
+)
+
+func TestPostRepository_Transition(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	post := createTestPost(t, repo, userID, "Workflow post", false)
+	if post.Status != models.PostDraft || post.PublishAt != nil {
+		t.Fatalf("Expected a new draft without publish_at, got %+v", post)
+	}
+	if published := createTestPost(t, repo, userID, "Published at once", true); published.Status != models.PostPublished || published.PublishAt == nil {
+		t.Errorf("Expected a published post with publish_at, got %+v", published)
+	}
+
+	move := func(status models.PostStatus, publishAt *time.Time) (*models.Post, error) {
+		return repo.Transition(ctx, post.ID, &models.PostTransitionRequest{Status: status, PublishAt: publishAt})
+	}
+
+	reviewed, err := move(models.PostInReview, nil)
+	if err != nil {
+		t.Fatalf("Transition() to in_review failed: %v", err)
+	}
+	if reviewed.Status != models.PostInReview || reviewed.Published {
+		t.Errorf("Expected an unpublished post in review, got %+v", reviewed)
+	}
+
+	if _, err := move(models.PostArchived, nil); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition from in_review to archived, got %v", err)
+	}
+
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	scheduled, err := repo.Schedule(ctx, post.ID, at)
+	if err != nil {
+		t.Fatalf("Schedule() failed: %v", err)
+	}
+	if scheduled.Status != models.PostScheduled || scheduled.PublishAt == nil || !scheduled.PublishAt.Equal(at) {
+		t.Errorf("Expected the post scheduled at %v, got %+v", at, scheduled)
+	}
+	later := at.Add(time.Hour)
+	if rescheduled, err := repo.Schedule(ctx, post.ID, later); err != nil || !rescheduled.PublishAt.Equal(later) {
+		t.Errorf("Expected the post rescheduled at %v, got %+v %v", later, rescheduled, err)
+	}
+
+	published, err := move(models.PostPublished, nil)
+	if err != nil {
+		t.Fatalf("Transition() to published failed: %v", err)
+	}
+	if !published.Published || published.PublishAt == nil || published.PublishAt.After(time.Now()) {
+		t.Errorf("Expected the post published now, got %+v", published)
+	}
+
+	archived, err := move(models.PostArchived, nil)
+	if err != nil {
+		t.Fatalf("Transition() to archived failed: %v", err)
+	}
+	if archived.Published || archived.PublishAt == nil || !archived.PublishAt.Equal(*published.PublishAt) {
+		t.Errorf("Expected an archived post keeping publish_at, got %+v", archived)
+	}
+
+	if _, err := move(models.PostPublished, nil); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition from archived to published, got %v", err)
+	}
+	if _, err := move(models.PostScheduled, nil); err == nil {
+		t.Error("Expected an error for scheduling without publish_at")
+	}
+	if _, err := repo.Transition(ctx, 9999, &models.PostTransitionRequest{Status: models.PostInReview}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a missing post, got %v", err)
+	}
+
+	// Update follows the workflow when it changes published
+	yes := true
+	if _, err := repo.Update(ctx, post.ID, &models.UpdatePostRequest{Published: &yes}); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition publishing an archived post, got %v", err)
+	}
+	drafts, err := repo.GetByStatus(ctx, models.PostArchived)
+	if err != nil || len(drafts) != 1 || drafts[0].ID != post.ID {
+		t.Errorf("Expected the archived post, got %+v %v", drafts, err)
+	}
+}
+
+func TestPostRepository_PublishDue(t *testing.T) {
+	repo, userID, cleanup := setupPostTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	due := createTestPost(t, repo, userID, "Due post", false)
+	notDue := createTestPost(t, repo, userID, "Not due post", false)
+	deleted := createTestPost(t, repo, userID, "Deleted due post", false)
+	createTestPost(t, repo, userID, "Plain draft", false)
+
+	now := time.Now()
+	for _, p := range []*models.Post{due, notDue, deleted} {
+		if _, err := repo.Schedule(ctx, p.ID, now.Add(time.Minute)); err != nil {
+			t.Fatalf("Schedule() failed: %v", err)
+		}
+	}
+	if _, err := repo.Schedule(ctx, notDue.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Schedule() failed: %v", err)
+	}
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	scheduler := NewPostScheduler(repo, time.Minute)
+	if ids, err := scheduler.RunOnce(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("Expected nothing due yet, got %v %v", ids, err)
+	}
+
+	// In a time zone east of UTC, local times compare wrong as SQLite text
+	scheduler.now = func() time.Time { return now.Add(2 * time.Minute).In(time.FixedZone("UTC+5", 5*3600)) }
+	ids, err := scheduler.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{due.ID}) {
+		t.Errorf("Expected only post %d published, got %v", due.ID, ids)
+	}
+	published, err := repo.GetByID(ctx, due.ID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if published.Status != models.PostPublished || !published.Published {
+		t.Errorf("Expected the due post published, got %+v", published)
+	}
+	if ids, err := scheduler.RunOnce(ctx); err != nil || len(ids) != 0 {
+		t.Errorf("Expected the post published only once, got %v %v", ids, err)
+	}
+
+	// Run stops with its context
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(runCtx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Run() did not stop when its context was canceled")
+	}
+}
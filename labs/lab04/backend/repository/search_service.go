@@ -4,17 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
+	"lab04-backend/database"
 	"lab04-backend/models"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
 )
 
 // SearchService handles dynamic search operations using Squirrel query builder
 // This service demonstrates SQUIRREL QUERY BUILDER approach for dynamic SQL
+//
+// Text queries use the posts_fts FTS5 index when the migration that creates it
+// has run, and fall back to LIKE matching without ranking otherwise.
+// Postgres always uses the fallback, with ILIKE.
 type SearchService struct {
-	db   *sql.DB
-	psql squirrel.StatementBuilderType
+	db      *sql.DB
+	psql    squirrel.StatementBuilderType
+	dialect database.Dialect
+	fts     bool // posts_fts exists
 }
 
 // SearchFilters represents search parameters
@@ -24,82 +33,195 @@ type SearchFilters struct {
 	Published    *bool  // Filter by published status
 	MinWordCount *int   // Minimum word count in content
 	Limit        int    // Results limit (default 50)
-	Offset       int    // Results offset (for pagination)
-	OrderBy      string // Order by field (title, created_at, updated_at)
+	Offset       int    // Results offset; SearchPostsPage pages by cursor instead
+	OrderBy      string // Order by field (title, created_at, updated_at, rank)
 	OrderDir     string // Order direction (ASC, DESC)
 }
 
-// NewSearchService creates a new SearchService
+// PostSearchResult is a post found by SearchPosts
+type PostSearchResult struct {
+	models.Post
+	// Rank is higher for better matches; 0 without a text query or FTS5
+	Rank float64 `json:"rank" db:"rank"`
+	// Snippet is the best matching part of the post with matches in [brackets]
+	Snippet string `json:"snippet" db:"snippet"`
+}
+
+const defaultSearchLimit = 50
+
+// searchOrderColumns allow-lists the SearchFilters.OrderBy values
+var searchOrderColumns = map[string]string{
+	"title":      "posts.title",
+	"created_at": "posts.created_at",
+	"updated_at": "posts.updated_at",
+	"rank":       "rank",
+}
+
+// NewSearchService creates a new SearchService.
+// Run migrations first: it checks once whether the FTS5 index exists.
 func NewSearchService(db *sql.DB) *SearchService {
-	return &SearchService{
-		db:   db,
-		psql: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
-	}
-}
-
-// TODO: Implement SearchPosts method using Squirrel query builder
-func (s *SearchService) SearchPosts(ctx context.Context, filters SearchFilters) ([]models.Post, error) {
-	// TODO: Build dynamic query using Squirrel instead of string concatenation
-	//
-	// Start with base query:
-	// query := s.psql.Select("id", "user_id", "title", "content", "published", "created_at", "updated_at").
-	//              From("posts")
-	//
-	// Add WHERE conditions dynamically:
-	// - If filters.Query: add ILIKE conditions for title and content
-	// - If filters.UserID: add user_id = ?
-	// - If filters.Published: add published = ?
-	// - If filters.MinWordCount: add word count condition
-	//
-	// Add ORDER BY dynamically:
-	// - Use OrderBy() and validate sort fields
-	//
-	// Add LIMIT/OFFSET:
-	// - Use Limit() and Offset()
-	//
-	// Build final SQL:
-	// sql, args, err := query.ToSql()
-	//
-	// Execute with scany:
-	// var posts []models.Post
-	// err = sqlscan.Select(ctx, s.db, &posts, sql, args...)
-	//
-	// This demonstrates the power of combining Squirrel (dynamic queries)
-	// with scany (automatic result mapping)
-
-	return nil, fmt.Errorf("TODO: implement SearchPosts with Squirrel query builder")
-}
-
-// TODO: Implement SearchUsers method using Squirrel
+	s := &SearchService{
+		db:      db,
+		psql:    squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		dialect: database.DialectOf(db),
+	}
+	if s.dialect == database.SQLite {
+		var name string
+		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts'`).Scan(&name)
+		s.fts = err == nil && database.HasFTS5(db)
+	}
+	return s
+}
+
+// SearchPosts finds posts that are not deleted and match every filter.
+// With a text query and no OrderBy the best matches come first.
+func (s *SearchService) SearchPosts(ctx context.Context, filters SearchFilters) ([]PostSearchResult, error) {
+	orderBy, err := searchOrder(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.BuildDynamicQuery(s.searchSelect(s.rankAndSnippet(filters)), filters)
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query = query.OrderBy(orderBy...).Limit(uint64(limit))
+	if filters.Offset > 0 {
+		query = query.Offset(uint64(filters.Offset))
+	}
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build search query: %w", err)
+	}
+	results := []PostSearchResult{}
+	if err := sqlscan.Select(ctx, s.db, &results, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	return results, nil
+}
+
+// SearchPostsPage is SearchPosts with keyset pagination, newest first.
+// Limit, Offset and the order fields of filters must be left empty.
+func (s *SearchService) SearchPostsPage(ctx context.Context, filters SearchFilters, req PageRequest) (Page[PostSearchResult], error) {
+	if filters.Limit != 0 || filters.Offset != 0 || filters.OrderBy != "" || filters.OrderDir != "" {
+		return Page[PostSearchResult]{}, fmt.Errorf("limit, offset and order do not apply to keyset pages")
+	}
+	k, err := parsePageRequest(req)
+	if err != nil {
+		return Page[PostSearchResult]{}, err
+	}
+
+	rank, snippet := s.rankAndSnippet(filters)
+	query := s.BuildDynamicQuery(s.searchSelect(rank, snippet), filters)
+	if k.cursor != nil {
+		query = query.Where("(posts.created_at, posts.id) "+k.op()+" (?, ?)", k.args()...)
+	}
+	query = query.OrderBy(k.order("posts.")).Limit(uint64(k.fetch()))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return Page[PostSearchResult]{}, fmt.Errorf("failed to build search query: %w", err)
+	}
+	results := []PostSearchResult{}
+	if err := sqlscan.Select(ctx, s.db, &results, sqlQuery, args...); err != nil {
+		return Page[PostSearchResult]{}, fmt.Errorf("failed to search posts: %w", err)
+	}
+	return buildPage(k, results, func(r PostSearchResult) Cursor { return postCursor(r.Post) }), nil
+}
+
+// rankAndSnippet returns the SQL for the rank and snippet columns
+func (s *SearchService) rankAndSnippet(filters SearchFilters) (string, string) {
+	if s.fts && strings.TrimSpace(filters.Query) != "" {
+		// bm25 is lower for better matches; title matches count ten times as much
+		return "-bm25(posts_fts, 10.0, 1.0)", "snippet(posts_fts, -1, '[', ']', '...', 16)"
+	}
+	return "0", "SUBSTR(COALESCE(posts.content, ''), 1, 120)"
+}
+
+// searchSelect selects the PostSearchResult columns from posts
+func (s *SearchService) searchSelect(rank, snippet string) squirrel.SelectBuilder {
+	return s.psql.Select(
+		"posts.id", "posts.user_id", "posts.title", "COALESCE(posts.content, '') AS content",
+		"posts.published", "posts.created_at", "posts.updated_at", "posts.deleted_at",
+		"posts.status", "posts.publish_at", "posts.comment_count", "posts.like_count",
+		rank+" AS rank", snippet+" AS snippet",
+	).From("posts")
+}
+
+// searchOrder returns the ORDER BY terms for filters, or an error if
+// OrderBy or OrderDir is not allowed
+func searchOrder(filters SearchFilters) ([]string, error) {
+	dir := strings.ToUpper(filters.OrderDir)
+	if dir != "" && dir != "ASC" && dir != "DESC" {
+		return nil, fmt.Errorf("invalid order direction %q", filters.OrderDir)
+	}
+
+	field := filters.OrderBy
+	if field == "" {
+		field = "created_at"
+		if strings.TrimSpace(filters.Query) != "" {
+			field = "rank"
+		}
+	}
+	column, ok := searchOrderColumns[field]
+	if !ok {
+		return nil, fmt.Errorf("invalid order field %q", filters.OrderBy)
+	}
+	if dir == "" {
+		dir = "DESC"
+		if field == "title" {
+			dir = "ASC"
+		}
+	}
+	// Break ties by ID so pages do not overlap
+	return []string{column + " " + dir, "posts.id " + dir}, nil
+}
+
+// SearchUsers finds users whose name contains nameQuery, ignoring ASCII case
 func (s *SearchService) SearchUsers(ctx context.Context, nameQuery string, limit int) ([]models.User, error) {
-	// TODO: Build user search query with Squirrel
-	// query := s.psql.Select("id", "name", "email", "created_at", "updated_at").
-	//              From("users").
-	//              Where(squirrel.Like{"name": "%" + nameQuery + "%"}).
-	//              OrderBy("name").
-	//              Limit(uint64(limit))
-	//
-	// sql, args, err := query.ToSql()
-	// var users []models.User
-	// err = sqlscan.Select(ctx, s.db, &users, sql, args...)
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query := s.psql.Select("id", "name", "email", "created_at", "updated_at").
+		From("users").
+		Where("name "+s.dialect.Like()+` ? ESCAPE '\'`, likePattern(nameQuery)).
+		OrderBy("name", "id").
+		Limit(uint64(limit))
 
-	return nil, fmt.Errorf("TODO: implement SearchUsers with Squirrel")
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user search query: %w", err)
+	}
+	users := []models.User{}
+	if err := sqlscan.Select(ctx, s.db, &users, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
 }
 
-// TODO: Implement GetPostStats method using Squirrel with JOINs
+// GetPostStats aggregates the posts that are not deleted
 func (s *SearchService) GetPostStats(ctx context.Context) (*PostStats, error) {
-	// TODO: Build complex query with JOINs using Squirrel
-	// query := s.psql.Select(
-	//     "COUNT(p.id) as total_posts",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_posts",
-	//     "COUNT(DISTINCT p.user_id) as active_users",
-	//     "AVG(LENGTH(p.content)) as avg_content_length",
-	// ).From("posts p").
-	//   Join("users u ON p.user_id = u.id")
-	//
-	// This shows how Squirrel handles complex queries better than string building
+	query := s.psql.Select(
+		"COUNT(p.id) AS total_posts",
+		"COUNT(CASE WHEN p.published = TRUE THEN 1 END) AS published_posts",
+		"COUNT(DISTINCT p.user_id) AS active_users",
+		"COALESCE(AVG(LENGTH(p.content)), 0) AS avg_content_length",
+	).From("posts p").
+		Join("users u ON p.user_id = u.id").
+		Where("p.deleted_at IS NULL")
 
-	return nil, fmt.Errorf("TODO: implement GetPostStats with Squirrel JOINs")
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build post stats query: %w", err)
+	}
+	stats := &PostStats{}
+	if err := sqlscan.Get(ctx, s.db, stats, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to get post stats: %w", err)
+	}
+	return stats, nil
 }
 
 // PostStats represents aggregated post statistics
@@ -110,60 +232,114 @@ type PostStats struct {
 	AvgContentLength float64 `db:"avg_content_length"`
 }
 
-// TODO: Implement BuildDynamicQuery helper method
+// BuildDynamicQuery adds the WHERE conditions of filters to baseQuery,
+// which must select from posts without an alias. Soft deleted posts are
+// always left out. Ordering and paging are up to the caller.
 func (s *SearchService) BuildDynamicQuery(baseQuery squirrel.SelectBuilder, filters SearchFilters) squirrel.SelectBuilder {
-	// TODO: Demonstrate how to build queries step by step with Squirrel
-	//
-	// query := baseQuery
-	//
-	// if filters.Query != "" {
-	//     searchTerm := "%" + filters.Query + "%"
-	//     query = query.Where(squirrel.Or{
-	//         squirrel.ILike{"title": searchTerm},
-	//         squirrel.ILike{"content": searchTerm},
-	//     })
-	// }
-	//
-	// if filters.UserID != nil {
-	//     query = query.Where(squirrel.Eq{"user_id": *filters.UserID})
-	// }
-	//
-	// if filters.Published != nil {
-	//     query = query.Where(squirrel.Eq{"published": *filters.Published})
-	// }
-	//
-	// This modular approach makes dynamic queries much cleaner
-	// than string concatenation used in manual SQL approaches
-
-	return baseQuery
-}
-
-// TODO: Implement GetTopUsers method using Squirrel with complex aggregation
+	query := baseQuery.Where("posts.deleted_at IS NULL")
+
+	if text := strings.TrimSpace(filters.Query); text != "" {
+		if s.fts {
+			query = query.Join("posts_fts ON posts_fts.rowid = posts.id").
+				Where("posts_fts MATCH ?", ftsQuery(text))
+		} else {
+			pattern, like := likePattern(text), s.dialect.Like()
+			query = query.Where(fmt.Sprintf(`(posts.title %[1]s ? ESCAPE '\' OR posts.content %[1]s ? ESCAPE '\')`, like), pattern, pattern)
+		}
+	}
+
+	if filters.UserID != nil {
+		query = query.Where(squirrel.Eq{"posts.user_id": *filters.UserID})
+	}
+
+	if filters.Published != nil {
+		query = query.Where(squirrel.Eq{"posts.published": *filters.Published})
+	}
+
+	if filters.MinWordCount != nil {
+		query = query.Where(wordCountSQL(s.dialect, "posts.content")+" >= ?", *filters.MinWordCount)
+	}
+
+	return query
+}
+
+// ftsQuery turns user input into an FTS5 query matching every word.
+// Each word is quoted so FTS5 operators in the input are searched for literally.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// likePattern matches values containing text, with LIKE wildcards in text
+// escaped by backslashes
+func likePattern(text string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(text) + "%"
+}
+
+// wordCountSQL counts the words of column: whitespace separated runs of
+// up to 16 spaces, tabs or newlines are treated as one separator
+func wordCountSQL(dialect database.Dialect, column string) string {
+	chr := "char"
+	if dialect == database.Postgres {
+		chr = "chr"
+	}
+	text := fmt.Sprintf("REPLACE(REPLACE(REPLACE(COALESCE(%[1]s, ''), %[2]s(9), ' '), %[2]s(10), ' '), %[2]s(13), ' ')", column, chr)
+	for i := 0; i < 4; i++ {
+		text = fmt.Sprintf("REPLACE(%s, '  ', ' ')", text)
+	}
+	text = "TRIM(" + text + ")"
+	return fmt.Sprintf("(CASE WHEN %[1]s = '' THEN 0 ELSE LENGTH(%[1]s) - LENGTH(REPLACE(%[1]s, ' ', '')) + 1 END)", text)
+}
+
+// GetTopUsers ranks users by engagement, including users without posts.
+// A post counts 1, each like on it 1 and each comment on it 2; the
+// counts come from the columns the triggers keep on posts. Deleted posts
+// and comments are not counted.
 func (s *SearchService) GetTopUsers(ctx context.Context, limit int) ([]UserWithStats, error) {
-	// TODO: Build complex aggregation query with Squirrel
-	// query := s.psql.Select(
-	//     "u.id",
-	//     "u.name",
-	//     "u.email",
-	//     "COUNT(p.id) as post_count",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_count",
-	//     "MAX(p.created_at) as last_post_date",
-	// ).From("users u").
-	//   LeftJoin("posts p ON u.id = p.user_id").
-	//   GroupBy("u.id", "u.name", "u.email").
-	//   OrderBy("post_count DESC").
-	//   Limit(uint64(limit))
-	//
-	// Notice how Squirrel makes complex queries more readable
-	// compared to building SQL strings manually
-
-	return nil, fmt.Errorf("TODO: implement GetTopUsers with Squirrel aggregation")
-}
-
-// UserWithStats represents a user with post statistics
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query := s.psql.Select(
+		"u.id",
+		"u.name",
+		"u.email",
+		"u.created_at",
+		"u.updated_at",
+		"COUNT(p.id) AS post_count",
+		"COUNT(CASE WHEN p.published = TRUE THEN 1 END) AS published_count",
+		"COALESCE(CAST(MAX(p.created_at) AS TEXT), '') AS last_post_date",
+		"COALESCE(SUM(p.like_count), 0) AS like_count",
+		"COALESCE(SUM(p.comment_count), 0) AS comment_count",
+		"COUNT(p.id) + COALESCE(SUM(p.like_count), 0) + 2 * COALESCE(SUM(p.comment_count), 0) AS engagement",
+	).From("users u").
+		LeftJoin("posts p ON u.id = p.user_id AND p.deleted_at IS NULL").
+		GroupBy("u.id", "u.name", "u.email", "u.created_at", "u.updated_at").
+		OrderBy("engagement DESC", "post_count DESC", "u.id").
+		Limit(uint64(limit))
+
+	sqlQuery, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build top users query: %w", err)
+	}
+	users := []UserWithStats{}
+	if err := sqlscan.Select(ctx, s.db, &users, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to get top users: %w", err)
+	}
+	return users, nil
+}
+
+// UserWithStats represents a user with post and engagement statistics
 type UserWithStats struct {
 	models.User
 	PostCount      int    `db:"post_count"`
 	PublishedCount int    `db:"published_count"`
 	LastPostDate   string `db:"last_post_date"`
+	// LikeCount and CommentCount are received on the user's posts
+	LikeCount    int `db:"like_count"`
+	CommentCount int `db:"comment_count"`
+	Engagement   int `db:"engagement"`
 }
@@ -1,112 +1,230 @@
 package repository
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
 
-	"lab04-backend/database"
+	"lab04-backend/fixtures"
+	"lab04-backend/fixtures/fixturetest"
+	"lab04-backend/models"
 )
 
+func setupSearchService(t *testing.T) (*SearchService, *PostRepository, *fixtures.Refs, func()) {
+	db, cleanup := openTestDB(t, "./test_search_service.db")
+	refs := fixturetest.Load(t, db, "testdata/search.yaml")
+	return NewSearchService(db), NewPostRepository(db), refs, cleanup
+}
+
 // TestSearchService tests the Squirrel query builder approach
 func TestSearchService(t *testing.T) {
-	// Initialize database for testing
-	db, err := database.InitDB()
-	if err != nil {
-		t.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer database.CloseDB(db)
+	searchService, posts, refs, cleanup := setupSearchService(t)
+	defer cleanup()
+	ctx := context.Background()
 
-	// Run migrations
-	if err := database.RunMigrations(db); err != nil {
-		t.Fatalf("Failed to run migrations: %v", err)
+	aliceID, bobID, carolID := refs.Users["alice"], refs.Users["bob"], refs.Users["carol"]
+	golangTitle, golangBody, flutter := refs.Posts["golangTitle"], refs.Posts["golangBody"], refs.Posts["flutter"]
+	if err := posts.Delete(ctx, refs.Posts["deleted"]); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	ids := func(results []PostSearchResult) []int {
+		var ids []int
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	search := func(filters SearchFilters) []PostSearchResult {
+		t.Helper()
+		results, err := searchService.SearchPosts(ctx, filters)
+		if err != nil {
+			t.Fatalf("SearchPosts(%+v) failed: %v", filters, err)
+		}
+		return results
 	}
 
-	// Create service instance
-	searchService := NewSearchService(db)
-
-	// TODO: Test SearchPosts with various filters
 	t.Run("SearchPosts with filters", func(t *testing.T) {
-		// TODO: Test dynamic query building with Squirrel
-		// - Test empty filters (should return all posts)
-		// - Test search by query string
-		// - Test filter by user ID
-		// - Test filter by published status
-		// - Test pagination (limit/offset)
-		// - Test sorting (order by different fields)
-		//
-		// Example test structure:
-		// filters := SearchFilters{
-		//     Query: "golang",
-		//     Published: &[]bool{true}[0],
-		//     Limit: 10,
-		//     OrderBy: "created_at",
-		//     OrderDir: "DESC",
-		// }
-		// posts, err := searchService.SearchPosts(context.Background(), filters)
-		// assert.NoError(t, err)
-		// assert.LessOrEqual(t, len(posts), 10)
+		if got := ids(search(SearchFilters{})); !reflect.DeepEqual(got, []int{flutter, golangBody, golangTitle}) {
+			t.Errorf("Expected all live posts newest first, got %v", got)
+		}
+
+		results := search(SearchFilters{Query: "GOLANG"})
+		if got := ids(results); len(got) != 2 || !containsInt(got, golangTitle) || !containsInt(got, golangBody) {
+			t.Errorf("Expected both golang posts, got %v", got)
+		}
+		if searchService.fts {
+			// The title match ranks above the content match
+			if results[0].ID != golangTitle || results[0].Rank <= results[1].Rank {
+				t.Errorf("Expected title match ranked first, got %+v", results)
+			}
+			if !strings.Contains(results[1].Snippet, "[golang]") {
+				t.Errorf("Expected highlighted snippet, got %q", results[1].Snippet)
+			}
+		} else if results[0].Rank != 0 {
+			t.Errorf("Expected rank 0 without FTS5, got %v", results[0].Rank)
+		}
 
-		// Use searchService to avoid "declared and not used" error
-		_ = searchService
-		t.Skip("TODO: implement SearchPosts test with Squirrel filters")
+		if got := ids(search(SearchFilters{Query: "golang", UserID: &aliceID, Published: boolPtr(false)})); !reflect.DeepEqual(got, []int{golangBody}) {
+			t.Errorf("Expected the unpublished golang post, got %v", got)
+		}
+		if got := ids(search(SearchFilters{UserID: &bobID})); !reflect.DeepEqual(got, []int{flutter}) {
+			t.Errorf("Expected bob's live post, got %v", got)
+		}
+		if got := ids(search(SearchFilters{Published: boolPtr(true)})); !reflect.DeepEqual(got, []int{flutter, golangTitle}) {
+			t.Errorf("Expected published posts, got %v", got)
+		}
+
+		// "Some thoughts on golang\tand   databases" has 6 words
+		if got := ids(search(SearchFilters{MinWordCount: intPtr(6)})); !reflect.DeepEqual(got, []int{golangBody}) {
+			t.Errorf("Expected only the 6 word post, got %v", got)
+		}
+		if got := ids(search(SearchFilters{MinWordCount: intPtr(7)})); len(got) != 0 {
+			t.Errorf("Expected no post with 7 words, got %v", got)
+		}
+
+		if got := ids(search(SearchFilters{OrderBy: "title", Limit: 2, Offset: 1})); !reflect.DeepEqual(got, []int{golangTitle, golangBody}) {
+			t.Errorf("Expected second and third by title, got %v", got)
+		}
+		if got := ids(search(SearchFilters{OrderBy: "created_at", OrderDir: "asc"})); !reflect.DeepEqual(got, []int{golangTitle, golangBody, flutter}) {
+			t.Errorf("Expected oldest first, got %v", got)
+		}
+
+		// Query syntax is searched for literally
+		for _, q := range []string{`"golang`, "golang OR", "100%", "title:x*"} {
+			if _, err := searchService.SearchPosts(ctx, SearchFilters{Query: q}); err != nil {
+				t.Errorf("Expected query %q to be escaped, got %v", q, err)
+			}
+		}
+		if _, err := searchService.SearchPosts(ctx, SearchFilters{OrderBy: "title; DROP TABLE posts"}); err == nil {
+			t.Error("Expected error for unknown order field")
+		}
+		if _, err := searchService.SearchPosts(ctx, SearchFilters{OrderDir: "SIDEWAYS"}); err == nil {
+			t.Error("Expected error for unknown order direction")
+		}
 	})
 
-	// TODO: Test SearchUsers functionality
 	t.Run("SearchUsers", func(t *testing.T) {
-		// TODO: Test user search with Squirrel
-		// - Test exact name matches
-		// - Test partial name matches with LIKE
-		// - Test case insensitive search
-		// - Test limit functionality
-
-		_ = searchService
-		t.Skip("TODO: implement SearchUsers test with Squirrel")
+		found, err := searchService.SearchUsers(ctx, "SMITH", 10)
+		if err != nil {
+			t.Fatalf("SearchUsers() failed: %v", err)
+		}
+		if len(found) != 1 || found[0].ID != aliceID {
+			t.Errorf("Expected alice, got %+v", found)
+		}
+
+		found, _ = searchService.SearchUsers(ctx, "o", 1)
+		if len(found) != 1 || found[0].ID != bobID {
+			t.Errorf("Expected only bob with limit 1, got %+v", found)
+		}
+
+		found, _ = searchService.SearchUsers(ctx, "%", 10)
+		if len(found) != 1 || found[0].ID != carolID {
+			t.Errorf("Expected %% to match literally, got %+v", found)
+		}
 	})
 
-	// TODO: Test GetPostStats with complex aggregation
 	t.Run("GetPostStats", func(t *testing.T) {
-		// TODO: Test complex aggregation query
-		// - Insert test data (users and posts)
-		// - Test aggregation calculations
-		// - Verify JOIN functionality
-		// - Test with no data (empty tables)
-
-		_ = searchService
-		t.Skip("TODO: implement GetPostStats test with Squirrel JOINs")
+		stats, err := searchService.GetPostStats(ctx)
+		if err != nil {
+			t.Fatalf("GetPostStats() failed: %v", err)
+		}
+		if stats.TotalPosts != 3 || stats.PublishedPosts != 2 || stats.ActiveUsers != 2 {
+			t.Errorf("Expected 3 posts, 2 published, 2 active users, got %+v", stats)
+		}
+		if stats.AvgContentLength <= 0 {
+			t.Errorf("Expected positive average content length, got %v", stats.AvgContentLength)
+		}
 	})
 
-	// TODO: Test GetTopUsers with aggregation and sorting
 	t.Run("GetTopUsers", func(t *testing.T) {
-		// TODO: Test user ranking with post statistics
-		// - Insert users with different post counts
-		// - Test ordering by post count
-		// - Test LEFT JOIN behavior (users with no posts)
-		// - Test limit functionality
-
-		_ = searchService
-		t.Skip("TODO: implement GetTopUsers test with Squirrel aggregation")
+		top, err := searchService.GetTopUsers(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetTopUsers() failed: %v", err)
+		}
+		if len(top) != 3 {
+			t.Fatalf("Expected 3 users, got %d", len(top))
+		}
+		if top[0].ID != aliceID || top[0].PostCount != 2 || top[0].PublishedCount != 1 || top[0].LastPostDate == "" {
+			t.Errorf("Expected alice first with 2 posts, got %+v", top[0])
+		}
+		if top[1].ID != bobID || top[1].PostCount != 1 {
+			t.Errorf("Expected bob second without the deleted post, got %+v", top[1])
+		}
+		if top[2].ID != carolID || top[2].PostCount != 0 || top[2].LastPostDate != "" {
+			t.Errorf("Expected carol last without posts, got %+v", top[2])
+		}
 	})
 
-	// TODO: Test BuildDynamicQuery helper
 	t.Run("BuildDynamicQuery", func(t *testing.T) {
-		// TODO: Test query building step by step
-		// - Test with different filter combinations
-		// - Verify generated SQL syntax
-		// - Test parameter binding
-		//
-		// Example:
-		// baseQuery := searchService.psql.Select("*").From("posts")
-		// filters := SearchFilters{Query: "test", Published: &[]bool{true}[0]}
-		// query := searchService.BuildDynamicQuery(baseQuery, filters)
-		// sql, args, err := query.ToSql()
-		// assert.NoError(t, err)
-		// assert.Contains(t, sql, "WHERE")
-		// assert.Contains(t, sql, "published")
-
-		_ = searchService
-		t.Skip("TODO: implement BuildDynamicQuery test")
+		baseQuery := searchService.psql.Select("*").From("posts")
+		filters := SearchFilters{UserID: intPtr(7), Published: boolPtr(true)}
+		sql, args, err := searchService.BuildDynamicQuery(baseQuery, filters).ToSql()
+		if err != nil {
+			t.Fatalf("ToSql() failed: %v", err)
+		}
+		want := "SELECT * FROM posts WHERE posts.deleted_at IS NULL AND posts.user_id = $1 AND posts.published = $2"
+		if sql != want {
+			t.Errorf("Expected %q, got %q", want, sql)
+		}
+		if !reflect.DeepEqual(args, []interface{}{7, true}) {
+			t.Errorf("Expected args [7 true], got %v", args)
+		}
 	})
 }
 
+func TestSearchService_GetTopUsersEngagement(t *testing.T) {
+	searchService, posts, refs, cleanup := setupSearchService(t)
+	defer cleanup()
+	ctx := context.Background()
+	db := searchService.db
+
+	aliceID, bobID, carolID := refs.Users["alice"], refs.Users["bob"], refs.Users["carol"]
+	if err := posts.Delete(ctx, refs.Posts["deleted"]); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	// bob's one post gets a like and a comment, outweighing alice's two posts
+	if _, err := NewLikeRepository(db).Like(ctx, refs.Posts["flutter"], carolID); err != nil {
+		t.Fatalf("Like() failed: %v", err)
+	}
+	comment := &models.CreateCommentRequest{PostID: refs.Posts["flutter"], UserID: aliceID, Content: "Nice widgets"}
+	if _, err := NewCommentRepository(db).Create(ctx, comment); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	top, err := searchService.GetTopUsers(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetTopUsers() failed: %v", err)
+	}
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(top))
+	}
+	if top[0].ID != bobID || top[0].LikeCount != 1 || top[0].CommentCount != 1 || top[0].Engagement != 4 {
+		t.Errorf("Expected bob first with 1 like and 1 comment, got %+v", top[0])
+	}
+	if top[1].ID != aliceID || top[1].Engagement != 2 {
+		t.Errorf("Expected alice second with her 2 posts, got %+v", top[1])
+	}
+}
+
+func TestFTSQuery(t *testing.T) {
+	if got, want := ftsQuery(`go  "generics" OR`), `"go" """generics""" "OR"`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func intPtr(v int) *int    { return &v }
+func boolPtr(v bool) *bool { return &v }
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
 // TestSquirrelQueryBuilder tests Squirrel query building functionality
 func TestSquirrelQueryBuilder(t *testing.T) {
 	// TODO: Test Squirrel query builder patterns
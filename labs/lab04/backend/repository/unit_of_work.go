@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+
+	"lab04-backend/models"
+)
+
+// DBTX is what the repositories need to run queries.
+// Both *sql.DB and *sql.Tx implement it.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Repos holds repositories bound to one transaction
+type Repos struct {
+	Users    *UserRepository
+	Posts    *PostRepository
+	Comments *CommentRepository
+	Likes    *LikeRepository
+
+	tx    *sql.Tx
+	depth int // Savepoint nesting level, 0 for the transaction itself
+}
+
+// TxRunner runs fn in a transaction. UnitOfWork starts a new transaction,
+// Repos a savepoint in its own, so code taking a TxRunner works either way.
+type TxRunner interface {
+	WithTx(ctx context.Context, fn func(tx Repos) error) error
+}
+
+// UnitOfWork runs functions in transactions over the repositories in Repos
+type UnitOfWork struct {
+	db *sql.DB
+	// MaxRetries is how often a transaction that failed because the
+	// database was busy (see IsBusy) is run again
+	MaxRetries int
+	// RetryDelay is the wait before the first retry; it doubles after each
+	RetryDelay time.Duration
+}
+
+// NewUnitOfWork creates a new UnitOfWork
+func NewUnitOfWork(db *sql.DB) *UnitOfWork {
+	return &UnitOfWork{db: db, MaxRetries: 5, RetryDelay: 10 * time.Millisecond}
+}
+
+// WithTx runs fn in a transaction and commits it if fn returns nil.
+// When the transaction fails because the database is busy, fn is run again
+// in a new transaction, so fn must not have effects outside the database.
+func (u *UnitOfWork) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	delay := u.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := u.runTx(ctx, fn)
+		if err == nil || !IsBusy(err) || attempt >= u.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runTx makes one attempt at WithTx
+func (u *UnitOfWork) runTx(ctx context.Context, fn func(tx Repos) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(newRepos(tx, 0)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// WithTx runs fn inside a savepoint of the transaction of r.
+// An error from fn rolls back only what fn did; the transaction goes on.
+func (r Repos) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	name := fmt.Sprintf("sp_%d", r.depth+1)
+	if _, err := r.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	rollback := func() {
+		// ROLLBACK TO keeps the savepoint open, so it is released as well
+		r.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		r.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(newRepos(r.tx, r.depth+1)); err != nil {
+		rollback()
+		return err
+	}
+	if _, err := r.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+func newRepos(tx *sql.Tx, depth int) Repos {
+	return Repos{
+		Users:    NewUserRepository(tx),
+		Posts:    NewPostRepository(tx),
+		Comments: NewCommentRepository(tx),
+		Likes:    NewLikeRepository(tx),
+		tx:       tx,
+		depth:    depth,
+	}
+}
+
+// IsBusy reports whether err comes from SQLite being busy or locked
+// by another connection, or from Postgres aborting a transaction that
+// clashed with another one (serialization failure or deadlock)
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
+
+// isUniqueViolation reports whether err comes from a unique constraint on
+// column failing
+func isUniqueViolation(err error, column string) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		// SQLite names the columns: "UNIQUE constraint failed: users.email"
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+			strings.Contains(sqliteErr.Error(), "."+column)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Postgres names the constraint, like users_email_key
+		return pgErr.Code == "23505" && strings.Contains(pgErr.ConstraintName, "_"+column)
+	}
+	return false
+}
+
+// CreateUserWithPost creates a user and their first post in one transaction.
+// The post's UserID is set to the new user; if either insert fails, neither is kept.
+func CreateUserWithPost(ctx context.Context, txr TxRunner, userReq *models.CreateUserRequest, postReq *models.CreatePostRequest) (*models.User, *models.Post, error) {
+	var user *models.User
+	var post *models.Post
+	err := txr.WithTx(ctx, func(tx Repos) error {
+		var err error
+		if user, err = tx.Users.Create(ctx, userReq); err != nil {
+			return err
+		}
+		req := *postReq
+		req.UserID = user.ID
+		post, err = tx.Posts.Create(ctx, &req)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, post, nil
+}
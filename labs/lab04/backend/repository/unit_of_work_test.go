@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+
+	"lab04-backend/models"
+)
+
+func setupUnitOfWork(t *testing.T) (*UnitOfWork, *UserRepository, *PostRepository, func()) {
+	db, cleanup := openTestDB(t, "./test_unit_of_work.db")
+
+	uow := NewUnitOfWork(db)
+	uow.RetryDelay = 0
+	return uow, NewUserRepository(db), NewPostRepository(db), cleanup
+}
+
+func TestCreateUserWithPost(t *testing.T) {
+	uow, users, posts, cleanup := setupUnitOfWork(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user, post, err := CreateUserWithPost(ctx, uow,
+		&models.CreateUserRequest{Name: "Jane Doe", Email: "jane@example.com"},
+		&models.CreatePostRequest{Title: "Hello world", Content: "First!", Published: true})
+	if err != nil {
+		t.Fatalf("CreateUserWithPost() failed: %v", err)
+	}
+	if post.UserID != user.ID {
+		t.Errorf("Expected post of user %d, got %d", user.ID, post.UserID)
+	}
+	if count, _ := posts.CountByUserID(ctx, user.ID); count != 1 {
+		t.Errorf("Expected 1 committed post, got %d", count)
+	}
+
+	// A post that fails validation takes the user with it
+	_, _, err = CreateUserWithPost(ctx, uow,
+		&models.CreateUserRequest{Name: "John Doe", Email: "john@example.com"},
+		&models.CreatePostRequest{Title: "Hi"})
+	if err == nil {
+		t.Fatal("Expected error for invalid post")
+	}
+	if _, err := users.GetByEmail(ctx, "john@example.com"); err == nil {
+		t.Error("Expected user to be rolled back")
+	}
+}
+
+func TestRepos_WithTxSavepoint(t *testing.T) {
+	uow, users, _, cleanup := setupUnitOfWork(t)
+	defer cleanup()
+	ctx := context.Background()
+	errInner := errors.New("inner failed")
+
+	err := uow.WithTx(ctx, func(tx Repos) error {
+		if _, err := tx.Users.Create(ctx, &models.CreateUserRequest{Name: "Outer User", Email: "outer@example.com"}); err != nil {
+			return err
+		}
+		err := tx.WithTx(ctx, func(tx Repos) error {
+			if _, err := tx.Users.Create(ctx, &models.CreateUserRequest{Name: "Inner User", Email: "inner@example.com"}); err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("Expected inner error, got %v", err)
+		}
+		// The savepoint is released, so another one can be nested
+		return tx.WithTx(ctx, func(tx Repos) error {
+			return tx.WithTx(ctx, func(tx Repos) error {
+				_, err := tx.Users.Create(ctx, &models.CreateUserRequest{Name: "Nested User", Email: "nested@example.com"})
+				return err
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx() failed: %v", err)
+	}
+
+	if _, err := users.GetByEmail(ctx, "outer@example.com"); err != nil {
+		t.Errorf("Expected outer user to be committed, got %v", err)
+	}
+	if _, err := users.GetByEmail(ctx, "nested@example.com"); err != nil {
+		t.Errorf("Expected nested user to be committed, got %v", err)
+	}
+	if _, err := users.GetByEmail(ctx, "inner@example.com"); err == nil {
+		t.Error("Expected inner user to be rolled back")
+	}
+}
+
+func TestUnitOfWork_RetriesBusy(t *testing.T) {
+	uow, users, _, cleanup := setupUnitOfWork(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	attempts := 0
+	err := uow.WithTx(ctx, func(tx Repos) error {
+		attempts++
+		// The first attempt is rolled back, so the email is free again
+		if _, err := tx.Users.Create(ctx, &models.CreateUserRequest{Name: "Busy User", Email: "busy@example.com"}); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx() failed: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if count, _ := users.Count(ctx); count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+
+	attempts = 0
+	err = uow.WithTx(ctx, func(tx Repos) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrLocked}
+	})
+	if !IsBusy(err) {
+		t.Errorf("Expected busy error after retries, got %v", err)
+	}
+	if attempts != uow.MaxRetries+1 {
+		t.Errorf("Expected %d attempts, got %d", uow.MaxRetries+1, attempts)
+	}
+
+	attempts = 0
+	uow.WithTx(ctx, func(tx Repos) error {
+		attempts++
+		return errors.New("not busy")
+	})
+	if attempts != 1 {
+		t.Errorf("Expected other errors not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestIsBusy(t *testing.T) {
+	busy := []error{
+		sqlite3.Error{Code: sqlite3.ErrBusy},
+		sqlite3.Error{Code: sqlite3.ErrLocked},
+		&pgconn.PgError{Code: "40001"},
+		&pgconn.PgError{Code: "40P01"},
+	}
+	for _, err := range busy {
+		if !IsBusy(fmt.Errorf("failed to commit: %w", err)) {
+			t.Errorf("Expected %v to be busy", err)
+		}
+	}
+
+	notBusy := []error{nil, errors.New("other"), sqlite3.Error{Code: sqlite3.ErrConstraint}, &pgconn.PgError{Code: "23505"}}
+	for _, err := range notBusy {
+		if IsBusy(err) {
+			t.Errorf("Expected %v not to be busy", err)
+		}
+	}
+}
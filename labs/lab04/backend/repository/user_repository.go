@@ -1,27 +1,44 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"lab04-backend/models"
+	"lab05/security"
 )
 
+var (
+	// ErrEmailTaken is returned when another user already has the email
+	ErrEmailTaken = errors.New("email is already taken")
+	// ErrInvalidCredentials is returned when an email and password do not
+	// match a user with a password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// dummyPasswordHash is a bcrypt hash, at the cost security.PasswordService
+// uses, that Authenticate checks passwords against when there is no user
+// hash, so unknown emails take as long as wrong passwords
+const dummyPasswordHash = "$2a$10$1tbzvZ3oGlo8TX66BeWIIeWP1k18tlfbK1olXFamR3PeHwuXv7xgG"
+
 // UserRepository handles database operations for users
 // This repository demonstrates MANUAL SQL approach with database/sql package
 type UserRepository struct {
-	db *sql.DB
+	db        DBTX
+	passwords *security.PasswordService
 }
 
 // NewUserRepository creates a new UserRepository
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db DBTX) *UserRepository {
+	return &UserRepository{db: db, passwords: security.NewPasswordService()}
 }
 
 // Implement Create method
-func (r *UserRepository) Create(req *models.CreateUserRequest) (*models.User, error) {
+func (r *UserRepository) Create(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
 	// Create a new user in the database
 	// - Validate the request
 	if err := req.Validate(); err != nil {
@@ -30,15 +47,27 @@ func (r *UserRepository) Create(req *models.CreateUserRequest) (*models.User, er
 	// - Insert into users table
 	// - Return the created user with ID and timestamps
 	// Use RETURNING clause to get the generated ID and timestamps
+	// - Hash the password, if any; only the hash is stored
+	var passwordHash sql.NullString
+	if req.Password != "" {
+		hash, err := r.passwords.HashPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+		passwordHash = sql.NullString{String: hash, Valid: true}
+	}
 	user := req.ToUser()
 	query := `
-		INSERT INTO users (name, email, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (name, email, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, name, email, created_at, updated_at
 	`
 
-	row := r.db.QueryRow(query, user.Name, user.Email, user.CreatedAt, user.UpdatedAt)
+	row := r.db.QueryRowContext(ctx, query, user.Name, user.Email, passwordHash, user.CreatedAt, user.UpdatedAt)
 	if err := user.ScanRow(row); err != nil {
+		if isUniqueViolation(err, "email") {
+			return nil, ErrEmailTaken
+		}
 		return nil, err
 	}
 
@@ -46,7 +75,7 @@ func (r *UserRepository) Create(req *models.CreateUserRequest) (*models.User, er
 }
 
 // Implement GetByID method
-func (r *UserRepository) GetByID(id int) (*models.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
 	// Get user by ID from database
 	// - Query users table by ID
 	query := `
@@ -55,7 +84,7 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 		WHERE id = $1
 	`
 	// - Return user or sql.ErrNoRows if not found
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 	user := &models.User{}
 	if err := user.ScanRow(row); err != nil {
 		if err == sql.ErrNoRows {
@@ -68,7 +97,7 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 }
 
 // Implement GetByEmail method
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	// Get user by email from database
 	// - Query users table by email
 	query := `
@@ -77,7 +106,7 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 		WHERE email = $1
 	`
 	// - Return user or sql.ErrNoRows if not found
-	row := r.db.QueryRow(query, email)
+	row := r.db.QueryRowContext(ctx, query, email)
 	user := &models.User{}
 	if err := user.ScanRow(row); err != nil {
 		if err == sql.ErrNoRows {
@@ -90,7 +119,7 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 }
 
 // Implement GetAll method
-func (r *UserRepository) GetAll() ([]models.User, error) {
+func (r *UserRepository) GetAll(ctx context.Context) ([]models.User, error) {
 	// Get all users from database
 	// - Query all users ordered by created_at
 	query := `
@@ -99,7 +128,7 @@ func (r *UserRepository) GetAll() ([]models.User, error) {
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get users: %w", err)
 	}
@@ -112,8 +141,42 @@ func (r *UserRepository) GetAll() ([]models.User, error) {
 	return users, nil
 }
 
+// List returns a page of users, newest first
+func (r *UserRepository) List(ctx context.Context, req PageRequest) (Page[models.User], error) {
+	k, err := parsePageRequest(req)
+	if err != nil {
+		return Page[models.User]{}, err
+	}
+
+	var where string
+	var args []interface{}
+	if k.cursor != nil {
+		where = fmt.Sprintf("WHERE (created_at, id) %s ($1, $2)", k.op())
+		args = k.args()
+	}
+	query := fmt.Sprintf(`
+		SELECT id, name, email, created_at, updated_at
+		FROM users
+		%s
+		ORDER BY %s
+		LIMIT %d
+	`, where, k.order(""), k.fetch())
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page[models.User]{}, fmt.Errorf("failed to list users: %w", err)
+	}
+	users, err := models.ScanUsers(rows)
+	if err != nil {
+		return Page[models.User]{}, fmt.Errorf("failed to scan users: %w", err)
+	}
+	return buildPage(k, users, func(u models.User) Cursor {
+		return Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+	}), nil
+}
+
 // Implement Update method
-func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.User, error) {
+func (r *UserRepository) Update(ctx context.Context, id int, req *models.UpdateUserRequest) (*models.User, error) {
 	// Update user in database
 	// - Build dynamic UPDATE query based on non-nil fields in req
 	var updates []string
@@ -150,7 +213,7 @@ func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.
 		RETURNING id, name, email, created_at, updated_at
 	`, strings.Join(updates, ", "), argNum)
 
-	row := r.db.QueryRow(query, args...)
+	row := r.db.QueryRowContext(ctx, query, args...)
 	user := &models.User{}
 	// - Return updated user
 	// - Handle case where user doesn't exist
@@ -158,14 +221,85 @@ func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("user not found: %w", err)
 		}
+		if isUniqueViolation(err, "email") {
+			return nil, ErrEmailTaken
+		}
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
 	return user, nil
 }
 
+// Authenticate returns the user with email if password matches theirs.
+// Unknown emails, deleted users and users without a password all fail
+// with ErrInvalidCredentials, so callers cannot tell them apart.
+func (r *UserRepository) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	query := `
+		SELECT id, name, email, created_at, updated_at, password_hash
+		FROM users
+		WHERE email = $1 AND deleted_at IS NULL
+	`
+	user := &models.User{}
+	var passwordHash sql.NullString
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &passwordHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to authenticate user: %w", err)
+	}
+	if err == sql.ErrNoRows || passwordHash.String == "" {
+		r.passwords.VerifyPassword(password, dummyPasswordHash)
+		return nil, ErrInvalidCredentials
+	}
+	if !r.passwords.VerifyPassword(password, passwordHash.String) {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password of the user with id after checking
+// the old one. A wrong old password fails with ErrInvalidCredentials and a
+// missing user with sql.ErrNoRows.
+func (r *UserRepository) ChangePassword(ctx context.Context, id int, req *models.ChangePasswordRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	var passwordHash sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+		SELECT password_hash FROM users WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+	if !r.passwords.VerifyPassword(req.OldPassword, passwordHash.String) {
+		return ErrInvalidCredentials
+	}
+
+	hash, err := r.passwords.HashPassword(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	// Only replace the hash that was checked, in case of a concurrent change
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE users SET password_hash = $1, updated_at = $2
+		WHERE id = $3 AND password_hash = $4
+	`, hash, time.Now(), id, passwordHash.String)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	if changed, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if changed == 0 {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
 // Implement Delete method
-func (r *UserRepository) Delete(id int) error {
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
 	// Delete user from database
 	// - Delete from users table by ID
 	query := `
@@ -173,7 +307,7 @@ func (r *UserRepository) Delete(id int) error {
 		WHERE id = $1
 	`
 
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -191,7 +325,7 @@ func (r *UserRepository) Delete(id int) error {
 }
 
 // Implement Count method
-func (r *UserRepository) Count() (int, error) {
+func (r *UserRepository) Count(ctx context.Context) (int, error) {
 	// Count total number of users
 	query := `
 		SELECT COUNT(*) 
@@ -199,7 +333,7 @@ func (r *UserRepository) Count() (int, error) {
 	`
 
 	var count int
-	err := r.db.QueryRow(query).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count users: %w", err)
 	}
@@ -1,55 +1,77 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	"lab04-backend/database"
+	"lab04-backend/database/pgtest"
 	"lab04-backend/models"
+	"lab05/security"
 )
 
-func setupTestDB(t *testing.T) (*UserRepository, func()) {
-	// Create test database
-	testDB := "./test_user_repo.db"
+// testDatabaseURLEnv names the variable that points the repository tests at
+// another database, such as a Postgres server, instead of SQLite files
+const testDatabaseURLEnv = "LAB04_TEST_DATABASE_URL"
+
+// openTestDB opens an empty, migrated database: the SQLite file at path, or
+// a throwaway schema in the database in LAB04_TEST_DATABASE_URL
+func openTestDB(t *testing.T, path string) (*sql.DB, func()) {
+	t.Helper()
 	config := &database.Config{
-		DatabasePath:    testDB,
-		MaxOpenConns:    5,
-		MaxIdleConns:    1,
-		ConnMaxLifetime: 0,
-		ConnMaxIdleTime: 0,
+		DatabaseURL:  os.Getenv(testDatabaseURLEnv),
+		DatabasePath: path,
+		MaxOpenConns: 5,
+		MaxIdleConns: 1,
+	}
+	if config.DatabaseURL == "" {
+		os.Remove(path)
+	} else if dialect, _, _ := database.ParseDSN(config.DatabaseURL); dialect == database.Postgres {
+		config.DatabaseURL = pgtest.Schema(t, config.DatabaseURL)
 	}
 
 	db, err := database.InitDBWithConfig(config)
 	if err != nil {
 		t.Fatalf("Failed to initialize test database: %v", err)
 	}
-
-	err = database.RunMigrations(db)
-	if err != nil {
+	if err := database.RunMigrations(db); err != nil {
 		t.Fatalf("Failed to migrate test database: %v", err)
 	}
 
-	repo := NewUserRepository(db)
-
-	// Return cleanup function
 	cleanup := func() {
 		database.CloseDB(db)
-		os.Remove(testDB)
+		if config.DatabaseURL == "" {
+			os.Remove(path)
+		}
 	}
+	return db, cleanup
+}
+
+func setupTestDB(t *testing.T) (*UserRepository, func()) {
+	// Create test database
+	db, cleanup := openTestDB(t, "./test_user_repo.db")
 
+	repo := NewUserRepository(db)
+
+	// Return cleanup function
 	return repo, cleanup
 }
 
 func TestUserRepository_Create(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	req := &models.CreateUserRequest{
 		Name:  "John Doe",
 		Email: "john@example.com",
 	}
 
-	user, err := repo.Create(req)
+	user, err := repo.Create(ctx, req)
 	if err != nil {
 		t.Fatalf("Create() failed: %v", err)
 	}
@@ -82,6 +104,7 @@ func TestUserRepository_Create(t *testing.T) {
 func TestUserRepository_GetByID(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -89,13 +112,13 @@ func TestUserRepository_GetByID(t *testing.T) {
 		Email: "jane@example.com",
 	}
 
-	createdUser, err := repo.Create(req)
+	createdUser, err := repo.Create(ctx, req)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Test GetByID
-	foundUser, err := repo.GetByID(createdUser.ID)
+	foundUser, err := repo.GetByID(ctx, createdUser.ID)
 	if err != nil {
 		t.Fatalf("GetByID() failed: %v", err)
 	}
@@ -117,7 +140,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 	}
 
 	// Test GetByID with non-existent ID
-	_, err = repo.GetByID(99999)
+	_, err = repo.GetByID(ctx, 99999)
 	if err == nil {
 		t.Error("GetByID() should return error for non-existent user")
 	}
@@ -126,6 +149,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 func TestUserRepository_GetByEmail(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -133,13 +157,13 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 		Email: "bob@example.com",
 	}
 
-	createdUser, err := repo.Create(req)
+	createdUser, err := repo.Create(ctx, req)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Test GetByEmail
-	foundUser, err := repo.GetByEmail(createdUser.Email)
+	foundUser, err := repo.GetByEmail(ctx, createdUser.Email)
 	if err != nil {
 		t.Fatalf("GetByEmail() failed: %v", err)
 	}
@@ -153,7 +177,7 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 	}
 
 	// Test GetByEmail with non-existent email
-	_, err = repo.GetByEmail("nonexistent@example.com")
+	_, err = repo.GetByEmail(ctx, "nonexistent@example.com")
 	if err == nil {
 		t.Error("GetByEmail() should return error for non-existent email")
 	}
@@ -162,9 +186,10 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 func TestUserRepository_GetAll(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Test empty database
-	users, err := repo.GetAll()
+	users, err := repo.GetAll(ctx)
 	if err != nil {
 		t.Fatalf("GetAll() failed: %v", err)
 	}
@@ -181,14 +206,14 @@ func TestUserRepository_GetAll(t *testing.T) {
 	}
 
 	for _, req := range userRequests {
-		_, err := repo.Create(req)
+		_, err := repo.Create(ctx, req)
 		if err != nil {
 			t.Fatalf("Failed to create user: %v", err)
 		}
 	}
 
 	// Test GetAll with users
-	users, err = repo.GetAll()
+	users, err = repo.GetAll(ctx)
 	if err != nil {
 		t.Fatalf("GetAll() failed: %v", err)
 	}
@@ -201,6 +226,7 @@ func TestUserRepository_GetAll(t *testing.T) {
 func TestUserRepository_Update(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -208,7 +234,7 @@ func TestUserRepository_Update(t *testing.T) {
 		Email: "original@example.com",
 	}
 
-	createdUser, err := repo.Create(req)
+	createdUser, err := repo.Create(ctx, req)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
@@ -221,7 +247,7 @@ func TestUserRepository_Update(t *testing.T) {
 		Email: &newEmail,
 	}
 
-	updatedUser, err := repo.Update(createdUser.ID, updateReq)
+	updatedUser, err := repo.Update(ctx, createdUser.ID, updateReq)
 	if err != nil {
 		t.Fatalf("Update() failed: %v", err)
 	}
@@ -243,15 +269,121 @@ func TestUserRepository_Update(t *testing.T) {
 	}
 
 	// Test update with non-existent ID
-	_, err = repo.Update(99999, updateReq)
+	_, err = repo.Update(ctx, 99999, updateReq)
 	if err == nil {
 		t.Error("Update() should return error for non-existent user")
 	}
 }
 
+func TestUserRepository_EmailTaken(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	if _, err := repo.Create(ctx, &models.CreateUserRequest{Name: "First", Email: "taken@example.com"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	other, err := repo.Create(ctx, &models.CreateUserRequest{Name: "Second", Email: "second@example.com"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	_, err = repo.Create(ctx, &models.CreateUserRequest{Name: "Again", Email: "taken@example.com"})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken from Create(), got %v", err)
+	}
+	email := "taken@example.com"
+	_, err = repo.Update(ctx, other.ID, &models.UpdateUserRequest{Email: &email})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken from Update(), got %v", err)
+	}
+}
+
+func TestUserRepository_Authenticate(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &models.CreateUserRequest{Name: "John Doe", Email: "john@example.com", Password: "secret42"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := repo.Create(ctx, &models.CreateUserRequest{Name: "No Password", Email: "nopass@example.com"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := repo.Create(ctx, &models.CreateUserRequest{Name: "Weak", Email: "weak@example.com", Password: "weak"}); err == nil {
+		t.Error("Create() should reject a weak password")
+	}
+
+	authenticated, err := repo.Authenticate(ctx, "john@example.com", "secret42")
+	if err != nil {
+		t.Fatalf("Authenticate() failed: %v", err)
+	}
+	if authenticated.ID != user.ID || authenticated.Email != user.Email {
+		t.Errorf("Expected user %d, got %+v", user.ID, authenticated)
+	}
+
+	failures := []struct{ email, password string }{
+		{"john@example.com", "wrong42"},
+		{"john@example.com", ""},
+		{"unknown@example.com", "secret42"},
+		{"nopass@example.com", ""},
+	}
+	for _, f := range failures {
+		if _, err := repo.Authenticate(ctx, f.email, f.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%q, %q): expected ErrInvalidCredentials, got %v", f.email, f.password, err)
+		}
+	}
+}
+
+func TestDummyPasswordHashMatchesCost(t *testing.T) {
+	hash, err := security.NewPasswordService().HashPassword("secret42")
+	if err != nil {
+		t.Fatalf("HashPassword() failed: %v", err)
+	}
+	// A bcrypt hash starts with its version and cost, such as "$2a$10$"
+	if !strings.HasPrefix(dummyPasswordHash, hash[:7]) {
+		t.Errorf("Expected dummyPasswordHash to start with %q, got %q", hash[:7], dummyPasswordHash)
+	}
+}
+
+func TestUserRepository_ChangePassword(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &models.CreateUserRequest{Name: "John Doe", Email: "john@example.com", Password: "secret42"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	err = repo.ChangePassword(ctx, user.ID, &models.ChangePasswordRequest{OldPassword: "wrong42", NewPassword: "better43"})
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for a wrong old password, got %v", err)
+	}
+	if err := repo.ChangePassword(ctx, user.ID, &models.ChangePasswordRequest{OldPassword: "secret42", NewPassword: "short"}); err == nil {
+		t.Error("ChangePassword() should reject a weak new password")
+	}
+	err = repo.ChangePassword(ctx, 99999, &models.ChangePasswordRequest{OldPassword: "secret42", NewPassword: "better43"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for a missing user, got %v", err)
+	}
+
+	if err := repo.ChangePassword(ctx, user.ID, &models.ChangePasswordRequest{OldPassword: "secret42", NewPassword: "better43"}); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if _, err := repo.Authenticate(ctx, "john@example.com", "secret42"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected the old password to stop working, got %v", err)
+	}
+	if _, err := repo.Authenticate(ctx, "john@example.com", "better43"); err != nil {
+		t.Errorf("Authenticate() with the new password failed: %v", err)
+	}
+}
+
 func TestUserRepository_Delete(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -259,25 +391,25 @@ func TestUserRepository_Delete(t *testing.T) {
 		Email: "delete@example.com",
 	}
 
-	createdUser, err := repo.Create(req)
+	createdUser, err := repo.Create(ctx, req)
 	if err != nil {
 		t.Fatalf("Failed to create user: %v", err)
 	}
 
 	// Test delete
-	err = repo.Delete(createdUser.ID)
+	err = repo.Delete(ctx, createdUser.ID)
 	if err != nil {
 		t.Fatalf("Delete() failed: %v", err)
 	}
 
 	// Verify user is deleted
-	_, err = repo.GetByID(createdUser.ID)
+	_, err = repo.GetByID(ctx, createdUser.ID)
 	if err == nil {
 		t.Error("User should be deleted and GetByID should return error")
 	}
 
 	// Test delete with non-existent ID
-	err = repo.Delete(99999)
+	err = repo.Delete(ctx, 99999)
 	if err == nil {
 		t.Error("Delete() should return error for non-existent user")
 	}
@@ -286,9 +418,10 @@ func TestUserRepository_Delete(t *testing.T) {
 func TestUserRepository_Count(t *testing.T) {
 	repo, cleanup := setupTestDB(t)
 	defer cleanup()
+	ctx := context.Background()
 
 	// Test count with empty database
-	count, err := repo.Count()
+	count, err := repo.Count(ctx)
 	if err != nil {
 		t.Fatalf("Count() failed: %v", err)
 	}
@@ -304,14 +437,14 @@ func TestUserRepository_Count(t *testing.T) {
 	}
 
 	for _, req := range userRequests {
-		_, err := repo.Create(req)
+		_, err := repo.Create(ctx, req)
 		if err != nil {
 			t.Fatalf("Failed to create user: %v", err)
 		}
 	}
 
 	// Test count with users
-	count, err = repo.Count()
+	count, err = repo.Count(ctx)
 	if err != nil {
 		t.Fatalf("Count() failed: %v", err)
 	}
@@ -32,9 +32,11 @@ func NewJWTService(secretKey string) (*JWTService, error) {
 // - Token expires in 24 hours
 // - Use HS256 signing method
 func (j *JWTService) GenerateToken(userID int, email string) (string, error) {
-	// TODO: Implement token generation
-	// Create claims with userID, email, and expiration
-	// Sign token with secret key
+	return j.GenerateTokenWithRole(userID, email, "")
+}
+
+// GenerateTokenWithRole creates a token like GenerateToken that also carries a role
+func (j *JWTService) GenerateTokenWithRole(userID int, email, role string) (string, error) {
 	if userID <= 0 {
 		return "", NewValidationError("user_id", "must be positive")
 	}
@@ -45,6 +47,7 @@ func (j *JWTService) GenerateToken(userID int, email string) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
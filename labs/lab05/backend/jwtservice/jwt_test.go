@@ -154,3 +154,25 @@ func TestJWTService_DifferentSecrets(t *testing.T) {
 		t.Error("Claims should not be nil for valid token")
 	}
 }
+
+func TestJWTService_GenerateTokenWithRole(t *testing.T) {
+	service, _ := NewJWTService("test-secret")
+
+	token, err := service.GenerateTokenWithRole(7, "admin@example.com", "admin")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := service.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("Token should be valid: %v", err)
+	}
+	if claims.Role != "admin" {
+		t.Errorf("Expected role 'admin', got '%s'", claims.Role)
+	}
+
+	token, _ = service.GenerateToken(7, "user@example.com")
+	claims, _ = service.ValidateToken(token)
+	if claims.Role != "" {
+		t.Errorf("Expected no role, got '%s'", claims.Role)
+	}
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import "github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+
+// OpenAPI describes the HTTP routes of the gateway.
+// CORS preflight (OPTIONS) routes are not documented.
+func (s *Service) OpenAPI() *openapi.Document {
+	spec := openapi.New("Calculator Gateway", "1.0.0",
+		"HTTP gateway in front of the gRPC calculator service.")
+
+	operation := spec.Schema(OperationResponse{})
+	plainError := func(description string) *openapi.Response {
+		return openapi.Body(description, "text/plain", openapi.String())
+	}
+
+	for _, op := range []struct{ name, summary string }{
+		{"add", "Add b to a"},
+		{"subtract", "Subtract b from a"},
+		{"multiply", "Multiply a by b"},
+		{"divide", "Divide a by b"},
+	} {
+		invalid := "Invalid request body or failed operation"
+		if op.name == "divide" {
+			invalid = "Invalid request body or division by zero"
+		}
+		spec.Add("POST", "/api/v1/calculate/"+op.name, openapi.Operation{
+			Summary:     op.summary,
+			Tags:        []string{"calculator"},
+			RequestBody: openapi.JSONBody(spec.Schema(OperationRequest{})),
+			Responses: openapi.Responses(map[int]*openapi.Response{
+				200: openapi.JSON("Result", operation),
+				400: openapi.JSON(invalid, operation),
+				500: plainError("Calculator service error"),
+			}),
+		})
+	}
+
+	spec.Add("GET", "/api/v1/history", openapi.Operation{
+		Summary:    "Recent calculations",
+		Tags:       []string{"calculator"},
+		Parameters: []openapi.Parameter{openapi.QueryParam("limit", "Maximum entries, default 10", openapi.Integer())},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("History entries", spec.Schema(HistoryResponse{})),
+			500: plainError("Calculator service error"),
+		}),
+	})
+	spec.Add("GET", "/api/v1/health", openapi.Operation{
+		Summary: "Report gateway health",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("Gateway is healthy", spec.Schema(HealthResponse{})),
+		}),
+	})
+	spec.Add("GET", "/openapi.json", openapi.Operation{
+		Summary: "This OpenAPI document",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.JSON("OpenAPI 3 document", &openapi.Schema{Type: "object"}),
+		}),
+	})
+	spec.Add("GET", "/docs", openapi.Operation{
+		Summary: "API documentation page",
+		Tags:    []string{"system"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			200: openapi.Body("HTML page", "text/html", openapi.String()),
+		}),
+	})
+
+	return spec.Document()
+}
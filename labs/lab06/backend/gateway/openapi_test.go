@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	s := createTestService()
+
+	var served []string
+	err := s.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			served = append(served, method+" "+openapi.NormalizePath(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	undocumented, unserved := openapi.Diff(served, s.OpenAPI().Routes())
+	for _, route := range undocumented {
+		t.Errorf("Route %s is missing from the OpenAPI document", route)
+	}
+	for _, route := range unserved {
+		t.Errorf("OpenAPI documents %s, which is not routed", route)
+	}
+}
+
+func TestService_OpenAPI(t *testing.T) {
+	router := createTestRouter()
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if _, ok := doc.Components.Schemas["OperationRequest"]; !ok {
+		t.Error("Expected OperationRequest in the components")
+	}
+	if doc.Paths["/api/v1/calculate/divide"]["post"] == nil {
+		t.Error("Expected POST /api/v1/calculate/divide to be documented")
+	}
+}
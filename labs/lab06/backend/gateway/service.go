@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -48,6 +49,13 @@ type HistoryEntry struct {
 	Timestamp int64   `json:"timestamp"`
 }
 
+// HealthResponse represents HTTP health check response
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Service   string `json:"service"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 // NewService creates a new gateway service
 func NewService(calculatorAddr string) (*Service, error) {
 	conn, err := grpc.Dial(calculatorAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -101,6 +109,10 @@ func (s *Service) setupRoutes() {
 	api.HandleFunc("/calculate/divide", s.handleDivide).Methods("POST")
 	api.HandleFunc("/history", s.handleHistory).Methods("GET")
 	api.HandleFunc("/health", s.handleHealth).Methods("GET")
+
+	// API documentation, generated by OpenAPI
+	s.router.Handle("/openapi.json", openapi.Handler(s.OpenAPI())).Methods("GET")
+	s.router.Handle("/docs", openapi.DocsHandler("/openapi.json")).Methods("GET")
 }
 
 // GetRouter returns the HTTP router
@@ -238,10 +250,10 @@ func (s *Service) handleHistory(w http.ResponseWriter, r *http.Request) {
 
 // handleHealth handles health check requests
 func (s *Service) handleHealth(w http.ResponseWriter, r *http.Request) {
-	health := map[string]interface{}{
-		"status":    "healthy",
-		"service":   "calculator-gateway",
-		"timestamp": time.Now().Unix(),
+	health := &HealthResponse{
+		Status:    "healthy",
+		Service:   "calculator-gateway",
+		Timestamp: time.Now().Unix(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -11,6 +11,7 @@ require (
 	github.com/gorilla/mux v1.8.1
 	github.com/gorilla/websocket v1.5.3
 	google.golang.org/grpc v1.73.0
+	github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi v0.0.0
 	google.golang.org/protobuf v1.36.6
 )
 
@@ -20,3 +21,5 @@ require (
 	golang.org/x/text v0.23.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250324211829-b45e905df463 // indirect
 )
+
+replace github.com/timur-harin/sum25-go-flutter-course/backend/pkg/openapi => ../../../backend/pkg/openapi